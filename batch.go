@@ -0,0 +1,146 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+// runBatch runs the editor in batch mode, for -es and --batch: each file
+// named on the command line is edited in turn and the Ex commands are run
+// on it, without using the terminal. The config file, plugins, language
+// servers and saved state are not used. A failing command is reported on
+// stderr and stops the commands for that file, so that it is not written
+// half edited. Changes left unwritten, unless thrown away with :q!, are
+// reported too. Command output, such as that of :!, goes to stdout. With
+// "-" the text is read from stdin and written to stdout once the commands
+// have run. It returns the exit status: 1 if there was any error.
+func runBatch(args cliArgs, stdin io.Reader, stdout, stderr io.Writer) int {
+	commands, err := batchCommands(args, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "goedit: %v\n", err)
+		return 2
+	}
+
+	ed := editor.NewEditor(80, 24)
+	if args.readOnly {
+		_ = ed.SetOption("readonly", true)
+	}
+	ed.ArgIndex = -1
+	for _, f := range args.files {
+		ed.ArgList = append(ed.ArgList, f.name)
+	}
+
+	status := 0
+	fail := func(name string, err error) {
+		fmt.Fprintf(stderr, "goedit: %s: %v\n", name, err)
+		status = 1
+	}
+	switch {
+	case args.stdin:
+		content, err := io.ReadAll(stdin)
+		if err != nil {
+			fail("-", err)
+			return status
+		}
+		ed.LoadFile(content)
+		if err := runBatchCommands(ed, commands, stdout); err != nil {
+			fail("-", err)
+		}
+		if _, err := stdout.Write(ed.EncodedContent()); err != nil {
+			fail("-", err)
+		}
+	case len(ed.ArgList) == 0:
+		if err := runBatchCommands(ed, commands, stdout); err != nil {
+			fail("[No Name]", err)
+		} else if err := unwrittenChanges(ed); err != nil {
+			fail("[No Name]", err)
+		}
+	default:
+		for i, name := range ed.ArgList {
+			ed.ShouldQuit, ed.QuitWarned = false, false
+			if err := cmd.EditArg(ed, i); err != nil {
+				fail(name, err)
+				continue
+			}
+			if err := runBatchCommands(ed, commands, stdout); err != nil {
+				fail(name, err)
+			} else if err := unwrittenChanges(ed); err != nil {
+				fail(name, err)
+			}
+		}
+	}
+	return status
+}
+
+// runBatchCommands runs the batch commands on the buffer until one fails or
+// quits, each as its own undo step.
+func runBatchCommands(ed *editor.Editor, commands []string, stdout io.Writer) error {
+	for _, c := range commands {
+		ed.BeginUndoStep()
+		err := cmd.ExecuteCommand(ed, c)
+		ed.EndUndoStep()
+		if err == nil && ed.CurrentMode == editor.ModeFileNamePrompt {
+			ed.CurrentMode = editor.ModeNormal // There is no one to answer the prompt
+			err = errors.New("No file name")
+		}
+		for _, line := range ed.Output {
+			fmt.Fprintln(stdout, line)
+		}
+		ed.Output = nil
+		if err != nil {
+			return fmt.Errorf("%s: %v", c, err)
+		}
+		if ed.ShouldQuit {
+			break
+		}
+	}
+	return nil
+}
+
+// unwrittenChanges returns an error if the buffer has changes that were
+// neither written nor thrown away with :q!, which editing the next file
+// would lose.
+func unwrittenChanges(ed *editor.Editor) error {
+	if ed.IsDirty && !ed.ShouldQuit {
+		return errors.New("No write since last change (add :w, or :q! to throw the changes away)")
+	}
+	return nil
+}
+
+// batchCommands returns the Ex commands to run in batch mode: those given
+// with -c or +, then those of the --batch script. Without either they are
+// read from stdin, unless the text is. In a script, empty lines and lines
+// starting with '"' are skipped.
+func batchCommands(args cliArgs, stdin io.Reader) ([]string, error) {
+	commands := args.commands
+	var script io.Reader
+	switch {
+	case args.script == "-" || args.script == "" && len(commands) == 0 && !args.stdin:
+		script = stdin
+	case args.script != "":
+		f, err := os.Open(args.script)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		script = f
+	default:
+		return commands, nil
+	}
+
+	scanner := bufio.NewScanner(script)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "\"") {
+			commands = append(commands, line)
+		}
+	}
+	return commands, scanner.Err()
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRunBatch(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	_ = os.WriteFile(a, []byte("foo one\nfoo two\n"), 0644)
+	_ = os.WriteFile(b, []byte("bar\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	args := cliArgs{files: []filePosition{{name: a}, {name: b}}, batch: true, commands: []string{"%s/foo/baz/", "w"}}
+	if status := runBatch(args, strings.NewReader(""), &stdout, &stderr); status != 1 {
+		t.Errorf("Expected status 1 for the file without a match, got %d", status)
+	}
+	if got, _ := os.ReadFile(a); string(got) != "baz one\nbaz two\n" {
+		t.Errorf("Expected %s substituted and written, got %q", a, got)
+	}
+	if got, _ := os.ReadFile(b); string(got) != "bar\n" {
+		t.Errorf("Expected %s unchanged, got %q", b, got)
+	}
+	if !strings.Contains(stderr.String(), b+": %s/foo/baz/: Pattern not found: foo") {
+		t.Errorf("Expected the error on stderr, got %q", stderr.String())
+	}
+
+	// A script from stdin, with the text from a file.
+	stderr.Reset()
+	args = cliArgs{files: []filePosition{{name: b}}, batch: true}
+	script := "\" Comment\n\ns/r$/t/\nw\n"
+	if status := runBatch(args, strings.NewReader(script), &stdout, &stderr); status != 0 {
+		t.Errorf("Expected status 0, got %d: %s", status, stderr.String())
+	}
+	if got, _ := os.ReadFile(b); string(got) != "bat\n" {
+		t.Errorf("Expected the script run on %s, got %q", b, got)
+	}
+}
+
+func TestRunBatchStdin(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	args := cliArgs{stdin: true, batch: true, commands: []string{"%s/a/b/g", "1"}}
+	if status := runBatch(args, strings.NewReader("aaa\nxa\n"), &stdout, &stderr); status != 0 {
+		t.Errorf("Expected status 0, got %d: %s", status, stderr.String())
+	}
+	if stdout.String() != "bbb\nxb\n" {
+		t.Errorf("Expected the filtered text on stdout, got %q", stdout.String())
+	}
+
+	stdout.Reset()
+	args.commands = []string{"w"}
+	if status := runBatch(args, strings.NewReader("a\n"), &stdout, &stderr); status != 1 {
+		t.Errorf("Expected :w without a file name to fail, got status %d", status)
+	}
+}
+
+func TestRunBatchUnwritten(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.txt")
+	_ = os.WriteFile(file, []byte("a\n"), 0644)
+
+	var stdout, stderr bytes.Buffer
+	args := cliArgs{files: []filePosition{{name: file}}, batch: true, commands: []string{"s/a/b/"}}
+	if status := runBatch(args, strings.NewReader(""), &stdout, &stderr); status != 1 {
+		t.Errorf("Expected status 1 for changes not written, got %d", status)
+	}
+	if !strings.Contains(stderr.String(), "No write since last change") {
+		t.Errorf("Expected an error on stderr, got %q", stderr.String())
+	}
+
+	// Changes thrown away with :q! are not an error.
+	stderr.Reset()
+	args.commands = []string{"s/a/b/", "q!"}
+	if status := runBatch(args, strings.NewReader(""), &stdout, &stderr); status != 0 {
+		t.Errorf("Expected status 0 after :q!, got %d: %s", status, stderr.String())
+	}
+	if got, _ := os.ReadFile(file); string(got) != "a\n" {
+		t.Errorf("Expected the file unchanged, got %q", got)
+	}
+}
@@ -0,0 +1,147 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// version is the goedit version, set at build time with
+// -ldflags "-X main.version=...".
+var version = "dev"
+
+const usage = `Usage: goedit [options] [file ...]
+       goedit [options] -
+
+Options:
+  +N              Start at line N (+ alone for the last line)
+  +/pattern       Start at the first line matching pattern
+  +{command}      Run an Ex command after loading the first file
+  -c {command}    Run an Ex command after loading the first file
+  -R              Read-only mode: :w needs a "!"
+  -S [session]    Restore a session saved with :mksession (default Session.vim)
+  -es             Batch mode: run the Ex commands on each file without the
+                  terminal, reading them from standard input if none are given
+  --batch script  Batch mode, running the Ex commands in script ("-" for
+                  standard input)
+  -               Read the text to edit from standard input
+  --version       Show the version and exit
+  --help, -h      Show this help and exit
+
+A file may be given as file:line or file:line:col, as compilers print them.
+`
+
+// maxCommands is how many +{command} and -c arguments may be given, as in Vim.
+const maxCommands = 10
+
+// cliArgs are the parsed command-line arguments.
+type cliArgs struct {
+	files       []filePosition
+	commands    []string // Ex commands to run after loading, in order
+	readOnly    bool
+	session     string
+	loadSession bool
+	stdin       bool
+	batch       bool   // Run the commands on each file without the terminal
+	script      string // File of Ex commands to run in batch mode
+	version     bool
+	help        bool
+}
+
+// filePosition is a file to edit and, if given as file:line:col, where to
+// put the cursor (1-based; 0 if not given).
+type filePosition struct {
+	name      string
+	line, col int
+}
+
+// parseArgs parses the command-line arguments, not including the program
+// name.
+func parseArgs(args []string) (cliArgs, error) {
+	var a cliArgs
+	onlyFiles := false
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		switch {
+		case onlyFiles:
+			a.files = append(a.files, splitFilePosition(arg))
+		case arg == "--":
+			onlyFiles = true
+		case arg == "--version":
+			a.version = true
+		case arg == "--help" || arg == "-h":
+			a.help = true
+		case arg == "-R":
+			a.readOnly = true
+		case arg == "-":
+			a.stdin = true
+		case arg == "-es":
+			a.batch = true
+		case arg == "--batch":
+			if i+1 == len(args) {
+				return a, errors.New("Argument missing after --batch")
+			}
+			i++
+			a.batch, a.script = true, args[i]
+		case arg == "-S":
+			a.loadSession = true
+			if i+1 < len(args) && !strings.HasPrefix(args[i+1], "-") && !strings.HasPrefix(args[i+1], "+") {
+				i++
+				a.session = args[i]
+			}
+		case arg == "-c":
+			if i+1 == len(args) {
+				return a, errors.New("Argument missing after -c")
+			}
+			i++
+			a.commands = append(a.commands, args[i])
+		case strings.HasPrefix(arg, "+"):
+			a.commands = append(a.commands, startCommand(arg[1:]))
+		case strings.HasPrefix(arg, "-"):
+			return a, fmt.Errorf("Unknown option: %s", arg)
+		default:
+			a.files = append(a.files, splitFilePosition(arg))
+		}
+	}
+	if len(a.commands) > maxCommands {
+		return a, fmt.Errorf("Too many + or -c arguments (at most %d)", maxCommands)
+	}
+	if a.batch && a.loadSession {
+		return a, errors.New("-S cannot be used in batch mode")
+	}
+	if a.script == "-" && a.stdin {
+		return a, errors.New("Cannot read both the script and the text from standard input")
+	}
+	return a, nil
+}
+
+// startCommand returns the Ex command for a +{command} argument: +N and +/pat
+// are line addresses that jump there, and + alone goes to the last line.
+func startCommand(s string) string {
+	if s == "" {
+		return "$"
+	}
+	return s
+}
+
+// filePositionRE matches a file name followed by a line number and
+// optionally a column, as in compiler messages.
+var filePositionRE = regexp.MustCompile(`^(.+?):(\d+)(?::(\d+))?:?$`)
+
+// splitFilePosition splits an argument such as "main.go:12:5" into the file
+// and position, unless a file with the whole name exists.
+func splitFilePosition(arg string) filePosition {
+	m := filePositionRE.FindStringSubmatch(arg)
+	if m == nil {
+		return filePosition{name: arg}
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return filePosition{name: arg}
+	}
+	line, _ := strconv.Atoi(m[2])
+	col, _ := strconv.Atoi(m[3])
+	return filePosition{name: m[1], line: line, col: col}
+}
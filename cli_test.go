@@ -0,0 +1,80 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		args     []string
+		expected cliArgs
+	}{
+		{[]string{"a.txt", "b.txt"}, cliArgs{files: []filePosition{{name: "a.txt"}, {name: "b.txt"}}}},
+		{[]string{"+12", "a.txt"}, cliArgs{files: []filePosition{{name: "a.txt"}}, commands: []string{"12"}}},
+		{[]string{"+", "+/func main", "a.txt"}, cliArgs{files: []filePosition{{name: "a.txt"}}, commands: []string{"$", "/func main"}}},
+		{[]string{"-R", "-c", "set nu", "-c", "2"}, cliArgs{readOnly: true, commands: []string{"set nu", "2"}}},
+		{[]string{"-S"}, cliArgs{loadSession: true}},
+		{[]string{"-S", "s.vim", "a.txt"}, cliArgs{loadSession: true, session: "s.vim", files: []filePosition{{name: "a.txt"}}}},
+		{[]string{"-S", "-R"}, cliArgs{loadSession: true, readOnly: true}},
+		{[]string{"-"}, cliArgs{stdin: true}},
+		{[]string{"--", "-R"}, cliArgs{files: []filePosition{{name: "-R"}}}},
+		{[]string{"main.go:12:5", "x.go:3:"}, cliArgs{files: []filePosition{{"main.go", 12, 5}, {"x.go", 3, 0}}}},
+		{[]string{"--version", "--help"}, cliArgs{version: true, help: true}},
+		{[]string{"-es", "-c", "%s/a/b/", "a.txt"}, cliArgs{batch: true, commands: []string{"%s/a/b/"}, files: []filePosition{{name: "a.txt"}}}},
+		{[]string{"--batch", "fix.ex", "a.txt"}, cliArgs{batch: true, script: "fix.ex", files: []filePosition{{name: "a.txt"}}}},
+	}
+	for _, tt := range tests {
+		got, err := parseArgs(tt.args)
+		if err != nil {
+			t.Errorf("parseArgs(%q): %v", tt.args, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tt.expected) {
+			t.Errorf("parseArgs(%q): expected %+v, got %+v", tt.args, tt.expected, got)
+		}
+	}
+
+	for _, args := range [][]string{{"-x"}, {"-c"}, {"--batch"}, {"-es", "-S"}, {"--batch", "-", "-"}, {"+1", "+2", "+3", "+4", "+5", "+6", "+7", "+8", "+9", "+10", "+11"}} {
+		if _, err := parseArgs(args); err == nil {
+			t.Errorf("parseArgs(%q): expected an error", args)
+		}
+	}
+}
+
+func TestSplitFilePosition(t *testing.T) {
+	dir := t.TempDir()
+	odd := filepath.Join(dir, "odd:1")
+	_ = os.WriteFile(odd, nil, 0644)
+	tests := []struct {
+		arg      string
+		expected filePosition
+	}{
+		{"main.go", filePosition{name: "main.go"}},
+		{"main.go:7", filePosition{"main.go", 7, 0}},
+		{"src/main.go:7:12:", filePosition{"src/main.go", 7, 12}},
+		{"C:notes", filePosition{name: "C:notes"}},
+		{odd, filePosition{name: odd}}, // An existing file is taken as named
+	}
+	for _, tt := range tests {
+		if got := splitFilePosition(tt.arg); got != tt.expected {
+			t.Errorf("splitFilePosition(%q): expected %+v, got %+v", tt.arg, tt.expected, got)
+		}
+	}
+}
+
+func TestStartCommandPastEnd(t *testing.T) {
+	ed := editor.NewEditor(80, 24)
+	ed.EditorContent = []string{"one", "two"}
+	if err := cmd.ExecuteCommand(ed, startCommand("99")); err != nil {
+		t.Fatalf("+99: %v", err)
+	}
+	if ed.CursorY != 1 {
+		t.Errorf("Expected +99 to go to the last line, got line %d", ed.CursorY)
+	}
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"goedit/editor"
+)
+
+func init() {
+	for _, name := range []string{"next", "n"} {
+		RegisterCommand(name, argCommand(func(e *editor.Editor) int { return e.ArgIndex + 1 }))
+	}
+	for _, name := range []string{"previous", "prev", "N", "Next"} {
+		RegisterCommand(name, argCommand(func(e *editor.Editor) int { return e.ArgIndex - 1 }))
+	}
+	for _, name := range []string{"first", "fir", "rewind", "rew"} {
+		RegisterCommand(name, argCommand(func(e *editor.Editor) int { return 0 }))
+	}
+	for _, name := range []string{"last", "la"} {
+		RegisterCommand(name, argCommand(func(e *editor.Editor) int { return len(e.ArgList) - 1 }))
+	}
+	RegisterCommand("args", argsCommand)
+	RegisterCommand("ar", argsCommand)
+	RegisterCommand("argument", argumentCommand)
+	RegisterCommand("argu", argumentCommand)
+	RegisterCommand("buffer", bufferCommand)
+	RegisterCommand("b", bufferCommand)
+}
+
+// EditArg edits file i of the argument list.
+func EditArg(e *editor.Editor, i int) error {
+	e.ArgIndex = i
+	if err := EditFile(e, e.ArgList[i]); err != nil {
+		return err
+	}
+	e.SetStatusMessage(fmt.Sprintf("\"%s\" (%d of %d)", e.ArgList[i], i+1, len(e.ArgList)))
+	return nil
+}
+
+// argCommand returns the implementation of a command such as :next, which
+// edits the file of the argument list at the index target returns. Unsaved
+// changes are only thrown away with "!".
+func argCommand(target func(e *editor.Editor) int) CommandFunc {
+	return func(e *editor.Editor, c ExCommand) error {
+		if len(e.ArgList) == 0 {
+			return errors.New("There is no file name")
+		}
+		return switchArg(e, target(e), c.Bang)
+	}
+}
+
+// switchArg edits file i of the argument list, which with unsaved changes
+// needs bang.
+func switchArg(e *editor.Editor, i int, bang bool) error {
+	switch {
+	case i >= len(e.ArgList):
+		return errors.New("Cannot go beyond last file")
+	case i < 0:
+		return errors.New("Cannot go before first file")
+	case e.IsDirty && !bang:
+		return errors.New("No write since last change (add ! to override)")
+	}
+	return EditArg(e, i)
+}
+
+// argumentCommand implements :argu[ment][!] [N], which edits file N of the
+// argument list, counted from 1, or the current one again.
+func argumentCommand(e *editor.Editor, c ExCommand) error {
+	if len(e.ArgList) == 0 {
+		return errors.New("There is no file name")
+	}
+	if c.Args == "" {
+		return switchArg(e, max(e.ArgIndex, 0), c.Bang)
+	}
+	n, rest := leadingNumber(c.Args)
+	if rest != "" {
+		return fmt.Errorf("Trailing characters: %s", c.Args)
+	}
+	return switchArg(e, n-1, c.Bang)
+}
+
+// BufferNames returns the names of the files being edited: those of the
+// argument list, then the current file if it is not one of them. Only one
+// is loaded at a time.
+func BufferNames(e *editor.Editor) []string {
+	names := slices.Clone(e.ArgList)
+	if e.Filename != "" && !slices.Contains(names, e.Filename) {
+		names = append(names, e.Filename)
+	}
+	return names
+}
+
+// bufferCommand implements :b[uffer][!] {N|name}, which edits buffer N of
+// BufferNames, counted from 1, or the one whose name is name or, failing
+// that, the only one whose name contains it. Unsaved changes are only
+// thrown away with "!".
+func bufferCommand(e *editor.Editor, c ExCommand) error {
+	names := BufferNames(e)
+	if c.Args == "" {
+		return errors.New("Argument required")
+	}
+	name := ""
+	if n, rest := leadingNumber(c.Args); rest == "" {
+		if n < 1 || n > len(names) {
+			return fmt.Errorf("Buffer %d does not exist", n)
+		}
+		name = names[n-1]
+	} else if slices.Contains(names, c.Args) {
+		name = c.Args
+	} else {
+		for _, candidate := range names {
+			if !strings.Contains(candidate, c.Args) {
+				continue
+			}
+			if name != "" {
+				return fmt.Errorf("More than one match for %s", c.Args)
+			}
+			name = candidate
+		}
+		if name == "" {
+			return fmt.Errorf("No matching buffer for %s", c.Args)
+		}
+	}
+
+	if i := slices.Index(e.ArgList, name); i >= 0 {
+		return switchArg(e, i, c.Bang)
+	}
+	if e.IsDirty && !c.Bang {
+		return errors.New("No write since last change (add ! to override)")
+	}
+	return EditFile(e, name)
+}
+
+// argsCommand implements :ar[gs], which shows the argument list with the
+// file being edited in brackets, and :ar[gs][!] {file}..., which replaces
+// the argument list with the files and edits the first. White space in a
+// name is escaped with a backslash. Unsaved changes are only thrown away
+// with "!".
+func argsCommand(e *editor.Editor, c ExCommand) error {
+	if files := splitSetArgs(c.Args); len(files) > 0 {
+		if e.IsDirty && !c.Bang {
+			return errors.New("No write since last change (add ! to override)")
+		}
+		for i, name := range files {
+			files[i] = ExpandHome(name)
+		}
+		e.ArgList = files
+		return EditArg(e, 0)
+	}
+	names := make([]string, len(e.ArgList))
+	for i, name := range e.ArgList {
+		if i == e.ArgIndex {
+			name = "[" + name + "]"
+		}
+		names[i] = name
+	}
+	e.SetStatusMessage(strings.Join(names, " "))
+	return nil
+}
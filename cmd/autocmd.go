@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("autocmd", autocmdCommand)
+	RegisterCommand("au", autocmdCommand)
+}
+
+// cutField splits s into its first space-separated word and the rest.
+func cutField(s string) (field, rest string) {
+	field, rest, _ = strings.Cut(strings.TrimLeft(s, " "), " ")
+	return field, strings.TrimLeft(rest, " ")
+}
+
+// eventNames returns the events named by a comma-separated list, in their
+// canonical spelling. "" and "*" name every event.
+func eventNames(list string) ([]string, error) {
+	if list == "" || list == "*" {
+		return editor.EventNames, nil
+	}
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		i := 0
+		for i < len(editor.EventNames) && !strings.EqualFold(editor.EventNames[i], name) {
+			i++
+		}
+		if i == len(editor.EventNames) {
+			return nil, fmt.Errorf("No such event: %s", name)
+		}
+		names = append(names, editor.EventNames[i])
+	}
+	return names, nil
+}
+
+// autocmdCommand implements :au[tocmd] {events} {pattern} {cmd}, which runs
+// cmd whenever one of a comma-separated list of events fires for a file
+// matching pattern, and :autocmd! {events} [{pattern}] [{cmd}], which first
+// removes the autocommands of the events with that pattern (or any pattern)
+// before adding the new one, if any. :autocmd [{events} [{pattern}]] lists
+// autocommands.
+func autocmdCommand(e *editor.Editor, c ExCommand) error {
+	events, rest := cutField(c.Args)
+	pattern, command := cutField(rest)
+	names, err := eventNames(events)
+	if err != nil {
+		return err
+	}
+	if c.Bang {
+		for _, name := range names {
+			e.RemoveAutocmds(name, pattern)
+		}
+		if command == "" {
+			return nil
+		}
+	}
+	if command == "" {
+		return listAutocmds(e, names, pattern)
+	}
+	if events == "*" {
+		return errors.New("Cannot add an autocommand for all events")
+	}
+
+	for _, name := range names {
+		e.AddEventHandler(name, editor.EventHandler{
+			Pattern: pattern,
+			Command: command,
+			Func: func(ev editor.Event) {
+				if err := ExecuteCommand(e, command); err != nil {
+					e.SetStatusMessage(fmt.Sprintf("%s Autocommands for \"%s\": %v", ev.Name, pattern, err))
+				}
+			},
+		})
+	}
+	return nil
+}
+
+// listAutocmds shows the autocommands of the named events with pattern, or
+// with any pattern if it is "", grouped by event.
+func listAutocmds(e *editor.Editor, names []string, pattern string) error {
+	var lines []string
+	for _, name := range names {
+		first := true
+		for _, h := range e.EventHandlers[name] {
+			if h.Command == "" || pattern != "" && h.Pattern != pattern {
+				continue
+			}
+			if first {
+				lines = append(lines, name)
+				first = false
+			}
+			lines = append(lines, fmt.Sprintf("    %-12s %s", h.Pattern, h.Command))
+		}
+	}
+	if len(lines) == 0 {
+		return errors.New("No matching autocommands")
+	}
+	e.ShowOutput(lines)
+	return nil
+}
@@ -1,104 +1,274 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"os"
+	"strings"
 
 	"goedit/editor"
 	"goedit/terminal"
 )
 
+// ExCommand is a parsed command line such as ":3,5normal Ax".
+type ExCommand struct {
+	Name     string    // Command name, without any "!"
+	Bang     bool      // Whether the name was followed by "!"
+	Args     string    // Everything after the name
+	Range    LineRange // Lines to act on; the cursor line if none was given
+	HasRange bool      // Whether a range was typed
+	LineZero bool      // Whether the range was address 0, above the first line
+}
+
+// CommandFunc implements an Ex command. A returned error is shown in the
+// status bar.
+type CommandFunc func(e *editor.Editor, c ExCommand) error
+
 // commandFuncMap defines the mapping from command strings to functions.
-var commandFuncMap = map[string]func(e *editor.Editor){
-	"w":  func(e *editor.Editor) { _ = SaveFile(e) },
-	"wq": saveAndQuit,
-	"q":  QuitEditor,
-	"q!": quitWithoutSaving,
+var commandFuncMap = map[string]CommandFunc{
+	"w":         writeCommand(writeFile),
+	"wq":        writeCommand(saveAndQuit),
+	"q":         withoutArgs(QuitEditor),
+	"q!":        withoutArgs(quitWithoutSaving),
+	"set":       setCommand(setAccess),
+	"se":        setCommand(setAccess),
+	"setlocal":  setCommand(setLocalAccess),
+	"setl":      setCommand(setLocalAccess),
+	"setglobal": setCommand(setGlobalAccess),
+	"setg":      setCommand(setGlobalAccess),
+	"undo":      undoCommand((*editor.Editor).Undo),
+	"u":         undoCommand((*editor.Editor).Undo),
+	"redo":      undoCommand((*editor.Editor).Redo),
+	"red":       undoCommand((*editor.Editor).Redo),
+}
+
+// withoutArgs adapts a command that takes no range or arguments.
+func withoutArgs(fn func(e *editor.Editor)) CommandFunc {
+	return func(e *editor.Editor, c ExCommand) error {
+		fn(e)
+		return nil
+	}
+}
+
+// writeCommand adapts a command that saves the file, which for a readonly
+// buffer needs a "!".
+func writeCommand(fn func(e *editor.Editor) error) CommandFunc {
+	return func(e *editor.Editor, c ExCommand) error {
+		if e.BoolOption("readonly") && !c.Bang {
+			return errors.New("'readonly' option is set (add ! to override)")
+		}
+		return fn(e)
+	}
+}
+
+// undoCommand adapts Undo or Redo as :undo or :redo.
+func undoCommand(fn func(e *editor.Editor, count int) error) CommandFunc {
+	return func(e *editor.Editor, c ExCommand) error {
+		return fn(e, 1)
+	}
+}
+
+// RegisterCommand adds an Ex command, replacing any command with the same
+// name. It lets packages that cmd cannot import provide commands. Commands
+// in this package that run other commands, directly or through events,
+// register themselves with it from an init function too: naming them in
+// the commandFuncMap literal would be an initialization cycle, since they
+// reach ExecuteCommand, which reads commandFuncMap.
+func RegisterCommand(name string, fn CommandFunc) {
+	commandFuncMap[name] = fn
 }
 
 // processCommandInput handles a single key press when in Command mode.
 func processCommandInput(e *editor.Editor, key byte) {
+	kind := editor.HistoryCommand
+	if e.Searching() {
+		kind = editor.HistorySearch
+	}
+	if EditCommandLine(e, key, kind) {
+		return
+	}
 	switch key {
 	case terminal.KeyEsc:
 		e.CurrentMode = editor.ModeNormal
 		e.CommandBuffer = ""
 	case 13:
+		if kind == editor.HistorySearch {
+			pattern := e.CommandBuffer
+			e.CommandBuffer = ""
+			e.CurrentMode = editor.ModeNormal
+			e.AddHistory(editor.HistorySearch, pattern)
+			if err := Search(e, pattern, e.CommandPrompt == '?', 1); err != nil {
+				e.SetStatusMessage(err.Error())
+			}
+			return
+		}
 		originalMode := e.CurrentMode
+		e.AddHistory(editor.HistoryCommand, e.CommandBuffer)
 		executeCommand(e)
 		if e.CurrentMode == originalMode {
 			e.CurrentMode = editor.ModeNormal
 		}
-	case 127, 8:
-		if len(e.CommandBuffer) > 0 {
-			e.CommandBuffer = e.CommandBuffer[:len(e.CommandBuffer)-1]
-		}
-	default:
-		if key >= 32 && key <= 126 {
-			e.CommandBuffer += string(key)
-		}
 	}
 }
 
-// executeCommand looks up and runs the command in the command buffer.
+// executeCommand runs the command in the command buffer.
 func executeCommand(e *editor.Editor) {
 	command := e.CommandBuffer
 	e.CommandBuffer = ""
 
-	if cmdFunc, exists := commandFuncMap[command]; exists {
-		cmdFunc(e)
-	} else {
-		e.SetStatusMessage(fmt.Sprintf("Unknown command: %s", command))
-		e.CurrentMode = editor.ModeNormal // If command unknown, explicitly return to Normal mode
+	if err := ExecuteCommand(e, command); err != nil {
+		e.SetStatusMessage(err.Error())
+		e.CurrentMode = editor.ModeNormal // If command failed, explicitly return to Normal mode
 	}
 }
 
+// ExecuteCommand parses and runs a single Ex command line, with or without
+// the leading ':'.
+func ExecuteCommand(e *editor.Editor, line string) error {
+	c, err := parseCommandLine(e, line)
+	if err != nil {
+		return err
+	}
+	if c.Name == "" {
+		if c.HasRange { // A bare range jumps to its last line
+			e.PushJump()
+			e.CursorY = c.Range.End
+			e.CursorX = editor.FirstNonBlank(e.EditorContent[e.CursorY])
+		}
+		return nil
+	}
+
+	if c.Bang {
+		if cmdFunc, exists := commandFuncMap[c.Name+"!"]; exists {
+			return cmdFunc(e, c)
+		}
+	}
+	if cmdFunc, exists := commandFuncMap[c.Name]; exists {
+		return cmdFunc(e, c)
+	}
+	return fmt.Errorf("Unknown command: %s", strings.TrimSpace(line))
+}
+
+// parseCommandLine splits a command line into its range, name, "!" and
+// arguments. Names are a run of letters or a single symbol such as "!".
+func parseCommandLine(e *editor.Editor, line string) (ExCommand, error) {
+	line = strings.TrimLeft(line, " :")
+	r, hasRange, rest, err := parseRange(e, line)
+	if err != nil {
+		return ExCommand{}, err
+	}
+	rest = strings.TrimLeft(rest, " ")
+
+	i := 0
+	for i < len(rest) && (rest[i] >= 'a' && rest[i] <= 'z' || rest[i] >= 'A' && rest[i] <= 'Z') {
+		i++
+	}
+	if i == 0 && rest != "" && strings.IndexByte("!&<>=~", rest[0]) >= 0 {
+		i = 1
+	}
+	c := ExCommand{Name: rest[:i], Range: r, HasRange: hasRange}
+	c.LineZero = hasRange && strings.TrimRight(line[:len(line)-len(rest)], " ") == "0"
+	rest = rest[i:]
+	if i > 0 && c.Name[0] != '!' && strings.HasPrefix(rest, "!") {
+		c.Bang = true
+		rest = rest[1:]
+	}
+	c.Args = strings.TrimLeft(rest, " ")
+	return c, nil
+}
+
 // SaveFile writes the editor content or prompts for filename if needed.
 // Returns true if a save was attempted (success or error), false if prompt was initiated.
 func SaveFile(e *editor.Editor) bool {
 	if e.Filename == "" {
-		e.CurrentMode = editor.ModeFileNamePrompt
-		e.CommandBuffer = ""
+		e.StartCommandLine(editor.ModeFileNamePrompt, "")
 		e.SetStatusMessage("Save file as: ")
 		e.PromptOriginCommand = "w"
 		return false
 	}
+	_ = writeBuffer(e)
+	return true
+}
 
-	content := e.ContentAsString()
-	err := os.WriteFile(e.Filename, []byte(content), 0644)
-	if err != nil {
-		e.SetStatusMessage(fmt.Sprintf("Error saving file: %v", err))
-	} else {
+// writeFile implements :w, which saves the file or prompts for its name,
+// returning any error writing it.
+func writeFile(e *editor.Editor) error {
+	if e.Filename == "" {
+		SaveFile(e)
+		return nil
+	}
+	return writeBuffer(e)
+}
+
+// writeBuffer writes the buffer to its file. The result is shown in the
+// status bar, and an error writing the file also returned.
+func writeBuffer(e *editor.Editor) error {
+	e.Fire(editor.EventBufWritePre)
+
+	// A formatter that fails leaves the buffer as it is, and it is saved
+	// unformatted. A buffer that may not be changed is saved as it is.
+	var formatErr error
+	modifiable := e.BoolOption("modifiable")
+	if e.BoolOption("formatonsave") && modifiable {
+		if formatErr = FormatBuffer(e); errors.Is(formatErr, errNoFormatter) {
+			formatErr = nil
+		}
+	}
+	if e.BoolOption("trimtrailingwhitespace") && modifiable {
+		e.TrimTrailingWhitespace()
+	}
+	err := os.WriteFile(e.Filename, e.EncodedContent(), 0644)
+	switch {
+	case err != nil:
+		err = fmt.Errorf("Error saving file: %v", err)
+		e.SetStatusMessage(err.Error())
+	case formatErr != nil:
+		e.SetStatusMessage(fmt.Sprintf("File '%s' saved without formatting: %v", e.Filename, formatErr))
+		e.IsDirty = false
+	default:
 		e.SetStatusMessage(fmt.Sprintf("File '%s' saved successfully.", e.Filename))
 		e.IsDirty = false
 	}
-	return true
+	if err == nil {
+		e.Fire(editor.EventBufWritePost)
+	}
+	return err
 }
 
-// saveAndQuit saves the file and then signals quit, only if save was attempted.
-func saveAndQuit(e *editor.Editor) {
-	attemptedSave := SaveFile(e)
-	if attemptedSave {
-		// Only quit if SaveFile actually tried to save (had filename)
-		// and wasn't blocked by IsDirty flag itself.
-		QuitEditor(e)
-	} else {
-		// SaveFile returned false, meaning it entered prompt mode.
-		// Mark that :wq triggered this prompt.
+// saveAndQuit saves the file and then signals quit, only if the file was
+// written.
+func saveAndQuit(e *editor.Editor) error {
+	if e.Filename == "" {
+		// SaveFile enters prompt mode. Mark that :wq triggered this prompt.
+		SaveFile(e)
 		e.PromptOriginCommand = "wq"
+		return nil
 	}
+	if err := writeBuffer(e); err != nil {
+		return err
+	}
+	QuitEditor(e)
+	return nil
 }
 
 // QuitEditor signals the main loop to exit if buffer isn't dirty.
 func QuitEditor(e *editor.Editor) {
+	e.Fire(editor.EventQuitPre)
 	if e.IsDirty {
 		e.SetStatusMessage("Unsaved changes! Use :q! or :wq to save and quit.")
 		return
 	}
+	if n := len(e.ArgList) - e.ArgIndex - 1; n > 0 && !e.QuitWarned {
+		e.SetStatusMessage(fmt.Sprintf("%d more files to edit", n))
+		e.QuitWarned = true
+		return
+	}
 	e.ShouldQuit = true
 }
 
 // quitWithoutSaving signals the main loop to exit regardless of dirty state.
 func quitWithoutSaving(e *editor.Editor) {
+	e.Fire(editor.EventQuitPre)
 	e.ShouldQuit = true
 }
 
@@ -1,10 +1,14 @@
 package cmd
 
 import (
+	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"goedit/editor"
+	"goedit/terminal"
 )
 
 // Helper to create a test editor instance
@@ -123,3 +127,1104 @@ func TestSaveFile(t *testing.T) {
 		// Note: Cannot assert exact success message content as it includes filename
 	})
 }
+
+func TestParseCommandLine(t *testing.T) {
+	tests := []struct {
+		name          string
+		line          string
+		cursorY       int
+		expectedErr   bool
+		expectedName  string
+		expectedBang  bool
+		expectedArgs  string
+		expectedRange LineRange
+		expectedHas   bool
+	}{
+		{name: "Plain command", line: "w", cursorY: 2, expectedName: "w", expectedRange: LineRange{2, 2}},
+		{name: "Bang", line: "q!", expectedName: "q", expectedBang: true},
+		{name: "Arguments", line: "normal dd", expectedName: "normal", expectedArgs: "dd"},
+		{name: "Leading colon and spaces", line: " :wq", expectedName: "wq"},
+		{name: "Whole buffer", line: "%normal x", expectedName: "normal", expectedArgs: "x", expectedRange: LineRange{0, 4}, expectedHas: true},
+		{name: "Numeric range", line: "2,4norm x", expectedName: "norm", expectedArgs: "x", expectedRange: LineRange{1, 3}, expectedHas: true},
+		{name: "Current and last line", line: ".,$d", cursorY: 1, expectedName: "d", expectedRange: LineRange{1, 4}, expectedHas: true},
+		{name: "Offsets", line: ".+1,$-1d", cursorY: 1, expectedName: "d", expectedRange: LineRange{2, 3}, expectedHas: true},
+		{name: "Missing second address", line: "3,d", cursorY: 0, expectedName: "d", expectedRange: LineRange{0, 2}, expectedHas: true},
+		{name: "Semicolon is relative to first address", line: "2;+1d", expectedName: "d", expectedRange: LineRange{1, 2}, expectedHas: true},
+		{name: "Backwards range is swapped", line: "4,2d", expectedName: "d", expectedRange: LineRange{1, 3}, expectedHas: true},
+		{name: "Bare range", line: "3", expectedRange: LineRange{2, 2}, expectedHas: true},
+		{name: "Symbol command", line: "%!sort", expectedName: "!", expectedArgs: "sort", expectedRange: LineRange{0, 4}, expectedHas: true},
+		{name: "Mark range", line: "'a,'bd", expectedName: "d", expectedRange: LineRange{1, 3}, expectedHas: true},
+		{name: "Unset mark", line: "'z,'bd", expectedErr: true},
+		{name: "Range past end", line: "1,9d", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(false)
+			ed.EditorContent = []string{"1", "2", "3", "4", "5"}
+			ed.CursorY = tt.cursorY
+			ed.SetMark('a', editor.Position{Line: 1})
+			ed.SetMark('b', editor.Position{Line: 3})
+
+			c, err := parseCommandLine(ed, tt.line)
+
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("Expected error %t, got %v", tt.expectedErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if c.Name != tt.expectedName {
+				t.Errorf("Expected Name %q, got %q", tt.expectedName, c.Name)
+			}
+			if c.Bang != tt.expectedBang {
+				t.Errorf("Expected Bang %t, got %t", tt.expectedBang, c.Bang)
+			}
+			if c.Args != tt.expectedArgs {
+				t.Errorf("Expected Args %q, got %q", tt.expectedArgs, c.Args)
+			}
+			if c.Range != tt.expectedRange {
+				t.Errorf("Expected Range %v, got %v", tt.expectedRange, c.Range)
+			}
+			if c.HasRange != tt.expectedHas {
+				t.Errorf("Expected HasRange %t, got %t", tt.expectedHas, c.HasRange)
+			}
+		})
+	}
+}
+
+func TestExecuteCommand(t *testing.T) {
+	t.Run("Unknown command", func(t *testing.T) {
+		ed := newTestEditor(false)
+		err := ExecuteCommand(ed, "bogus")
+		if err == nil || err.Error() != "Unknown command: bogus" {
+			t.Errorf("Expected unknown command error, got %v", err)
+		}
+	})
+
+	t.Run("Bare range moves the cursor", func(t *testing.T) {
+		ed := newTestEditor(false)
+		ed.EditorContent = []string{"a", "  b", "c"}
+		if err := ExecuteCommand(ed, ":2"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ed.CursorY != 1 || ed.CursorX != 2 {
+			t.Errorf("Expected cursor at (2, 1), got (%d, %d)", ed.CursorX, ed.CursorY)
+		}
+	})
+
+	t.Run("Bare range past the end goes to the last line", func(t *testing.T) {
+		ed := newTestEditor(false)
+		ed.EditorContent = []string{"a", "  b", "c"}
+		if err := ExecuteCommand(ed, "99"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ed.CursorY != 2 {
+			t.Errorf("Expected cursor on line 2, got %d", ed.CursorY)
+		}
+		if err := ExecuteCommand(ed, "99d"); err == nil {
+			t.Errorf("Expected an error for a command with a range past the end")
+		}
+	})
+
+	t.Run("Registered command receives arguments", func(t *testing.T) {
+		var got ExCommand
+		RegisterCommand("testcmd", func(e *editor.Editor, c ExCommand) error {
+			got = c
+			return nil
+		})
+		defer delete(commandFuncMap, "testcmd")
+
+		ed := newTestEditor(false)
+		if err := ExecuteCommand(ed, "testcmd! some args"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !got.Bang || got.Args != "some args" {
+			t.Errorf("Expected bang and args %q, got %+v", "some args", got)
+		}
+	})
+}
+
+func TestSetCommand(t *testing.T) {
+	tests := []struct {
+		name        string
+		commands    []string
+		expectedNu  bool
+		expectedRnu bool
+		expectedScl string
+		expectError bool
+	}{
+		{name: "Turn on", commands: []string{"set number"}, expectedNu: true, expectedScl: "auto"},
+		{name: "Short name", commands: []string{"se nu rnu"}, expectedNu: true, expectedRnu: true, expectedScl: "auto"},
+		{name: "Turn off", commands: []string{"set nu", "set nonu"}, expectedScl: "auto"},
+		{name: "Toggle with bang", commands: []string{"set nu!"}, expectedNu: true, expectedScl: "auto"},
+		{name: "Toggle with inv", commands: []string{"set nu", "set invnu"}, expectedScl: "auto"},
+		{name: "String value", commands: []string{"set signcolumn=yes"}, expectedScl: "yes"},
+		{name: "Invalid value", commands: []string{"set scl=maybe"}, expectedScl: "auto", expectError: true},
+		{name: "Value for boolean", commands: []string{"set nu=1"}, expectedScl: "auto", expectError: true},
+		{name: "Unknown option", commands: []string{"set bogus"}, expectedScl: "auto", expectError: true},
+		{name: "Reset to default", commands: []string{"set nu scl=no", "set nu& scl&"}, expectedScl: "auto"},
+		{name: "Colon value", commands: []string{"set scl:no"}, expectedScl: "no"},
+		{name: "Local value", commands: []string{"setlocal nu"}, expectedNu: true, expectedScl: "auto"},
+		{name: "Global value keeps local", commands: []string{"setglobal nu"}, expectedScl: "auto"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(false)
+			var err error
+			for _, command := range tt.commands {
+				err = ExecuteCommand(ed, command)
+			}
+			if (err != nil) != tt.expectError {
+				t.Errorf("Expected error %t, got %v", tt.expectError, err)
+			}
+			if nu := ed.BoolOption("number"); nu != tt.expectedNu {
+				t.Errorf("Expected number %t, got %t", tt.expectedNu, nu)
+			}
+			if rnu := ed.BoolOption("relativenumber"); rnu != tt.expectedRnu {
+				t.Errorf("Expected relativenumber %t, got %t", tt.expectedRnu, rnu)
+			}
+			if scl := ed.StringOption("signcolumn"); scl != tt.expectedScl {
+				t.Errorf("Expected signcolumn %q, got %q", tt.expectedScl, scl)
+			}
+		})
+	}
+}
+
+func TestSetCommandValues(t *testing.T) {
+	tests := []struct {
+		name            string
+		commands        []string
+		expectedTimeout int
+		expectedMessage string
+	}{
+		{name: "Show bool", commands: []string{"set nu?"}, expectedTimeout: 5000, expectedMessage: "nonumber"},
+		{name: "Show string", commands: []string{"set scl"}, expectedTimeout: 5000, expectedMessage: "signcolumn=auto"},
+		{name: "Show several", commands: []string{"set nu", "set nu? stm?"}, expectedTimeout: 5000, expectedMessage: "number statustimeout=5000"},
+		{name: "Show changed", commands: []string{"set rnu stm=10", "set"}, expectedTimeout: 10, expectedMessage: "relativenumber statustimeout=10"},
+		{name: "Set number", commands: []string{"set stm=100"}, expectedTimeout: 100},
+		{name: "Add", commands: []string{"set stm+=100"}, expectedTimeout: 5100},
+		{name: "Subtract", commands: []string{"set stm-=1000"}, expectedTimeout: 4000},
+		{name: "Multiply", commands: []string{"set stm^=2"}, expectedTimeout: 10000},
+		{name: "Invalid number", commands: []string{"set stm=soon"}, expectedTimeout: 5000, expectedMessage: "Number required after =: statustimeout=soon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(false)
+			for _, command := range tt.commands {
+				ed.StatusMessage = ""
+				if err := ExecuteCommand(ed, command); err != nil {
+					ed.SetStatusMessage(err.Error())
+				}
+			}
+			if v := ed.IntOption("statustimeout"); v != tt.expectedTimeout {
+				t.Errorf("Expected statustimeout %d, got %d", tt.expectedTimeout, v)
+			}
+			if ed.StatusMessage != tt.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tt.expectedMessage, ed.StatusMessage)
+			}
+		})
+	}
+}
+
+func TestModifyListOption(t *testing.T) {
+	def := &editor.OptionDef{Name: "list", Type: editor.OptionList}
+	tests := []struct {
+		op       byte
+		arg      string
+		expected string
+	}{
+		{op: '+', arg: "c", expected: "a,b,c"},
+		{op: '+', arg: "a", expected: "b,a"},
+		{op: '^', arg: "c", expected: "c,a,b"},
+		{op: '-', arg: "a", expected: "b"},
+	}
+	for _, tt := range tests {
+		v, err := modifyOption(def, "a,b", tt.op, tt.arg)
+		if err != nil || v != tt.expected {
+			t.Errorf("Expected a,b %c= %s to give %q, got %v (%v)", tt.op, tt.arg, tt.expected, v, err)
+		}
+	}
+}
+
+func TestSplitSetArgs(t *testing.T) {
+	args := splitSetArgs(`nu  scl=yes\ no  stm?`)
+	expected := []string{"nu", "scl=yes no", "stm?"}
+	if len(args) != len(expected) {
+		t.Fatalf("Expected %q, got %q", expected, args)
+	}
+	for i := range expected {
+		if args[i] != expected[i] {
+			t.Errorf("Expected arg %d %q, got %q", i, expected[i], args[i])
+		}
+	}
+}
+
+func TestSourceFile(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "config")
+	content := "\" comment\n\nset nu\n:set bogus\nset rnu\n"
+	if err := os.WriteFile(config, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("Runs every line", func(t *testing.T) {
+		ed := newTestEditor(false)
+		err := ExecuteCommand(ed, "source "+config)
+		if err == nil || err.Error() != config+" line 4: Unknown option: bogus" {
+			t.Errorf("Expected error for line 4, got %v", err)
+		}
+		if !ed.BoolOption("number") || !ed.BoolOption("relativenumber") {
+			t.Error("Expected commands before and after the error to run")
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		ed := newTestEditor(false)
+		if err := ExecuteCommand(ed, "so "+filepath.Join(dir, "missing")); err == nil {
+			t.Error("Expected error for missing file")
+		}
+	})
+
+	t.Run("Recursive source stops", func(t *testing.T) {
+		loop := filepath.Join(dir, "loop")
+		if err := os.WriteFile(loop, []byte("source "+loop+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ed := newTestEditor(false)
+		if err := SourceFile(ed, loop); err == nil {
+			t.Error("Expected error for endless recursion")
+		}
+		if ed.SourceDepth != 0 {
+			t.Errorf("Expected SourceDepth 0 afterwards, got %d", ed.SourceDepth)
+		}
+	})
+
+	t.Run("Line too long", func(t *testing.T) {
+		long := filepath.Join(dir, "long")
+		text := "set nu\n\" " + strings.Repeat("x", 70*1024) + "\nset rnu\n"
+		if err := os.WriteFile(long, []byte(text), 0644); err != nil {
+			t.Fatal(err)
+		}
+		ed := newTestEditor(false)
+		err := SourceFile(ed, long)
+		if err == nil || !strings.HasPrefix(err.Error(), long+" line 2: ") {
+			t.Errorf("Expected an error for line 2, got %v", err)
+		}
+		if !ed.BoolOption("number") {
+			t.Error("Expected the line before to run")
+		}
+	})
+
+	t.Run("Config path", func(t *testing.T) {
+		t.Setenv("XDG_CONFIG_HOME", dir)
+		if p := ConfigPath(); p != filepath.Join(dir, "goedit", "config") {
+			t.Errorf("Expected config under XDG_CONFIG_HOME, got %q", p)
+		}
+		ed := newTestEditor(false)
+		if err := LoadConfig(ed); err != nil {
+			t.Errorf("Expected missing config to be ignored, got %v", err)
+		}
+	})
+}
+
+func TestShellCommands(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "lines.txt")
+	if err := os.WriteFile(file, []byte("one\r\ntwo\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name            string
+		command         string
+		expectedContent []string
+		expectedCursorY int
+		expectedOutput  []string
+		expectedErr     string
+	}{
+		{name: "Filter whole buffer", command: "%!sort", expectedContent: []string{"a", "b", "c", "d"}, expectedCursorY: 0},
+		{name: "Filter range", command: "2,3!tr a-z A-Z", expectedContent: []string{"d", "B", "A", "c"}, expectedCursorY: 1},
+		{name: "Filter to fewer lines", command: "%!head -n 1", expectedContent: []string{"d"}, expectedCursorY: 0},
+		{name: "Failing filter", command: "%!echo oops >&2; exit 3", expectedContent: []string{"d", "b", "a", "c"}, expectedCursorY: 0, expectedErr: "Shell returned 3: oops"},
+		{name: "Show output", command: "!echo hi; echo there", expectedContent: []string{"d", "b", "a", "c"}, expectedOutput: []string{"hi", "there"}},
+		{name: "Show failure", command: "!echo hi; exit 1", expectedContent: []string{"d", "b", "a", "c"}, expectedOutput: []string{"hi", "Shell returned 1"}},
+		{name: "Expand file name", command: "!echo %", expectedContent: []string{"d", "b", "a", "c"}, expectedOutput: []string{"test.txt"}},
+		{name: "Read command", command: "2r !printf 'x\\ny\\n'", expectedContent: []string{"d", "b", "x", "y", "a", "c"}, expectedCursorY: 2},
+		{name: "Read command with bang", command: "$r!echo z", expectedContent: []string{"d", "b", "a", "c", "z"}, expectedCursorY: 4},
+		{name: "Read file", command: "1read " + file, expectedContent: []string{"d", "one", "two", "b", "a", "c"}, expectedCursorY: 1},
+		{name: "Read file above the first line", command: "0r " + file, expectedContent: []string{"one", "two", "d", "b", "a", "c"}, expectedCursorY: 0},
+		{name: "Read missing file", command: "r " + filepath.Join(dir, "missing"), expectedContent: []string{"d", "b", "a", "c"}, expectedErr: "Can't open file " + filepath.Join(dir, "missing")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(false)
+			ed.Filename = "test.txt"
+			ed.EditorContent = []string{"d", "b", "a", "c"}
+			_ = ed.SetOption("shell", "sh")
+
+			err := ExecuteCommand(ed, tt.command)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error %q, got %v", tt.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if strings.Join(ed.EditorContent, "|") != strings.Join(tt.expectedContent, "|") {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, ed.EditorContent)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+			if strings.Join(ed.Output, "|") != strings.Join(tt.expectedOutput, "|") {
+				t.Errorf("Expected output %q, got %q", tt.expectedOutput, ed.Output)
+			}
+		})
+	}
+}
+
+func TestFormatOnSave(t *testing.T) {
+	tests := []struct {
+		name            string
+		formatter       string
+		noFormatOnSave  bool
+		initialContent  []string
+		cursorX         int
+		cursorY         int
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+		expectedStatus  string
+	}{
+		{name: "Formats before writing", formatter: "tr a-z A-Z", initialContent: []string{"abc", "def"}, cursorX: 2, cursorY: 1, expectedContent: []string{"ABC", "DEF"}, expectedCursorX: 2, expectedCursorY: 1, expectedStatus: "saved successfully"},
+		{name: "Cursor follows inserted lines", formatter: "(echo header; cat)", initialContent: []string{"abc", "def"}, cursorX: 1, cursorY: 1, expectedContent: []string{"header", "abc", "def"}, expectedCursorX: 1, expectedCursorY: 2, expectedStatus: "saved successfully"},
+		{name: "Cursor keeps place after indent", formatter: "sed 's/^ *//'", initialContent: []string{"x", "    abc"}, cursorX: 5, cursorY: 1, expectedContent: []string{"x", "abc"}, expectedCursorX: 1, expectedCursorY: 1, expectedStatus: "saved successfully"},
+		{name: "Failing formatter leaves buffer", formatter: "echo bad syntax >&2; exit 2", initialContent: []string{"abc"}, expectedContent: []string{"abc"}, expectedStatus: "saved without formatting: echo: Shell returned 2: bad syntax"},
+		{name: "Formatter without output leaves buffer", formatter: "true", initialContent: []string{"abc"}, expectedContent: []string{"abc"}, expectedStatus: "saved without formatting: true: no output"},
+		{name: "formatonsave off", formatter: "tr a-z A-Z", noFormatOnSave: true, initialContent: []string{"abc"}, expectedContent: []string{"abc"}, expectedStatus: "saved successfully"},
+		{name: "No formatter", initialContent: []string{"abc"}, expectedContent: []string{"abc"}, expectedStatus: "saved successfully"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(true)
+			ed.Filename = filepath.Join(t.TempDir(), "file.txt")
+			ed.EditorContent = tt.initialContent
+			ed.CursorX, ed.CursorY = tt.cursorX, tt.cursorY
+			_ = ed.SetOption("shell", "sh")
+			_ = ed.SetLocalOption("filetype", "text")
+			_ = ed.SetLocalOption("formatonsave", !tt.noFormatOnSave)
+			if tt.formatter != "" {
+				if err := ExecuteCommand(ed, "formatter text "+tt.formatter); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			SaveFile(ed)
+
+			if strings.Join(ed.EditorContent, "|") != strings.Join(tt.expectedContent, "|") {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, ed.EditorContent)
+			}
+			data, _ := os.ReadFile(ed.Filename)
+			if want := strings.Join(tt.expectedContent, "\n") + "\n"; string(data) != want {
+				t.Errorf("Expected file %q, got %q", want, data)
+			}
+			if ed.CursorX != tt.expectedCursorX || ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected cursor %d,%d, got %d,%d", tt.expectedCursorY, tt.expectedCursorX, ed.CursorY, ed.CursorX)
+			}
+			if !strings.Contains(ed.StatusMessage, tt.expectedStatus) {
+				t.Errorf("Expected status containing %q, got %q", tt.expectedStatus, ed.StatusMessage)
+			}
+		})
+	}
+}
+
+func TestFormatterCommand(t *testing.T) {
+	ed := newTestEditor(false)
+	if err := ExecuteCommand(ed, "formatter go gofmt"); err != nil {
+		t.Fatal(err)
+	}
+	if err := ExecuteCommand(ed, "formatter javascript prettier --stdin-filepath %"); err != nil {
+		t.Fatal(err)
+	}
+	if ed.Formatters["go"] != "gofmt" || ed.Formatters["javascript"] != "prettier --stdin-filepath %" {
+		t.Errorf("Expected formatters to be set, got %v", ed.Formatters)
+	}
+	if err := ExecuteCommand(ed, "formatter go"); err != nil || ed.StatusMessage != "go: gofmt" {
+		t.Errorf("Expected formatter to be shown, got %q (%v)", ed.StatusMessage, err)
+	}
+	if err := ExecuteCommand(ed, "formatter! go"); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ed.Formatters["go"]; ok {
+		t.Error("Expected formatter to be removed")
+	}
+	if err := ExecuteCommand(ed, "format"); err == nil {
+		t.Error("Expected error formatting without a formatter")
+	}
+}
+
+func TestAutocmdCommand(t *testing.T) {
+	ed := newTestEditor(false)
+	ed.Filename = filepath.Join(t.TempDir(), "main.go")
+	ed.EditorContent = []string{"one"}
+	for _, c := range []string{
+		"autocmd BufWritePre *.go %!tr o O",
+		"au bufwritepre,BufWritePost *.txt %!tr n N",
+		"autocmd QuitPre * set number",
+	} {
+		if err := ExecuteCommand(ed, c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+
+	if err := ExecuteCommand(ed, "autocmd BufWritePre"); err != nil {
+		t.Fatalf("autocmd BufWritePre: %v", err)
+	}
+	want := []string{"BufWritePre", "    *.go         %!tr o O", "    *.txt        %!tr n N"}
+	if strings.Join(ed.Output, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected listing %q, got %q", want, ed.Output)
+	}
+
+	_ = ed.SetOption("shell", "sh")
+	SaveFile(ed)
+	data, _ := os.ReadFile(ed.Filename)
+	if string(data) != "One\n" {
+		t.Errorf("Expected BufWritePre to change the file, got %q", data)
+	}
+	QuitEditor(ed)
+	if !ed.BoolOption("number") {
+		t.Errorf("Expected QuitPre to set number")
+	}
+
+	if err := ExecuteCommand(ed, "autocmd! BufWritePre *.go"); err != nil {
+		t.Fatalf("autocmd!: %v", err)
+	}
+	if err := ExecuteCommand(ed, "autocmd * *.go"); err == nil || err.Error() != "No matching autocommands" {
+		t.Errorf("Expected no *.go autocommands, got %v", err)
+	}
+	if err := ExecuteCommand(ed, "autocmd!"); err != nil {
+		t.Fatalf("autocmd!: %v", err)
+	}
+	if err := ExecuteCommand(ed, "autocmd"); err == nil {
+		t.Errorf("Expected no autocommands left")
+	}
+	if err := ExecuteCommand(ed, "autocmd BufOpen * set number"); err == nil || err.Error() != "No such event: BufOpen" {
+		t.Errorf("Expected unknown event error, got %v", err)
+	}
+
+	// A failing command is reported with its event.
+	_ = ExecuteCommand(ed, "autocmd BufWritePost * nosuch")
+	SaveFile(ed)
+	if !strings.Contains(ed.StatusMessage, "BufWritePost Autocommands") || !strings.Contains(ed.StatusMessage, "Unknown command: nosuch") {
+		t.Errorf("Expected the error in the status bar, got %q", ed.StatusMessage)
+	}
+}
+
+func TestCommandLineKeys(t *testing.T) {
+	ed := newTestEditor(false)
+	ed.EditorContent = []string{"one"}
+	type step struct {
+		keys   string
+		buffer string
+		cursor int
+	}
+	ed.StartCommandLine(editor.ModeCommand, "")
+	for _, s := range []step{
+		{"set nu", "set nu", 6},
+		{string([]byte{terminal.KeyArrowLeft, terminal.KeyArrowLeft}), "set nu", 4},
+		{"no", "set nonu", 6},
+		{string([]byte{keyCtrlE}), "set nonu", 8},
+		{string([]byte{keyCtrlW}), "set ", 4},
+		{string([]byte{keyCtrlA, terminal.KeyArrowRight}), "set ", 1},
+		{string([]byte{127}), "et ", 0},
+		{string([]byte{keyCtrlE, keyCtrlU}), "", 0},
+	} {
+		for i := 0; i < len(s.keys); i++ {
+			processCommandInput(ed, s.keys[i])
+		}
+		if ed.CommandBuffer != s.buffer || ed.CommandCursor() != s.cursor {
+			t.Errorf("After %q: expected %q with cursor %d, got %q with cursor %d", s.keys, s.buffer, s.cursor, ed.CommandBuffer, ed.CommandCursor())
+		}
+	}
+
+	for _, key := range []byte("set number\r") {
+		processCommandInput(ed, key)
+	}
+	if !ed.BoolOption("number") || ed.CurrentMode != editor.ModeNormal {
+		t.Errorf("Expected number set and Normal mode")
+	}
+	ed.StartCommandLine(editor.ModeCommand, "")
+	processCommandInput(ed, terminal.KeyArrowUp)
+	if ed.CommandBuffer != "set number" {
+		t.Errorf("Expected the last command from history, got %q", ed.CommandBuffer)
+	}
+}
+
+func TestCommandLineCompletion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"alpha.txt", "alto.txt", "beta.txt"} {
+		_ = os.WriteFile(filepath.Join(dir, name), nil, 0644)
+	}
+	tests := []struct {
+		text     string
+		expected string
+		menu     []string
+	}{
+		{"undol", "undol", nil},
+		{"autoc", "autocmd", nil},
+		{"1,2und", "1,2undo", nil},
+		{"se", "se", []string{"se", "set", "setg", "setglobal", "setl", "setlocal"}},
+		{"set relat", "set relativenumber", nil},
+		{"set nonumb", "set nonumber", nil},
+		{"set tabstop=", "set tabstop=", nil},
+		{"au BufWriteP", "au BufWritePre", []string{"BufWritePre", "BufWritePost"}},
+		{"au insertl", "au InsertLeave", nil},
+		{"au BufRead,filet", "au BufRead,FileType", nil},
+		{"w " + dir + "/al", "w " + dir + "/alpha.txt", []string{dir + "/alpha.txt", dir + "/alto.txt"}},
+		{"r " + dir + "/b", "r " + dir + "/beta.txt", nil},
+	}
+	for _, tt := range tests {
+		ed := newTestEditor(false)
+		ed.StartCommandLine(editor.ModeCommand, tt.text)
+		processCommandInput(ed, keyTab)
+		if tt.menu != nil {
+			if ed.Wildmenu == nil || strings.Join(ed.Wildmenu.Items, ",") != strings.Join(tt.menu, ",") {
+				t.Errorf("%q: expected menu %q, got %+v", tt.text, tt.menu, ed.Wildmenu)
+				continue
+			}
+			// The first item is put in at once.
+			tt.expected = tt.text[:ed.Wildmenu.Start] + tt.menu[0]
+		}
+		if ed.CommandBuffer != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.text, tt.expected, ed.CommandBuffer)
+		}
+	}
+
+	// Tab and Ctrl-N go forward through the menu, Ctrl-P back, and other
+	// keys close it.
+	ed := newTestEditor(false)
+	ed.StartCommandLine(editor.ModeCommand, "w "+dir+"/al")
+	processCommandInput(ed, keyTab)
+	processCommandInput(ed, keyTab)
+	if ed.CommandBuffer != "w "+dir+"/alto.txt" {
+		t.Errorf("Expected the second item, got %q", ed.CommandBuffer)
+	}
+	processCommandInput(ed, keyCtrlP)
+	processCommandInput(ed, keyCtrlP)
+	if ed.CommandBuffer != "w "+dir+"/al" {
+		t.Errorf("Expected the original text, got %q", ed.CommandBuffer)
+	}
+	processCommandInput(ed, 'x')
+	if ed.Wildmenu != nil || ed.CommandBuffer != "w "+dir+"/alx" {
+		t.Errorf("Expected the menu closed and x typed, got %q", ed.CommandBuffer)
+	}
+}
+
+func TestEditCommand(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.go"), filepath.Join(dir, "b.txt")
+	_ = os.WriteFile(a, []byte("package a\n\nfunc A() {}\n"), 0644)
+
+	ed := newTestEditor(false)
+	var events []string
+	for _, name := range []string{editor.EventBufRead, editor.EventBufNewFile, editor.EventFileType} {
+		ed.On(name, func(ev editor.Event) { events = append(events, ev.Name+":"+ev.Match) })
+	}
+	if err := ExecuteCommand(ed, "edit "+a); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if len(ed.EditorContent) != 3 || ed.StringOption("filetype") != "go" {
+		t.Errorf("Expected 3 lines of go, got %d lines of %q", len(ed.EditorContent), ed.StringOption("filetype"))
+	}
+	ed.CursorY, ed.CursorX = 2, 5
+
+	ed.InsertChar('x')
+	if err := ExecuteCommand(ed, "e "+b); err == nil {
+		t.Errorf("Expected an error for unsaved changes")
+	}
+	if err := ExecuteCommand(ed, "e! "+b); err != nil {
+		t.Fatalf("e!: %v", err)
+	}
+	if ed.Filename != b || ed.IsDirty || len(ed.EditorContent) != 1 || ed.CursorY != 0 {
+		t.Errorf("Expected an empty new buffer for %s, got %q in %s", b, ed.EditorContent, ed.Filename)
+	}
+
+	// Going back to a file puts the cursor where it was left.
+	if err := ExecuteCommand(ed, "e "+a); err != nil {
+		t.Fatalf("e: %v", err)
+	}
+	if ed.CursorY != 2 || ed.CursorX != 6 {
+		t.Errorf("Expected cursor 2,6, got %d,%d", ed.CursorY, ed.CursorX)
+	}
+	want := "BufRead:" + a + ",FileType:go,BufNewFile:" + b + ",BufRead:" + a + ",FileType:go"
+	if got := strings.Join(events, ","); got != want {
+		t.Errorf("Expected events %q, got %q", want, got)
+	}
+}
+
+func TestMksession(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	other := filepath.Join(dir, "b c.txt")
+	session := filepath.Join(dir, "s.vim")
+	_ = os.WriteFile(file, []byte("one\ntwo\n"), 0644)
+	_ = os.WriteFile(other, []byte("three\n"), 0644)
+
+	ed := newTestEditor(false)
+	ed.ArgList = []string{other, file}
+	if err := EditArg(ed, 1); err != nil {
+		t.Fatalf("EditArg: %v", err)
+	}
+	_ = ed.SetOption("number", true)
+	_ = ed.SetOption("shell", `/bin/sh -c`)
+	_ = ed.SetLocalOption("tabstop", 3)
+	ed.CursorY = 1
+	if err := ExecuteCommand(ed, "mksession "+session); err != nil {
+		t.Fatalf("mksession: %v", err)
+	}
+	data, _ := os.ReadFile(session)
+	want := `" goedit session file
+set number
+set shell=/bin/sh\ -c
+args ` + strings.ReplaceAll(other, " ", `\ `) + " " + file + `
+argument 2
+setlocal endofline
+setlocal fileencoding=utf-8
+setlocal filetype=
+setlocal tabstop=3
+cursor 2 1
+`
+	if string(data) != want {
+		t.Errorf("Expected session file:\n%s\ngot:\n%s", want, data)
+	}
+	if err := ExecuteCommand(ed, "mksession "+session); err == nil {
+		t.Errorf("Expected an error for an existing file")
+	}
+
+	restored := newTestEditor(false)
+	if err := LoadSession(restored, session); err != nil {
+		t.Fatalf("LoadSession: %v", err)
+	}
+	if restored.Filename != file || restored.CursorY != 1 || !restored.BoolOption("number") ||
+		restored.IntOption("tabstop") != 3 || restored.StringOption("shell") != "/bin/sh -c" ||
+		!slices.Equal(restored.ArgList, ed.ArgList) || restored.ArgIndex != 1 {
+		t.Errorf("Expected the session restored, got %s line %d, arguments %q at %d",
+			restored.Filename, restored.CursorY, restored.ArgList, restored.ArgIndex)
+	}
+}
+
+func TestArgList(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"a.txt", "b.txt", "c.txt"} {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, []byte(name+"\n"), 0644)
+		files = append(files, path)
+	}
+	ed := newTestEditor(false)
+	ed.ArgList = files
+	if err := EditArg(ed, 0); err != nil {
+		t.Fatalf("EditArg: %v", err)
+	}
+
+	steps := []struct {
+		command string
+		file    int
+		err     string
+	}{
+		{"next", 1, ""},
+		{"n", 2, ""},
+		{"next", 2, "Cannot go beyond last file"},
+		{"prev", 1, ""},
+		{"first", 0, ""},
+		{"N", 0, "Cannot go before first file"},
+		{"last", 2, ""},
+		{"rewind", 0, ""},
+	}
+	for _, s := range steps {
+		err := ExecuteCommand(ed, s.command)
+		if s.err == "" && err != nil || s.err != "" && (err == nil || err.Error() != s.err) {
+			t.Errorf("%s: expected error %q, got %v", s.command, s.err, err)
+		}
+		if ed.ArgIndex != s.file || ed.Filename != files[s.file] {
+			t.Errorf("%s: expected file %d, got %d (%s)", s.command, s.file, ed.ArgIndex, ed.Filename)
+		}
+	}
+
+	ed.InsertChar('x')
+	if err := ExecuteCommand(ed, "next"); err == nil {
+		t.Errorf("Expected an error for unsaved changes")
+	}
+	if err := ExecuteCommand(ed, "next!"); err != nil || ed.ArgIndex != 1 {
+		t.Errorf("Expected next! to go on, got %v", err)
+	}
+	_ = ExecuteCommand(ed, "args")
+	if want := files[0] + " [" + files[1] + "] " + files[2]; ed.StatusMessage != want {
+		t.Errorf("Expected %q, got %q", want, ed.StatusMessage)
+	}
+
+	// :q warns once about the files not edited yet.
+	QuitEditor(ed)
+	if ed.ShouldQuit || ed.StatusMessage != "1 more files to edit" {
+		t.Errorf("Expected a warning, got %q", ed.StatusMessage)
+	}
+	QuitEditor(ed)
+	if !ed.ShouldQuit {
+		t.Errorf("Expected the second :q to quit")
+	}
+
+	// :args with files replaces the list and edits the first.
+	ed.InsertChar('x')
+	if err := ExecuteCommand(ed, "args "+files[2]+" "+files[0]); err == nil {
+		t.Errorf("Expected an error for unsaved changes")
+	}
+	if err := ExecuteCommand(ed, "args! "+files[2]+" "+files[0]); err != nil ||
+		!slices.Equal(ed.ArgList, []string{files[2], files[0]}) || ed.ArgIndex != 0 || ed.Filename != files[2] {
+		t.Errorf("Expected the new argument list, got %v, %q at %d", err, ed.ArgList, ed.ArgIndex)
+	}
+}
+
+func TestCursorCommand(t *testing.T) {
+	ed := newTestEditor(false)
+	ed.EditorContent = []string{"one", "two"}
+	for _, tt := range []struct {
+		args string
+		x, y int
+	}{
+		{"2 3", 2, 1},
+		{"1", 0, 0},
+		{"9 9", 3, 1}, // Kept within the buffer
+	} {
+		if err := ExecuteCommand(ed, "cursor "+tt.args); err != nil || ed.CursorX != tt.x || ed.CursorY != tt.y {
+			t.Errorf(":cursor %s: expected %d,%d, got %d,%d, %v", tt.args, tt.x, tt.y, ed.CursorX, ed.CursorY, err)
+		}
+	}
+	for _, args := range []string{"", "0", "1 x", "1 2 3"} {
+		if err := ExecuteCommand(ed, "cursor "+args); err == nil {
+			t.Errorf(":cursor %s: expected an error", args)
+		}
+	}
+}
+
+func TestBufferNameCompletion(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"alpha.txt", "beta.txt", "beta2.txt"} {
+		files = append(files, filepath.Join(dir, name))
+	}
+	ed := newTestEditor(false)
+	ed.ArgList = files
+
+	// Tab completes buffer names, by their file names too.
+	for _, tt := range []struct{ text, expected string }{
+		{"b " + dir + "/al", "b " + files[0]},
+		{"b alp", "b " + files[0]},
+	} {
+		ed.StartCommandLine(editor.ModeCommand, tt.text)
+		processCommandInput(ed, keyTab)
+		if ed.CommandBuffer != tt.expected {
+			t.Errorf("%q: expected %q, got %q", tt.text, tt.expected, ed.CommandBuffer)
+		}
+	}
+	ed.StartCommandLine(editor.ModeCommand, "b bet")
+	processCommandInput(ed, keyTab)
+	if ed.Wildmenu == nil || !slices.Equal(ed.Wildmenu.Items, files[1:3]) {
+		t.Errorf("Expected a menu of %q, got %+v", files[1:3], ed.Wildmenu)
+	}
+}
+
+func TestBufferCommands(t *testing.T) {
+	dir := t.TempDir()
+	var files []string
+	for _, name := range []string{"alpha.txt", "beta.txt", "beta2.txt", "other.txt"} {
+		path := filepath.Join(dir, name)
+		_ = os.WriteFile(path, []byte(name+"\n"), 0644)
+		files = append(files, path)
+	}
+	ed := newTestEditor(false)
+	ed.ArgList = files[:3]
+	if err := EditArg(ed, 0); err != nil {
+		t.Fatalf("EditArg: %v", err)
+	}
+
+	steps := []struct {
+		command string
+		file    string
+		err     string
+	}{
+		{"argument 3", files[2], ""},
+		{"argu", files[2], ""},
+		{"argument 4", files[2], "Cannot go beyond last file"},
+		{"b 2", files[1], ""},
+		{"b alpha", files[0], ""},
+		{"buffer beta", files[0], "More than one match for beta"},
+		{"b " + files[2], files[2], ""},
+		{"b gamma", files[2], "No matching buffer for gamma"},
+		{"b 9", files[2], "Buffer 9 does not exist"},
+		{"e " + files[3], files[3], ""},
+		{"b 4", files[3], ""}, // The file edited outside the argument list
+		{"b 1", files[0], ""},
+	}
+	for _, s := range steps {
+		err := ExecuteCommand(ed, s.command)
+		if s.err == "" && err != nil || s.err != "" && (err == nil || err.Error() != s.err) {
+			t.Errorf("%s: expected error %q, got %v", s.command, s.err, err)
+		}
+		if ed.Filename != s.file {
+			t.Errorf("%s: expected %s, got %s", s.command, s.file, ed.Filename)
+		}
+	}
+
+	ed.InsertChar('x')
+	if err := ExecuteCommand(ed, "b 1"); err == nil {
+		t.Errorf("Expected an error for unsaved changes")
+	}
+	if err := ExecuteCommand(ed, "b! 1"); err != nil || ed.Filename != files[0] {
+		t.Errorf("Expected b! to edit %s, got %s, %v", files[0], ed.Filename, err)
+	}
+}
+
+func TestReadonlyWrite(t *testing.T) {
+	ed := newTestEditor(true)
+	ed.Filename = filepath.Join(t.TempDir(), "a.txt")
+	_ = ed.SetOption("readonly", true)
+	if err := ExecuteCommand(ed, "w"); err == nil || !strings.Contains(err.Error(), "readonly") {
+		t.Errorf("Expected a readonly error, got %v", err)
+	}
+	if _, err := os.Stat(ed.Filename); err == nil {
+		t.Errorf("Expected the file not written")
+	}
+	if err := ExecuteCommand(ed, "w!"); err != nil {
+		t.Fatalf("w!: %v", err)
+	}
+	if _, err := os.Stat(ed.Filename); err != nil {
+		t.Errorf("Expected the file written: %v", err)
+	}
+}
+
+func TestViewCommand(t *testing.T) {
+	dir := t.TempDir()
+	a, b := filepath.Join(dir, "a.txt"), filepath.Join(dir, "b.txt")
+	_ = os.WriteFile(a, []byte("a\n"), 0644)
+	_ = os.WriteFile(b, []byte("b\n"), 0444)
+
+	ed := newTestEditor(false)
+	if err := ExecuteCommand(ed, "view "+a); err != nil {
+		t.Fatalf("view: %v", err)
+	}
+	if !ed.BoolOption("readonly") {
+		t.Errorf("Expected :view to make the buffer readonly")
+	}
+	if err := ExecuteCommand(ed, "e "+a); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+	if ed.BoolOption("readonly") {
+		t.Errorf("Expected :edit of a writable file not to be readonly")
+	}
+
+	// Root may write any file.
+	if os.Geteuid() != 0 {
+		if err := ExecuteCommand(ed, "e "+b); err != nil {
+			t.Fatalf("edit: %v", err)
+		}
+		if !ed.BoolOption("readonly") {
+			t.Errorf("Expected an unwritable file to be readonly")
+		}
+	}
+}
+
+func TestNotModifiableCommands(t *testing.T) {
+	ed := newTestEditor(false)
+	ed.EditorContent = []string{"b", "a"}
+	_ = ed.SetLocalOption("modifiable", false)
+	for _, command := range []string{"%!sort", "undo"} {
+		if err := ExecuteCommand(ed, command); err == nil {
+			t.Errorf("%s: expected an error", command)
+		}
+	}
+	if ed.EditorContent[0] != "b" {
+		t.Errorf("Expected the buffer unchanged, got %q", ed.EditorContent)
+	}
+}
+
+func TestPatternAddress(t *testing.T) {
+	ed := newTestEditor(false)
+	ed.EditorContent = []string{"package main", "", "func a() {}", "func main() {", "}"}
+	tests := []struct {
+		command  string
+		cursor   int
+		expected int
+	}{
+		{"/func", 0, 2},
+		{"/func", 2, 3},
+		{"/func", 3, 2}, // Wraps around
+		{"?func", 2, 3},
+		{"/func m/", 0, 3},
+		{"/^}/-1", 0, 3},
+		{`/\/`, 0, -1},
+		{"/nothing", 0, -1},
+	}
+	for _, tt := range tests {
+		ed.CursorY = tt.cursor
+		err := ExecuteCommand(ed, tt.command)
+		if tt.expected < 0 {
+			if err == nil {
+				t.Errorf("%s: expected an error", tt.command)
+			}
+			continue
+		}
+		if err != nil || ed.CursorY != tt.expected {
+			t.Errorf("%s from line %d: expected line %d, got %d (%v)", tt.command, tt.cursor, tt.expected, ed.CursorY, err)
+		}
+	}
+}
+
+func TestSubstitute(t *testing.T) {
+	tests := []struct {
+		command  string
+		content  []string
+		expected []string
+		cursor   int
+	}{
+		{"s/a/x/", []string{"aaa", "aaa"}, []string{"xaa", "aaa"}, 0},
+		{"%s/a/x/g", []string{"aaa", "bab"}, []string{"xxx", "bxb"}, 1},
+		{"%s#A#x#i", []string{"a/a"}, []string{"x/a"}, 0},
+		{`%s/\/a/-/`, []string{"b/a"}, []string{"b-"}, 0},
+		{`%s/(\w+) (\w+)/\2 \1 [&] \&/`, []string{"hello world"}, []string{"world hello [hello world] &"}, 0},
+		{`s/, /,\r/g`, []string{"a, b, c", "d"}, []string{"a,", "b,", "c", "d"}, 2},
+		{`%s/^b$/x\ty/`, []string{"a", "b", "b"}, []string{"a", "x\ty", "x\ty"}, 2},
+		{"%s/z/y/e", []string{"a"}, []string{"a"}, 0},
+	}
+	for _, tt := range tests {
+		ed := newTestEditor(false)
+		ed.EditorContent = slices.Clone(tt.content)
+		if err := ExecuteCommand(ed, tt.command); err != nil {
+			t.Errorf("%s: %v", tt.command, err)
+			continue
+		}
+		if !slices.Equal(ed.EditorContent, tt.expected) || ed.CursorY != tt.cursor {
+			t.Errorf("%s: expected %q with the cursor on %d, got %q on %d", tt.command, tt.expected, tt.cursor, ed.EditorContent, ed.CursorY)
+		}
+	}
+
+	ed := newTestEditor(false)
+	ed.EditorContent = []string{"abc"}
+	for _, command := range []string{"s/z/y/", "s", "s/(/x/", "sxaxbx", "s/a/b/q"} {
+		if err := ExecuteCommand(ed, command); err == nil {
+			t.Errorf("%s: expected an error", command)
+		}
+	}
+	// An empty pattern is the last one used.
+	if err := ExecuteCommand(ed, "s/b/B/"); err != nil {
+		t.Fatal(err)
+	}
+	ed.EditorContent = []string{"abc"}
+	if err := ExecuteCommand(ed, "s//x/"); err != nil || ed.EditorContent[0] != "axc" {
+		t.Errorf("Expected the last pattern reused, got %q, %v", ed.EditorContent[0], err)
+	}
+}
+
+func TestLineCommands(t *testing.T) {
+	tests := []struct {
+		command  string
+		expected []string
+		cursor   int
+	}{
+		{"2d", []string{"a", "c", "d"}, 1},
+		{"2,3d x", []string{"a", "d"}, 1},
+		{"1d 2", []string{"c", "d"}, 0},
+		{"%d", []string{""}, 0},
+		{"1m$", []string{"b", "c", "d", "a"}, 3},
+		{"3,4m0", []string{"c", "d", "a", "b"}, 1},
+		{"1,2m3", []string{"c", "a", "b", "d"}, 2},
+		{"2m1", []string{"a", "b", "c", "d"}, 1},
+		{"1,2t$", []string{"a", "b", "c", "d", "a", "b"}, 5},
+		{"4co0", []string{"d", "a", "b", "c", "d"}, 0},
+		{"t.", []string{"a", "a", "b", "c", "d"}, 1},
+	}
+	for _, tt := range tests {
+		ed := newTestEditor(false)
+		ed.EditorContent = []string{"a", "b", "c", "d"}
+		if err := ExecuteCommand(ed, tt.command); err != nil {
+			t.Errorf("%s: %v", tt.command, err)
+			continue
+		}
+		if !slices.Equal(ed.EditorContent, tt.expected) || ed.CursorY != tt.cursor {
+			t.Errorf("%s: expected %q with the cursor on %d, got %q on %d", tt.command, tt.expected, tt.cursor, ed.EditorContent, ed.CursorY)
+		}
+	}
+
+	ed := newTestEditor(false)
+	ed.EditorContent = []string{"a", "b", "c"}
+	_ = ExecuteCommand(ed, "1d a")
+	_ = ExecuteCommand(ed, "1d A")
+	if reg, _ := ed.GetRegister('a'); reg.Text != "a\nb\n" || !reg.Linewise {
+		t.Errorf("Expected register a to hold both lines, got %+v", reg)
+	}
+	ed.EditorContent = []string{"a", "b", "c"}
+	for _, command := range []string{"1,3m2", "m", "m 9", "t 1x", "d 0"} {
+		if err := ExecuteCommand(ed, command); err == nil {
+			t.Errorf("%s: expected an error", command)
+		}
+	}
+}
+
+func TestGlobal(t *testing.T) {
+	content := []string{"foo 1", "bar 2", "foo 3", "bar 4", "foo 5"}
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{"g/foo/d", []string{"bar 2", "bar 4"}},
+		{"v/foo/d", []string{"foo 1", "foo 3", "foo 5"}},
+		{"g!/foo/d", []string{"foo 1", "foo 3", "foo 5"}},
+		{"2,4g/foo/d", []string{"foo 1", "bar 2", "bar 4", "foo 5"}},
+		{"g/^/m0", []string{"foo 5", "bar 4", "foo 3", "bar 2", "foo 1"}},
+		{"g/bar/t$", []string{"foo 1", "bar 2", "foo 3", "bar 4", "foo 5", "bar 2", "bar 4"}},
+		{"g/foo/s//baz/", []string{"baz 1", "bar 2", "baz 3", "bar 4", "baz 5"}},
+		{"g/[0-9]/s/[13]/x/", []string{"foo x", "bar 2", "foo x", "bar 4", "foo 5"}},
+		// Deleting the next line too skips it, as it is gone.
+		{"1,4g/./.,+1d", []string{"foo 5"}},
+		{"g#o 3#s#\\d#&&#", []string{"foo 1", "bar 2", "foo 33", "bar 4", "foo 5"}},
+	}
+	for _, tt := range tests {
+		ed := newTestEditor(false)
+		ed.EditorContent = slices.Clone(content)
+		if err := ExecuteCommand(ed, tt.command); err != nil {
+			t.Errorf("%s: %v", tt.command, err)
+			continue
+		}
+		if !slices.Equal(ed.EditorContent, tt.expected) {
+			t.Errorf("%s: expected %q, got %q", tt.command, tt.expected, ed.EditorContent)
+		}
+		if ed.GlobalLines != nil {
+			t.Errorf("%s: expected GlobalLines cleared", tt.command)
+		}
+		// The whole command is one undo step.
+		if err := ExecuteCommand(ed, "undo"); err != nil || !slices.Equal(ed.EditorContent, content) {
+			t.Errorf("%s: expected undo to restore the buffer, got %q, %v", tt.command, ed.EditorContent, err)
+		}
+	}
+
+	ed := newTestEditor(false)
+	ed.EditorContent = slices.Clone(content)
+	if err := ExecuteCommand(ed, "g/bar"); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(ed.Output, []string{"     2 bar 2", "     4 bar 4"}) {
+		t.Errorf("Expected the matching lines shown, got %q", ed.Output)
+	}
+	for _, command := range []string{"g/zzz/d", "v/./d", "g/foo/g/1/d", "g", "gxfooxd"} {
+		if err := ExecuteCommand(ed, command); err == nil {
+			t.Errorf("%s: expected an error", command)
+		}
+	}
+	if !slices.Equal(ed.EditorContent, content) || ed.GlobalLines != nil {
+		t.Errorf("Expected the buffer unchanged, got %q", ed.EditorContent)
+	}
+}
@@ -0,0 +1,221 @@
+package cmd
+
+import (
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"goedit/editor"
+	"goedit/terminal"
+)
+
+// Keys of the command-line editor.
+const (
+	keyCtrlA byte = 1
+	keyCtrlE byte = 5
+	keyCtrlN byte = 14
+	keyCtrlP byte = 16
+	keyCtrlU byte = 21
+	keyCtrlW byte = 23
+	keyTab   byte = 9
+)
+
+// fileCommands take a file name, which Tab completes.
+var fileCommands = []string{"e", "edit", "view", "vie", "w", "wq", "r", "read", "source", "so", "luafile", "mksession", "mks"}
+
+// bufferCommands take a buffer name, which Tab completes.
+var bufferCommands = []string{"b", "buffer"}
+
+// setCommands take option names, which Tab completes.
+var setCommands = []string{"set", "se", "setlocal", "setl", "setglobal", "setg"}
+
+// EditCommandLine handles a key that edits the command line, in Command mode
+// or at a prompt, whose history is of kind. It returns false for other keys,
+// such as Enter and Esc, which the caller handles. Tab completes the text
+// before the cursor, showing the choices in a wildmenu that Tab and Ctrl-N
+// go forward through and Ctrl-P back; any other key closes the menu.
+func EditCommandLine(e *editor.Editor, key byte, kind byte) bool {
+	if e.Wildmenu != nil {
+		switch key {
+		case keyTab, keyCtrlN:
+			e.SelectWildmenu(e.Wildmenu.Selected + 1)
+			return true
+		case keyCtrlP:
+			e.SelectWildmenu(e.Wildmenu.Selected - 1)
+			return true
+		}
+		e.Wildmenu = nil
+	}
+
+	switch key {
+	case terminal.KeyArrowUp, keyCtrlP:
+		e.BrowseHistory(kind, -1)
+		return true
+	case terminal.KeyArrowDown, keyCtrlN:
+		e.BrowseHistory(kind, 1)
+		return true
+	case terminal.KeyArrowLeft:
+		e.SetCommandCursor(e.CommandCursor() - 1)
+		return true
+	case terminal.KeyArrowRight:
+		e.SetCommandCursor(e.CommandCursor() + 1)
+		return true
+	case keyCtrlA:
+		e.SetCommandCursor(0)
+		return true
+	case keyCtrlE:
+		e.SetCommandCursor(len(e.CommandBuffer))
+		return true
+	}
+
+	// Keys that change the text end browsing the history: the line is now
+	// what was typed.
+	switch {
+	case key == keyTab:
+		completeCommandLine(e, kind)
+	case key == keyCtrlW:
+		e.DeleteCommandText(e.CommandWordStart())
+	case key == keyCtrlU:
+		e.DeleteCommandText(0)
+	case key == 127 || key == 8:
+		e.DeleteCommandText(e.CommandCursor() - 1)
+	case key >= 32 && key <= 126:
+		e.InsertCommandText(string(key))
+	default:
+		return false
+	}
+	e.HistoryIndex = 0
+	return true
+}
+
+// completeCommandLine starts completing the word before the cursor: a
+// command name, an option name after :set, an event name after :autocmd,
+// a buffer name after :buffer, or a file name after a command that takes
+// one or at a prompt.
+func completeCommandLine(e *editor.Editor, kind byte) {
+	text := e.CommandBuffer[:e.CommandCursor()]
+	start, items := 0, []string(nil)
+	switch kind {
+	case editor.HistoryCommand:
+		start, items = commandCandidates(e, text)
+	case editor.HistorySearch:
+		return // Patterns are not completed
+	default:
+		items = editor.FileCandidates(text)
+	}
+	if len(items) == 0 {
+		e.SetStatusMessage("No match")
+		return
+	}
+	e.StartWildmenu(start, items)
+}
+
+// commandCandidates returns where the word to complete in the command line
+// text starts, and what it may be completed to.
+func commandCandidates(e *editor.Editor, text string) (int, []string) {
+	rest := strings.TrimLeft(text, " :")
+	if _, _, after, err := parseRange(e, rest); err == nil {
+		rest = after
+	} else {
+		// A range past the end of the buffer still ends at the name.
+		rest = strings.TrimLeft(rest, "0123456789,;.$%+-")
+	}
+	rest = strings.TrimLeft(rest, " ")
+	nameStart := len(text) - len(rest)
+	i := 0
+	for i < len(rest) && (rest[i] >= 'a' && rest[i] <= 'z' || rest[i] >= 'A' && rest[i] <= 'Z') {
+		i++
+	}
+	name := rest[:i]
+	if i == len(rest) {
+		return nameStart, commandNames(name)
+	}
+
+	args := strings.TrimPrefix(rest[i:], "!")
+	if !strings.HasPrefix(args, " ") {
+		return 0, nil // Not a command followed by arguments
+	}
+	args = strings.TrimLeft(args, " ")
+	start := strings.LastIndexByte(text, ' ') + 1
+	word := text[start:]
+	switch {
+	case slices.Contains(setCommands, name):
+		return start, optionNames(word)
+	case name == "autocmd" || name == "au":
+		if strings.Contains(args, " ") {
+			return 0, nil // Past the events
+		}
+		start += strings.LastIndexByte(word, ',') + 1
+		return start, eventCandidates(text[start:])
+	case slices.Contains(fileCommands, name):
+		return start, editor.FileCandidates(word)
+	case slices.Contains(bufferCommands, name):
+		return start, bufferCandidates(e, word)
+	}
+	return 0, nil
+}
+
+// bufferCandidates returns the buffer names that start with prefix, or
+// whose file name does, in BufferNames order.
+func bufferCandidates(e *editor.Editor, prefix string) []string {
+	var names []string
+	for _, name := range BufferNames(e) {
+		if strings.HasPrefix(name, prefix) || strings.HasPrefix(filepath.Base(name), prefix) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// commandNames returns the names of the commands that start with prefix, in
+// name order.
+func commandNames(prefix string) []string {
+	var names []string
+	for name := range commandFuncMap {
+		if strings.HasPrefix(name, prefix) && strings.Trim(name, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ") == "" {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// optionNames returns the option names that start with prefix, which may
+// have a "no" or "inv" in front of a boolean option, in definition order.
+// Nothing is offered once a value is being typed.
+func optionNames(prefix string) []string {
+	if strings.ContainsAny(prefix, "=:") {
+		return nil
+	}
+	var names []string
+	for _, def := range editor.OptionDefs() {
+		if strings.HasPrefix(def.Name, prefix) {
+			names = append(names, def.Name)
+		}
+	}
+	for _, neg := range []string{"no", "inv"} {
+		rest, ok := strings.CutPrefix(prefix, neg)
+		if !ok {
+			continue
+		}
+		for _, def := range editor.OptionDefs() {
+			if def.Type == editor.OptionBool && strings.HasPrefix(def.Name, rest) {
+				names = append(names, neg+def.Name)
+			}
+		}
+	}
+	return names
+}
+
+// eventCandidates returns the event names that start with prefix, ignoring
+// case.
+func eventCandidates(prefix string) []string {
+	var names []string
+	for _, name := range editor.EventNames {
+		if strings.HasPrefix(strings.ToLower(name), strings.ToLower(prefix)) {
+			names = append(names, name)
+		}
+	}
+	return names
+}
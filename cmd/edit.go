@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("edit", editCommand)
+	RegisterCommand("e", editCommand)
+	RegisterCommand("view", viewCommand)
+	RegisterCommand("vie", viewCommand)
+}
+
+// EditFile loads the file name into the editor in place of the buffer, as
+// at startup: its filetype and .editorconfig settings are applied, it is
+// made readonly if the user may not write it, the cursor goes back to where
+// it was last left in it, and BufRead (or BufNewFile if it does not exist)
+// and FileType fire.
+func EditFile(e *editor.Editor, name string) error {
+	content, err := os.ReadFile(name)
+	newFile := errors.Is(err, os.ErrNotExist)
+	if err != nil && !newFile {
+		return fmt.Errorf("Can't open file %s: %v", name, err)
+	}
+
+	e.RememberCursor()
+	e.Filename = name
+	e.LoadFile(content)
+	e.CursorX, e.CursorY = 0, 0
+	e.RowOffset, e.ColOffset = 0, 0
+	e.SetFiletype()
+	err = e.ApplyEditorConfig()
+	if err != nil {
+		err = fmt.Errorf("Error reading .editorconfig for '%s': %v", name, err)
+	}
+	if !newFile && unix.Access(name, unix.W_OK) != nil {
+		_ = e.SetLocalOption("readonly", true)
+	}
+	if newFile {
+		e.Fire(editor.EventBufNewFile)
+	} else {
+		e.RestoreCursor()
+		e.Fire(editor.EventBufRead)
+	}
+	if ft := e.StringOption("filetype"); ft != "" {
+		e.FireEvent(editor.Event{Name: editor.EventFileType, File: name, Match: ft})
+	}
+	return err
+}
+
+// viewCommand implements :vie[w][!] [file], which is like :edit but makes
+// the buffer readonly.
+func viewCommand(e *editor.Editor, c ExCommand) error {
+	if err := editCommand(e, c); err != nil {
+		return err
+	}
+	return e.SetLocalOption("readonly", true)
+}
+
+// editCommand implements :e[dit][!] [file], which edits file, or loads the
+// current file again. Unsaved changes are only thrown away with "!".
+func editCommand(e *editor.Editor, c ExCommand) error {
+	name := ExpandHome(c.Args)
+	if name == "" {
+		name = e.Filename
+	}
+	if name == "" {
+		return errors.New("No file name")
+	}
+	if e.IsDirty && !c.Bang {
+		return errors.New("No write since last change (add ! to override)")
+	}
+	return EditFile(e, name)
+}
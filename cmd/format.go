@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("formatter", formatterCommand)
+	RegisterCommand("format", func(e *editor.Editor, c ExCommand) error { return FormatBuffer(e) })
+}
+
+// errNoFormatter is returned by FormatBuffer when the filetype has none.
+var errNoFormatter = errors.New("No formatter for this filetype")
+
+// FormatBuffer pipes the buffer through the formatter command of its
+// filetype and replaces it with the output, keeping the cursor on the same
+// text. If the formatter fails the buffer is left untouched.
+func FormatBuffer(e *editor.Editor) error {
+	command := e.Formatters[e.StringOption("filetype")]
+	if command == "" {
+		return errNoFormatter
+	}
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	input := strings.Join(e.EditorContent, "\n") + "\n"
+	var stdout, stderr bytes.Buffer
+	if err := runShell(e, command, input, &stdout, &stderr); err != nil {
+		return fmt.Errorf("%s: %w", strings.Fields(command)[0], shellError(err, &stderr))
+	}
+	lines := textLines(stdout.String())
+	if len(lines) == 0 && strings.TrimSpace(input) != "" {
+		// Most likely a formatter that rewrites the file instead of printing.
+		return fmt.Errorf("%s: no output", strings.Fields(command)[0])
+	}
+	e.ReplaceBuffer(lines)
+	return nil
+}
+
+// formatterCommand implements :formatter {filetype} [{command}], which sets
+// the command that formats files of a filetype on save, or shows it. The
+// command reads the buffer on standard input and writes the formatted text;
+// "%" in it stands for the file name. :formatter! {filetype} removes the
+// formatter.
+func formatterCommand(e *editor.Editor, c ExCommand) error {
+	ft, command, _ := strings.Cut(strings.TrimSpace(c.Args), " ")
+	if ft == "" {
+		return errors.New("Argument required")
+	}
+	command = strings.TrimSpace(command)
+	if command == "" && !c.Bang {
+		if current, ok := e.Formatters[ft]; ok {
+			e.SetStatusMessage(ft + ": " + current)
+			return nil
+		}
+		return fmt.Errorf("No formatter for %s", ft)
+	}
+	if e.Formatters == nil {
+		e.Formatters = make(map[string]string)
+	}
+	if command == "" {
+		delete(e.Formatters, ft)
+		return nil
+	}
+	e.Formatters[ft] = command
+	return nil
+}
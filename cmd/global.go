@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("global", globalCommand(false))
+	RegisterCommand("g", globalCommand(false))
+	RegisterCommand("vglobal", globalCommand(true))
+	RegisterCommand("v", globalCommand(true))
+}
+
+// globalCommand returns the implementation of :[range]g[lobal]/{pattern}/{cmd},
+// which runs the Ex command cmd on each line of the range (the whole buffer
+// by default) that matches pattern, and of :[range]v[global]/{pattern}/{cmd}
+// and :g!, for the lines that do not match. The lines are found before cmd
+// runs, and a line deleted by an earlier cmd is skipped. Without a cmd the
+// lines are shown. All the changes are one undo step.
+func globalCommand(invert bool) CommandFunc {
+	return func(e *editor.Editor, c ExCommand) error {
+		if e.GlobalLines != nil {
+			return errors.New("Cannot do :global recursive")
+		}
+		if c.Args == "" {
+			return errors.New("Argument required")
+		}
+		delim := c.Args[0]
+		if delim == ' ' || delim == '\\' || delim == '"' || delim == '|' ||
+			delim >= 'a' && delim <= 'z' || delim >= 'A' && delim <= 'Z' || delim >= '0' && delim <= '9' {
+			return fmt.Errorf("Invalid delimiter: %c", delim)
+		}
+		pat, command := splitPattern(c.Args[1:], delim)
+		re, err := compilePattern(e, pat)
+		if err != nil {
+			return err
+		}
+
+		r := c.Range
+		if !c.HasRange {
+			r = LineRange{0, len(e.EditorContent) - 1}
+		}
+		inverted := invert != c.Bang
+		lines := []int{}
+		for y := r.Start; y <= r.End; y++ {
+			if re.MatchString(e.EditorContent[y]) != inverted {
+				lines = append(lines, y)
+			}
+		}
+		switch {
+		case len(lines) == 0 && inverted:
+			return fmt.Errorf("Pattern found in every line: %s", re)
+		case len(lines) == 0:
+			return fmt.Errorf("Pattern not found: %s", re)
+		case command == "":
+			output := make([]string, len(lines))
+			for i, y := range lines {
+				output[i] = fmt.Sprintf("%6d %s", y+1, e.EditorContent[y])
+			}
+			e.ShowOutput(output)
+			return nil
+		}
+
+		// A command typed on the command line is already an undo step.
+		if !e.UndoStepOpen {
+			e.BeginUndoStep()
+			defer e.EndUndoStep()
+		}
+		e.GlobalLines = lines
+		defer func() { e.GlobalLines = nil }()
+		for len(e.GlobalLines) > 0 && !e.ShouldQuit {
+			e.CursorY, e.CursorX = e.GlobalLines[0], 0
+			e.GlobalLines = e.GlobalLines[1:]
+			if err := ExecuteCommand(e, command); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("d", deleteCommand)
+	RegisterCommand("delete", deleteCommand)
+	RegisterCommand("m", moveCommand)
+	RegisterCommand("move", moveCommand)
+	RegisterCommand("t", copyCommand)
+	RegisterCommand("co", copyCommand)
+	RegisterCommand("copy", copyCommand)
+}
+
+// deleteCommand implements :[range]d[elete] [x] [count], which deletes the
+// lines of the range into register x, or the unnamed register. An
+// uppercase register name appends to the register. With a count, count
+// lines starting with the last line of the range are deleted.
+func deleteCommand(e *editor.Editor, c ExCommand) error {
+	args := c.Args
+	name := editor.UnnamedRegister
+	if args != "" && (args[0] >= 'a' && args[0] <= 'z' || args[0] >= 'A' && args[0] <= 'Z') {
+		name, args = args[0], strings.TrimLeft(args[1:], " ")
+	}
+	start, end := c.Range.Start, c.Range.End
+	if args != "" {
+		n, rest := leadingNumber(args)
+		if rest == args || n == 0 {
+			return fmt.Errorf("Trailing characters: %s", args)
+		}
+		if rest != "" {
+			return fmt.Errorf("Trailing characters: %s", rest)
+		}
+		start, end = end, min(end+n-1, len(e.EditorContent)-1)
+	}
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+
+	text := strings.Join(e.EditorContent[start:end+1], "\n") + "\n"
+	if name >= 'A' && name <= 'Z' {
+		name += 'a' - 'A'
+		if reg, ok := e.GetRegister(name); ok {
+			text = reg.Text + text
+		}
+	}
+	e.SetRegister(name, editor.Register{Text: text, Linewise: true})
+	e.ReplaceLines(start, end, nil)
+	return nil
+}
+
+// moveCommand implements :[range]m[ove] {address}, which moves the lines of
+// the range below the line address, or above the first line for 0. The
+// cursor goes to the last line moved.
+func moveCommand(e *editor.Editor, c ExCommand) error {
+	dest, err := destinationLine(e, c.Args)
+	if err != nil {
+		return err
+	}
+	start, end := c.Range.Start, c.Range.End
+	if dest >= start && dest < end {
+		return errors.New("Move lines into themselves")
+	}
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	if dest == end || dest == start-1 {
+		e.CursorY = end // Already there
+	} else {
+		// The lines are put in their new place first so that the buffer
+		// never runs out of lines.
+		lines := slices.Clone(e.EditorContent[start : end+1])
+		n := len(lines)
+		e.InsertLines(dest+1, lines)
+		if dest > end {
+			e.ReplaceLines(start, end, nil)
+			e.CursorY = dest
+		} else {
+			e.ReplaceLines(start+n, end+n, nil)
+			e.CursorY = dest + n
+		}
+	}
+	e.CursorX = editor.FirstNonBlank(e.EditorContent[e.CursorY])
+	return nil
+}
+
+// copyCommand implements :[range]t {address} and :[range]co[py] {address},
+// which put a copy of the lines of the range below the line address, or
+// above the first line for 0. The cursor goes to the last line copied.
+func copyCommand(e *editor.Editor, c ExCommand) error {
+	dest, err := destinationLine(e, c.Args)
+	if err != nil {
+		return err
+	}
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	lines := slices.Clone(e.EditorContent[c.Range.Start : c.Range.End+1])
+	e.InsertLines(dest+1, lines)
+	e.CursorY = dest + len(lines)
+	e.CursorX = editor.FirstNonBlank(e.EditorContent[e.CursorY])
+	return nil
+}
+
+// destinationLine parses the address that :move and :copy put lines below.
+// Address 0 stands for above the first line, and gives -1.
+func destinationLine(e *editor.Editor, s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if s == "0" {
+		return -1, nil
+	}
+	line, ok, rest, err := parseAddress(e, s, e.CursorY)
+	switch {
+	case err != nil:
+		return 0, err
+	case !ok:
+		return 0, errors.New("Invalid address")
+	case strings.TrimSpace(rest) != "":
+		return 0, fmt.Errorf("Trailing characters: %s", rest)
+	case line < 0 || line >= len(e.EditorContent):
+		return 0, errors.New("Invalid range")
+	}
+	return line, nil
+}
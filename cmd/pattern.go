@@ -0,0 +1,139 @@
+package cmd
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+
+	"goedit/editor"
+)
+
+// splitPattern splits s at the first delim not escaped with a backslash,
+// returning the pattern before it, with escaped delimiters unescaped, and
+// the rest after it. Without a closing delim the pattern runs to the end.
+func splitPattern(s string, delim byte) (pat, rest string) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s) && s[i+1] == delim:
+			b.WriteByte(delim)
+			i++
+		case s[i] == delim:
+			return b.String(), s[i+1:]
+		default:
+			b.WriteByte(s[i])
+		}
+	}
+	return b.String(), ""
+}
+
+// compilePattern compiles a search pattern, which uses Go regular
+// expression syntax. An empty pattern stands for the last one used, and a
+// pattern that compiles becomes the last one.
+func compilePattern(e *editor.Editor, pat string) (*regexp.Regexp, error) {
+	if pat == "" {
+		pat = e.LastPattern
+	}
+	if pat == "" {
+		return nil, fmt.Errorf("Empty pattern")
+	}
+	re, err := regexp.Compile(pat)
+	if err != nil {
+		return nil, fmt.Errorf("Invalid pattern: %s", pat)
+	}
+	e.LastPattern = pat
+	return re, nil
+}
+
+// searchLine returns the first line after line base matching re, or before
+// it if backward is set, wrapping around the end of the buffer.
+func searchLine(e *editor.Editor, re *regexp.Regexp, base int, backward bool) (int, bool) {
+	n := len(e.EditorContent)
+	step := 1
+	if backward {
+		step = n - 1
+	}
+	for i, line := 0, base; i < n; i++ {
+		line = (line + step) % n
+		if re.MatchString(e.EditorContent[line]) {
+			return line, true
+		}
+	}
+	return 0, false
+}
+
+// Search moves the cursor to the count'th match of pat after it, or before
+// it if backward is set, wrapping around the end of the buffer. An empty
+// pat searches for the last pattern again. The direction is remembered for
+// SearchNext and the jump is recorded in the jump list.
+func Search(e *editor.Editor, pat string, backward bool, count int) error {
+	re, err := compilePattern(e, pat)
+	if err != nil {
+		return err
+	}
+	e.LastSearchBackward = backward
+	prompt := "/"
+	if backward {
+		prompt = "?"
+	}
+	y, x, wrapped := e.CursorY, e.CursorX, false
+	for n := 0; n < max(count, 1); n++ {
+		var ok, w bool
+		if y, x, w, ok = searchMatch(e, re, y, x, backward); !ok {
+			return fmt.Errorf("Pattern not found: %s", re)
+		}
+		wrapped = wrapped || w
+	}
+	e.PushJump()
+	e.CursorY, e.CursorX = y, x
+	switch {
+	case wrapped && backward:
+		e.SetStatusMessage("search hit TOP, continuing at BOTTOM")
+	case wrapped:
+		e.SetStatusMessage("search hit BOTTOM, continuing at TOP")
+	default:
+		e.SetStatusMessage(prompt + re.String())
+	}
+	return nil
+}
+
+// SearchNext repeats the last search count times, in the same direction or
+// the opposite one if reverse is set, as n and N do.
+func SearchNext(e *editor.Editor, reverse bool, count int) error {
+	if e.LastPattern == "" {
+		return fmt.Errorf("No previous regular expression")
+	}
+	backward := e.LastSearchBackward
+	err := Search(e, "", backward != reverse, count)
+	e.LastSearchBackward = backward // N does not change the direction of n
+	return err
+}
+
+// searchMatch returns the start of the first match of re after line y,
+// column x, or the last one before it if backward is set, wrapping around
+// the end of the buffer, and whether it wrapped.
+func searchMatch(e *editor.Editor, re *regexp.Regexp, y, x int, backward bool) (int, int, bool, bool) {
+	n := len(e.EditorContent)
+	for i := 0; i <= n; i++ {
+		line := (y + i) % n
+		if backward {
+			line = ((y-i)%n + n) % n
+		}
+		matches := re.FindAllStringIndex(e.EditorContent[line], -1)
+		if backward {
+			slices.Reverse(matches)
+		}
+		for _, m := range matches {
+			switch {
+			case i == 0 && !backward && m[0] <= x, i == 0 && backward && m[0] >= x:
+				continue // Not after, or before, the cursor
+			case i == n && !backward && m[0] > x, i == n && backward && m[0] < x:
+				continue // Already searched, on the cursor line
+			}
+			wrapped := !backward && line < y || backward && line > y || i == n
+			return line, m[0], wrapped, true
+		}
+	}
+	return 0, 0, false, false
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"goedit/editor"
+)
+
+// LineRange is a span of buffer lines (0-based, inclusive) that an Ex command
+// applies to.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+// parseRange parses the optional line range at the start of a command line:
+// "%" for the whole buffer, or one or two addresses separated by ',' or ';'.
+// It returns the range, whether one was given, and the rest of the line. If
+// no range was given the range is the cursor line.
+func parseRange(e *editor.Editor, s string) (LineRange, bool, string, error) {
+	cur := e.CursorY
+	last := len(e.EditorContent) - 1
+	if strings.HasPrefix(s, "%") {
+		return LineRange{0, last}, true, s[1:], nil
+	}
+
+	start, ok, s, err := parseAddress(e, s, cur)
+	if err != nil {
+		return LineRange{}, false, s, err
+	}
+	if !ok {
+		if !strings.HasPrefix(s, ",") && !strings.HasPrefix(s, ";") {
+			return LineRange{cur, cur}, false, s, nil
+		}
+		start = cur
+	}
+
+	end := start
+	if strings.HasPrefix(s, ",") || strings.HasPrefix(s, ";") {
+		base := cur
+		if s[0] == ';' {
+			base = start // With ';' the second address is relative to the first
+		}
+		var endGiven bool
+		end, endGiven, s, err = parseAddress(e, s[1:], base)
+		if err != nil {
+			return LineRange{}, false, s, err
+		}
+		if !endGiven {
+			end = base
+		}
+	}
+
+	if start > end {
+		start, end = end, start
+	}
+	if strings.TrimSpace(s) == "" && start >= 0 {
+		// A bare range only jumps, and past the end goes to the last line.
+		start, end = min(start, last), min(end, last)
+	}
+	if start < 0 || end > last {
+		return LineRange{}, false, s, errors.New("Invalid range")
+	}
+	return LineRange{start, end}, true, s, nil
+}
+
+// parseAddress parses a single line address: a line number, "." for the line
+// base, "$" for the last line, "'x" for the line of mark x, or "/pat/" or
+// "?pat?" for the next or previous line after base matching a pattern,
+// followed by any "+N" or "-N" offsets. A lone offset is relative to base.
+func parseAddress(e *editor.Editor, s string, base int) (int, bool, string, error) {
+	line, ok := 0, false
+	switch {
+	case s == "":
+		return 0, false, s, nil
+	case s[0] >= '0' && s[0] <= '9':
+		n, rest := leadingNumber(s)
+		line, ok, s = max(n-1, 0), true, rest
+	case s[0] == '.':
+		line, ok, s = base, true, s[1:]
+	case s[0] == '$':
+		line, ok, s = len(e.EditorContent)-1, true, s[1:]
+	case s[0] == '\'':
+		if len(s) < 2 {
+			return 0, false, s, errors.New("Invalid range")
+		}
+		p, err := e.MarkPosition(s[1])
+		if err != nil {
+			return 0, false, s, err
+		}
+		line, ok, s = p.Line, true, s[2:]
+	case s[0] == '/' || s[0] == '?':
+		pat, rest := splitPattern(s[1:], s[0])
+		re, err := compilePattern(e, pat)
+		if err != nil {
+			return 0, false, s, err
+		}
+		n, found := searchLine(e, re, base, s[0] == '?')
+		if !found {
+			return 0, false, s, fmt.Errorf("Pattern not found: %s", re)
+		}
+		line, ok, s = n, true, rest
+	}
+
+	for len(s) > 0 && (s[0] == '+' || s[0] == '-') {
+		sign := 1
+		if s[0] == '-' {
+			sign = -1
+		}
+		n, rest := leadingNumber(s[1:])
+		if rest == s[1:] {
+			n = 1 // A bare + or - means one line
+		}
+		if !ok {
+			line, ok = base, true
+		}
+		line += sign * n
+		s = rest
+	}
+	return line, ok, s, nil
+}
+
+// leadingNumber parses the decimal digits at the start of s.
+func leadingNumber(s string) (int, string) {
+	n, i := 0, 0
+	for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+		n = n*10 + int(s[i]-'0')
+		i++
+	}
+	return n, s[i:]
+}
@@ -0,0 +1,133 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"goedit/editor"
+)
+
+// defaultSession is the file :mksession writes and -S reads when none is
+// named.
+const defaultSession = "Session.vim"
+
+func init() {
+	RegisterCommand("mksession", mksessionCommand)
+	RegisterCommand("mks", mksessionCommand)
+	RegisterCommand("cursor", cursorCommand)
+}
+
+// argEscaper escapes white space and backslashes in a value for :set, or in
+// a file name for :args.
+var argEscaper = strings.NewReplacer(`\`, `\\`, " ", `\ `, "\t", "\\\t")
+
+// mksessionCommand implements :mks[ession][!] [file], which writes the
+// commands that bring back the editing session: the options changed, the
+// argument list, the file being edited with its own option values, and the
+// cursor position.
+// Sourcing the file, or starting goedit with -S, restores it. An existing
+// file is only overwritten with "!".
+func mksessionCommand(e *editor.Editor, c ExCommand) error {
+	path := ExpandHome(c.Args)
+	if path == "" {
+		path = defaultSession
+	}
+	if _, err := os.Stat(path); err == nil && !c.Bang {
+		return fmt.Errorf("File exists: %s (add ! to override)", c.Args)
+	}
+	if err := os.WriteFile(path, []byte(strings.Join(SessionCommands(e), "\n")+"\n"), 0644); err != nil {
+		return fmt.Errorf("Can't write session file: %v", err)
+	}
+	e.SetStatusMessage(fmt.Sprintf("Session saved to '%s'", path))
+	return nil
+}
+
+// SessionCommands returns the lines of a session file for e.
+func SessionCommands(e *editor.Editor) []string {
+	lines := []string{`" goedit session file`}
+	lines = append(lines, setLines("set", e.Options)...)
+	if len(e.ArgList) > 0 {
+		names := make([]string, len(e.ArgList))
+		for i, name := range e.ArgList {
+			names[i] = argEscaper.Replace(absPath(name))
+		}
+		lines = append(lines, "args "+strings.Join(names, " "))
+	}
+	switch {
+	case e.ArgIndex >= 0 && e.ArgIndex < len(e.ArgList) && e.ArgList[e.ArgIndex] == e.Filename:
+		lines = append(lines, fmt.Sprintf("argument %d", e.ArgIndex+1))
+	case e.Filename != "":
+		lines = append(lines, "edit "+absPath(e.Filename))
+	}
+	lines = append(lines, setLines("setlocal", e.LocalOptions)...)
+	lines = append(lines, fmt.Sprintf("cursor %d %d", e.CursorY+1, e.CursorX+1))
+	return lines
+}
+
+// absPath returns the absolute path of name, or name if there is none.
+func absPath(name string) string {
+	if abs, err := filepath.Abs(name); err == nil {
+		return abs
+	}
+	return name
+}
+
+// cursorCommand implements :cursor {line} [col], which puts the cursor on
+// line and byte column col, both counted from 1 and kept within the buffer.
+// Session files use it.
+func cursorCommand(e *editor.Editor, c ExCommand) error {
+	fields := strings.Fields(c.Args)
+	switch {
+	case len(fields) == 0:
+		return errors.New("Argument required")
+	case len(fields) > 2:
+		return fmt.Errorf("Trailing characters: %s", strings.Join(fields[2:], " "))
+	}
+	pos := []int{1, 1}
+	for i, f := range fields {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 1 {
+			return fmt.Errorf("Invalid argument: %s", f)
+		}
+		pos[i] = n
+	}
+	e.CursorY = min(pos[0], len(e.EditorContent)) - 1
+	e.CursorX = min(pos[1]-1, len(e.EditorContent[e.CursorY]))
+	return nil
+}
+
+// setLines returns a command setting each of the options, in name order,
+// with white space and backslashes in values escaped as :set needs.
+func setLines(command string, options map[string]any) []string {
+	var lines []string
+	for name, value := range options {
+		def, ok := editor.LookupOption(name)
+		if !ok {
+			continue
+		}
+		arg := formatOption(def, value)
+		if def.Type != editor.OptionBool {
+			arg = argEscaper.Replace(arg)
+		}
+		lines = append(lines, command+" "+arg)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+// LoadSession sources a session file, or Session.vim if path is "".
+func LoadSession(e *editor.Editor, path string) error {
+	if path == "" {
+		path = defaultSession
+	}
+	err := SourceFile(e, ExpandHome(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("Can't open session file %s", path)
+	}
+	return err
+}
@@ -0,0 +1,200 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"goedit/editor"
+)
+
+// optionAccess is how one of :set, :setlocal and :setglobal reads and writes
+// option values.
+type optionAccess struct {
+	get func(e *editor.Editor, name string) any
+	set func(e *editor.Editor, name string, value any) error
+}
+
+var (
+	setAccess       = optionAccess{(*editor.Editor).OptionValue, (*editor.Editor).SetOption}
+	setLocalAccess  = optionAccess{(*editor.Editor).OptionValue, (*editor.Editor).SetLocalOption}
+	setGlobalAccess = optionAccess{(*editor.Editor).GlobalOptionValue, (*editor.Editor).SetGlobalOption}
+)
+
+// setCommand returns the implementation of :set, :setlocal or :setglobal.
+// Each argument is one of:
+//
+//	name       turn a boolean option on, or show another option
+//	noname     turn a boolean option off
+//	name!      toggle a boolean option (also invname)
+//	name?      show the value
+//	name&      reset to the default value
+//	name=value set the value (also name:value)
+//	name+=value, name-=value, name^=value
+//	           add, subtract or multiply a number; append, remove or
+//	           prepend a string or list item
+//
+// Without arguments the options that differ from their defaults are shown;
+// "all" shows every option. A backslash escapes a space in a value.
+func setCommand(access optionAccess) CommandFunc {
+	return func(e *editor.Editor, c ExCommand) error {
+		args := splitSetArgs(c.Args)
+		if len(args) == 0 || (len(args) == 1 && args[0] == "all") {
+			showAll := len(args) == 1
+			var shown []string
+			for _, def := range editor.OptionDefs() {
+				value := access.get(e, def.Name)
+				if showAll || value != def.Default {
+					shown = append(shown, formatOption(&def, value))
+				}
+			}
+			e.SetStatusMessage(strings.Join(shown, " "))
+			return nil
+		}
+
+		var shown []string
+		for _, arg := range args {
+			show, err := setOption(e, access, arg)
+			if err != nil {
+				return err
+			}
+			if show != "" {
+				shown = append(shown, show)
+			}
+		}
+		if len(shown) > 0 {
+			e.SetStatusMessage(strings.Join(shown, " "))
+		}
+		return nil
+	}
+}
+
+// splitSetArgs splits :set or :args arguments at unescaped white space.
+func splitSetArgs(s string) []string {
+	var args []string
+	var arg strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && i+1 < len(s):
+			i++
+			arg.WriteByte(s[i])
+		case s[i] == ' ' || s[i] == '\t':
+			if arg.Len() > 0 {
+				args = append(args, arg.String())
+				arg.Reset()
+			}
+		default:
+			arg.WriteByte(s[i])
+		}
+	}
+	if arg.Len() > 0 {
+		args = append(args, arg.String())
+	}
+	return args
+}
+
+// setOption applies a single :set argument. It returns the text to show if
+// the argument asked for an option's value.
+func setOption(e *editor.Editor, access optionAccess, arg string) (string, error) {
+	name := arg
+	if i := strings.IndexAny(arg, "=:+-^!?&"); i >= 0 {
+		name = arg[:i]
+	}
+	op := arg[len(name):]
+
+	def, ok := editor.LookupOption(name)
+	if !ok && op == "" {
+		// "noname" and "invname" turn off or toggle a boolean option.
+		for _, prefix := range []string{"no", "inv"} {
+			if d, found := editor.LookupOption(strings.TrimPrefix(name, prefix)); found && strings.HasPrefix(name, prefix) && d.Type == editor.OptionBool {
+				if prefix == "no" {
+					return "", access.set(e, d.Name, false)
+				}
+				return "", access.set(e, d.Name, !access.get(e, d.Name).(bool))
+			}
+		}
+	}
+	if !ok {
+		return "", fmt.Errorf("Unknown option: %s", name)
+	}
+
+	switch {
+	case op == "" && def.Type == editor.OptionBool:
+		return "", access.set(e, def.Name, true)
+	case op == "" || op == "?":
+		return formatOption(def, access.get(e, def.Name)), nil
+	case op == "!" && def.Type == editor.OptionBool:
+		return "", access.set(e, def.Name, !access.get(e, def.Name).(bool))
+	case op == "&":
+		return "", access.set(e, def.Name, def.Default)
+	case op[0] == '=' || op[0] == ':':
+		value, err := editor.ParseOptionValue(def, op[1:])
+		if err != nil {
+			return "", err
+		}
+		return "", access.set(e, def.Name, value)
+	case len(op) > 1 && op[1] == '=' && strings.IndexByte("+-^", op[0]) >= 0:
+		value, err := modifyOption(def, access.get(e, def.Name), op[0], op[2:])
+		if err != nil {
+			return "", err
+		}
+		return "", access.set(e, def.Name, value)
+	}
+	return "", fmt.Errorf("Invalid argument: %s", arg)
+}
+
+// modifyOption computes the result of "+=", "-=" or "^=" (given as op) with
+// arg on an option whose value is current.
+func modifyOption(def *editor.OptionDef, current any, op byte, arg string) (any, error) {
+	value, err := editor.ParseOptionValue(def, arg)
+	if err != nil {
+		return nil, err
+	}
+	switch def.Type {
+	case editor.OptionInt:
+		n, cur := value.(int), current.(int)
+		switch op {
+		case '+':
+			return cur + n, nil
+		case '-':
+			return cur - n, nil
+		}
+		return cur * n, nil
+	case editor.OptionList:
+		var items []string
+		for _, item := range strings.Split(current.(string), ",") {
+			if item != "" && item != arg {
+				items = append(items, item)
+			}
+		}
+		switch op {
+		case '+':
+			items = append(items, arg)
+		case '^':
+			items = append([]string{arg}, items...)
+		}
+		return strings.Join(items, ","), nil
+	}
+	cur := current.(string)
+	switch op {
+	case '+':
+		return cur + arg, nil
+	case '-':
+		return strings.Replace(cur, arg, "", 1), nil
+	}
+	return arg + cur, nil
+}
+
+// formatOption shows an option the way :set name? does.
+func formatOption(def *editor.OptionDef, value any) string {
+	switch v := value.(type) {
+	case bool:
+		if !v {
+			return "no" + def.Name
+		}
+		return def.Name
+	case int:
+		return def.Name + "=" + strconv.Itoa(v)
+	}
+	return fmt.Sprintf("%s=%v", def.Name, value)
+}
@@ -0,0 +1,162 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("!", bangCommand)
+	RegisterCommand("r", readCommand)
+	RegisterCommand("read", readCommand)
+}
+
+// expandFileName replaces each "%" in a shell command with the name of the
+// file being edited. "\%" stands for a literal "%".
+func expandFileName(e *editor.Editor, command string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(command); i++ {
+		switch {
+		case command[i] == '\\' && i+1 < len(command) && command[i+1] == '%':
+			b.WriteByte('%')
+			i++
+		case command[i] == '%':
+			if e.Filename == "" {
+				return "", errors.New("No file name to substitute for '%'")
+			}
+			b.WriteString(e.Filename)
+		default:
+			b.WriteByte(command[i])
+		}
+	}
+	return b.String(), nil
+}
+
+// runShell runs command with the shell named by the shell option, giving it
+// input on its standard input. Standard output goes to stdout and standard
+// error to stderr, which may be the same buffer. A non-zero exit status is
+// returned as an error.
+func runShell(e *editor.Editor, command, input string, stdout, stderr *bytes.Buffer) error {
+	command, err := expandFileName(e, command)
+	if err != nil {
+		return err
+	}
+	c := exec.Command(e.StringOption("shell"), "-c", command)
+	c.Stdin = strings.NewReader(input)
+	c.Stdout, c.Stderr = stdout, stderr
+	err = c.Run()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return fmt.Errorf("Shell returned %d", exitErr.ExitCode())
+	}
+	return err
+}
+
+// shellError adds the first line of a failed command's error output to err.
+func shellError(err error, stderr *bytes.Buffer) error {
+	if msg, _, _ := strings.Cut(strings.TrimSpace(stderr.String()), "\n"); msg != "" {
+		return fmt.Errorf("%v: %s", err, msg)
+	}
+	return err
+}
+
+// textLines splits text into lines, ignoring a final line ending.
+func textLines(text string) []string {
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.TrimSuffix(text, "\n")
+	if text == "" {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// bangCommand implements :!{cmd}, which runs a shell command and shows its
+// output, and :{range}!{filter}, which pipes the lines of the range through
+// a filter command and replaces them with its output. The lines are left
+// alone if the filter fails.
+func bangCommand(e *editor.Editor, c ExCommand) error {
+	if c.Args == "" {
+		return errors.New("Argument required")
+	}
+	if !c.HasRange {
+		var out bytes.Buffer
+		err := runShell(e, c.Args, "", &out, &out)
+		lines := textLines(out.String())
+		if err != nil && len(lines) == 0 {
+			return err
+		}
+		if err != nil {
+			lines = append(lines, err.Error())
+		}
+		e.ShowOutput(lines)
+		return nil
+	}
+
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	start, end := c.Range.Start, c.Range.End
+	input := strings.Join(e.EditorContent[start:end+1], "\n") + "\n"
+	var stdout, stderr bytes.Buffer
+	if err := runShell(e, c.Args, input, &stdout, &stderr); err != nil {
+		return shellError(err, &stderr)
+	}
+	e.ReplaceLines(start, end, textLines(stdout.String()))
+	e.SetStatusMessage(fmt.Sprintf("%d lines filtered", end-start+1))
+	return nil
+}
+
+// readCommand implements :r[ead] {file}, which inserts the lines of a file
+// (the current file if none is named) below the last line of the range, or
+// above the first line for :0r[ead], and :r[ead] !{cmd}, which inserts the
+// output of a shell command. The cursor goes to the first inserted line.
+func readCommand(e *editor.Editor, c ExCommand) error {
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	var lines []string
+	if command, ok := strings.CutPrefix(c.Args, "!"); ok || c.Bang {
+		if c.Bang {
+			command = c.Args
+		}
+		if command == "" {
+			return errors.New("Argument required")
+		}
+		var stdout, stderr bytes.Buffer
+		if err := runShell(e, command, "", &stdout, &stderr); err != nil {
+			return shellError(err, &stderr)
+		}
+		lines = textLines(stdout.String())
+	} else {
+		name := c.Args
+		if name == "" {
+			name = e.Filename
+		}
+		if name == "" {
+			return errors.New("No file name")
+		}
+		data, err := os.ReadFile(ExpandHome(name))
+		if err != nil {
+			return fmt.Errorf("Can't open file %s", name)
+		}
+		lines = textLines(string(data))
+	}
+
+	if len(lines) == 0 {
+		return nil
+	}
+	at := c.Range.End + 1
+	if c.LineZero {
+		at = 0
+	}
+	e.InsertLines(at, lines)
+	e.CursorY = at
+	e.CursorX = editor.FirstNonBlank(lines[0])
+	return nil
+}
@@ -0,0 +1,110 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"goedit/editor"
+)
+
+// maxSourceDepth limits how deeply sourced files may source other files.
+const maxSourceDepth = 20
+
+func init() {
+	RegisterCommand("source", sourceCommand)
+	RegisterCommand("so", sourceCommand)
+}
+
+// ConfigPath returns the path of the config file read at startup:
+// $XDG_CONFIG_HOME/goedit/config, or ~/.config/goedit/config when
+// XDG_CONFIG_HOME is not set. It returns "" if neither can be determined.
+// Only the user's config directory is used; the system directories of
+// XDG_CONFIG_DIRS are not searched.
+func ConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "goedit", "config")
+}
+
+// LoadConfig sources the startup config file, if there is one.
+func LoadConfig(e *editor.Editor) error {
+	path := ConfigPath()
+	if path == "" {
+		return nil
+	}
+	err := SourceFile(e, path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// SourceFile runs each line of a file as an Ex command. Empty lines and
+// lines starting with '"' are skipped. A failing command does not stop the
+// rest of the file; the first error is returned with its line number. A
+// line too long to read stops the file, and that error is returned.
+func SourceFile(e *editor.Editor, path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	if e.SourceDepth >= maxSourceDepth {
+		return fmt.Errorf("Too deeply nested :source: %s", path)
+	}
+	e.SourceDepth++
+	defer func() { e.SourceDepth-- }()
+
+	var firstErr error
+	lineNum := 0
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		lineNum++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "\"") {
+			continue
+		}
+		if err := ExecuteCommand(e, line); err != nil && firstErr == nil {
+			firstErr = fmt.Errorf("%s line %d: %v", path, lineNum, err)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("%s line %d: %v", path, lineNum+1, err)
+	}
+	return firstErr
+}
+
+// sourceCommand implements :source {file}.
+func sourceCommand(e *editor.Editor, c ExCommand) error {
+	path := c.Args
+	if path == "" {
+		return errors.New("Argument required")
+	}
+	if err := SourceFile(e, ExpandHome(path)); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Can't open file %s", c.Args)
+		}
+		return err
+	}
+	return nil
+}
+
+// ExpandHome replaces a leading "~/" in path with the home directory.
+func ExpandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(home, rest)
+		}
+	}
+	return path
+}
@@ -0,0 +1,141 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"goedit/editor"
+)
+
+func init() {
+	RegisterCommand("s", substituteCommand)
+	RegisterCommand("substitute", substituteCommand)
+}
+
+// substituteCommand implements :[range]s[ubstitute]/{pattern}/{string}/[flags],
+// which replaces matches of pattern in each line of the range with string.
+// Any punctuation may be used in place of "/". The flags are "g" to replace
+// every match in a line rather than the first, "i" to ignore case and "e"
+// to give no error when nothing matches. The cursor goes to the last line
+// changed.
+func substituteCommand(e *editor.Editor, c ExCommand) error {
+	if c.Args == "" {
+		return errors.New("Argument required")
+	}
+	delim := c.Args[0]
+	if delim == ' ' || delim == '\\' || delim == '"' || delim == '|' ||
+		delim >= 'a' && delim <= 'z' || delim >= 'A' && delim <= 'Z' || delim >= '0' && delim <= '9' {
+		return fmt.Errorf("Invalid delimiter: %c", delim)
+	}
+	pat, rest := splitPattern(c.Args[1:], delim)
+	replacement, flags := splitPattern(rest, delim)
+
+	all, ignoreCase, quiet := false, false, false
+	for _, f := range flags {
+		switch f {
+		case 'g':
+			all = true
+		case 'i':
+			ignoreCase = true
+		case 'I':
+			ignoreCase = false
+		case 'e':
+			quiet = true
+		default:
+			return fmt.Errorf("Trailing characters: %s", flags)
+		}
+	}
+	re, err := compilePattern(e, pat)
+	if err != nil {
+		return err
+	}
+	if ignoreCase {
+		re = regexp.MustCompile("(?i)" + re.String())
+	}
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+
+	count, lines, last := 0, 0, -1
+	end := c.Range.End
+	for y := c.Range.Start; y <= end && y < len(e.EditorContent); y++ {
+		line := e.EditorContent[y]
+		n := 1
+		if all {
+			n = -1
+		}
+		matches := re.FindAllStringSubmatchIndex(line, n)
+		if len(matches) == 0 {
+			continue
+		}
+		var b strings.Builder
+		prev := 0
+		for _, m := range matches {
+			b.WriteString(line[prev:m[0]])
+			expandReplacement(&b, replacement, line, m)
+			prev = m[1]
+		}
+		b.WriteString(line[prev:])
+		count += len(matches)
+		lines++
+
+		newLines := strings.Split(b.String(), "\n")
+		if len(newLines) > 1 || newLines[0] != line {
+			e.ReplaceLines(y, y, newLines)
+		}
+		y += len(newLines) - 1
+		end += len(newLines) - 1
+		last = y
+	}
+
+	if count == 0 {
+		// Under :global a line without a match is not an error, as the
+		// pattern may not be the one :global matched.
+		if quiet || e.GlobalLines != nil {
+			return nil
+		}
+		return fmt.Errorf("Pattern not found: %s", re)
+	}
+	e.CursorY = last
+	e.CursorX = editor.FirstNonBlank(e.EditorContent[last])
+	if lines > 1 {
+		e.SetStatusMessage(fmt.Sprintf("%d substitutions on %d lines", count, lines))
+	}
+	return nil
+}
+
+// expandReplacement writes the replacement for a match of a :substitute
+// pattern in line, whose submatch indexes are m. In the replacement "&" and
+// "\0" stand for the whole match, "\1" to "\9" for submatches, "\n" and "\r"
+// for a line break and "\t" for a tab; a backslash makes any other
+// character, such as "&" or "\", stand for itself.
+func expandReplacement(b *strings.Builder, replacement, line string, m []int) {
+	group := func(i int) {
+		if 2*i+1 < len(m) && m[2*i] >= 0 {
+			b.WriteString(line[m[2*i]:m[2*i+1]])
+		}
+	}
+	for i := 0; i < len(replacement); i++ {
+		ch := replacement[i]
+		switch {
+		case ch == '&':
+			group(0)
+		case ch == '\\' && i+1 < len(replacement):
+			i++
+			switch ch = replacement[i]; {
+			case ch >= '0' && ch <= '9':
+				group(int(ch - '0'))
+			case ch == 'n' || ch == 'r':
+				b.WriteByte('\n')
+			case ch == 't':
+				b.WriteByte('\t')
+			default:
+				b.WriteByte(ch)
+			}
+		default:
+			b.WriteByte(ch)
+		}
+	}
+}
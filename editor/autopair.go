@@ -0,0 +1,48 @@
+package editor
+
+import "strings"
+
+// defaultAutoPairs are the pairs closed automatically when the autopairs
+// option is empty and the filetype has no pairs of its own, in the
+// "open:close,..." form of the option.
+const defaultAutoPairs = `(:),[:],{:},":",':'`
+
+// filetypeAutoPairs holds the pairs of filetypes where the defaults get in
+// the way, such as apostrophes in prose and Rust lifetimes.
+var filetypeAutoPairs = map[string]string{
+	"go":       "(:),[:],{:},\":\",':',`:`",
+	"markdown": "(:),[:],{:},\":\",`:`",
+	"rust":     `(:),[:],{:},":"`,
+}
+
+// AutoPairs returns the pairs of characters closed automatically in Insert
+// mode, or nil if the autopair option is off.
+func (e *Editor) AutoPairs() [][2]byte {
+	if !e.BoolOption("autopair") {
+		return nil
+	}
+	spec := e.StringOption("autopairs")
+	if spec == "" {
+		spec = defaultAutoPairs
+		if ft, ok := filetypeAutoPairs[e.StringOption("filetype")]; ok {
+			spec = ft
+		}
+	}
+	var pairs [][2]byte
+	for _, item := range splitList(spec) {
+		if len(item) == 3 && item[1] == ':' {
+			pairs = append(pairs, [2]byte{item[0], item[2]})
+		}
+	}
+	return pairs
+}
+
+// InStringOrComment reports whether p is inside a string or comment,
+// according to the SyntaxAt hook. Without a highlighter it returns false.
+func (e *Editor) InStringOrComment(p Position) bool {
+	if e.SyntaxAt == nil {
+		return false
+	}
+	group := strings.ToLower(e.SyntaxAt(p))
+	return strings.Contains(group, "string") || strings.Contains(group, "comment")
+}
@@ -0,0 +1,49 @@
+package editor
+
+import "testing"
+
+func TestAutoPairs(t *testing.T) {
+	tests := []struct {
+		name     string
+		options  map[string]any
+		expected string
+	}{
+		{name: "Off", expected: ""},
+		{name: "Defaults", options: map[string]any{"autopair": true}, expected: `()[]{}""''`},
+		{name: "Filetype", options: map[string]any{"autopair": true, "filetype": "rust"}, expected: `()[]{}""`},
+		{name: "Option", options: map[string]any{"autopair": true, "filetype": "rust", "autopairs": "<:>,bad,(:)"}, expected: "<>()"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			for name, value := range tt.options {
+				if err := ed.SetOption(name, value); err != nil {
+					t.Fatalf("Unexpected error setting %s: %v", name, err)
+				}
+			}
+			got := ""
+			for _, p := range ed.AutoPairs() {
+				got += string(p[:])
+			}
+			if got != tt.expected {
+				t.Errorf("Expected pairs %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestInStringOrComment(t *testing.T) {
+	ed := NewEditor(80, 24)
+	if ed.InStringOrComment(Position{}) {
+		t.Error("Expected false without a highlighter")
+	}
+	ed.SyntaxAt = func(p Position) string {
+		return []string{"Keyword", "String", "goComment"}[p.Col]
+	}
+	for col, expected := range []bool{false, true, true} {
+		if got := ed.InStringOrComment(Position{Col: col}); got != expected {
+			t.Errorf("Expected %t at column %d, got %t", expected, col, got)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package editor
+
+import (
+	"slices"
+	"strings"
+	"time"
+)
+
+// History kinds, named by the character Vim uses for them.
+const (
+	HistoryCommand byte = ':' // Ex command lines
+	HistorySearch  byte = '/' // Search patterns typed after / or ?
+	HistoryInput   byte = '@' // Answers to prompts, such as a file name to save as
+)
+
+// HistoryEntry is a line of history and when it was last used, by which
+// the histories of editors running at once are merged.
+type HistoryEntry struct {
+	Text string
+	Time time.Time
+}
+
+// Wildmenu is a command-line completion in progress, shown as a menu above
+// the command line. The selected item replaces the text from Start to the
+// cursor; with no item selected the text typed before completion began is
+// restored.
+type Wildmenu struct {
+	Items    []string
+	Selected int    // Index into Items, or -1 for the original text
+	Start    int    // Offset in CommandBuffer where the completed text starts
+	Original string // Text from Start to the cursor when completion began
+}
+
+// StartCommandLine enters mode, Command mode or a prompt, with text already
+// typed on the command line and the cursor after it.
+func (e *Editor) StartCommandLine(mode Mode, text string) {
+	e.CurrentMode = mode
+	e.CommandPrompt = HistoryCommand
+	e.CommandBuffer = text
+	e.CommandTail = 0
+	e.HistoryIndex = 0
+	e.Wildmenu = nil
+}
+
+// StartSearch enters Command mode to type a search pattern after the
+// prompt, '/' to search forward or '?' backward.
+func (e *Editor) StartSearch(prompt byte) {
+	e.StartCommandLine(ModeCommand, "")
+	e.CommandPrompt = prompt
+}
+
+// Searching reports whether the command line holds a search pattern rather
+// than an Ex command.
+func (e *Editor) Searching() bool {
+	return e.CurrentMode == ModeCommand && (e.CommandPrompt == '/' || e.CommandPrompt == '?')
+}
+
+// CommandCursor returns the offset of the cursor in CommandBuffer.
+func (e *Editor) CommandCursor() int {
+	return max(len(e.CommandBuffer)-e.CommandTail, 0)
+}
+
+// SetCommandCursor moves the command-line cursor to offset col, kept within
+// the text.
+func (e *Editor) SetCommandCursor(col int) {
+	e.CommandTail = len(e.CommandBuffer) - max(min(col, len(e.CommandBuffer)), 0)
+}
+
+// InsertCommandText inserts text at the command-line cursor.
+func (e *Editor) InsertCommandText(text string) {
+	col := e.CommandCursor()
+	e.CommandBuffer = e.CommandBuffer[:col] + text + e.CommandBuffer[col:]
+}
+
+// DeleteCommandText deletes the command-line text from offset from to the
+// cursor.
+func (e *Editor) DeleteCommandText(from int) {
+	col := e.CommandCursor()
+	from = max(min(from, col), 0)
+	e.CommandBuffer = e.CommandBuffer[:from] + e.CommandBuffer[col:]
+}
+
+// CommandWordStart returns the offset of the start of the word before the
+// command-line cursor, as deleted by Ctrl-W: a run of keyword characters or
+// of other non-blank characters, after any blanks.
+func (e *Editor) CommandWordStart() int {
+	text := e.CommandBuffer[:e.CommandCursor()]
+	i := len(strings.TrimRight(text, " "))
+	if i > 0 && isKeywordChar(text[i-1]) {
+		for i > 0 && isKeywordChar(text[i-1]) {
+			i--
+		}
+	} else {
+		for i > 0 && text[i-1] != ' ' && !isKeywordChar(text[i-1]) {
+			i--
+		}
+	}
+	return i
+}
+
+// AddHistory adds line to the history of kind, moving it to the end if it
+// is there already. The oldest lines beyond the history option are dropped.
+func (e *Editor) AddHistory(kind byte, line string) {
+	if line == "" {
+		return
+	}
+	if e.History == nil {
+		e.History = make(map[byte][]HistoryEntry)
+	}
+	lines := slices.DeleteFunc(e.History[kind], func(h HistoryEntry) bool { return h.Text == line })
+	e.History[kind] = e.trimHistory(append(lines, HistoryEntry{Text: line, Time: time.Now()}))
+}
+
+// trimHistory drops the oldest lines beyond the history option.
+func (e *Editor) trimHistory(lines []HistoryEntry) []HistoryEntry {
+	if n := max(e.IntOption("history"), 0); len(lines) > n {
+		lines = slices.Delete(lines, 0, len(lines)-n)
+	}
+	return lines
+}
+
+// BrowseHistory replaces the command line with the next older (dir < 0) or
+// newer (dir > 0) line of the history of kind that starts with the text
+// typed before browsing began. Going newer than the newest line brings back
+// the typed text. It returns false if there is no such line.
+func (e *Editor) BrowseHistory(kind byte, dir int) bool {
+	lines := e.History[kind]
+	if e.HistoryIndex == 0 {
+		e.HistoryPrefix = e.CommandBuffer
+	}
+	// HistoryIndex counts back from the typed text, which is 0.
+	for back := e.HistoryIndex - dir; back >= 0 && back <= len(lines); back -= dir {
+		text := e.HistoryPrefix
+		if back > 0 {
+			text = lines[len(lines)-back].Text
+		}
+		if back == 0 || strings.HasPrefix(text, e.HistoryPrefix) {
+			e.HistoryIndex = back
+			e.CommandBuffer = text
+			e.CommandTail = 0
+			return true
+		}
+	}
+	return false
+}
+
+// StartWildmenu begins completing the command-line text from start to the
+// cursor with items. A single item is put in at once; with several the
+// first is selected and the menu shown.
+func (e *Editor) StartWildmenu(start int, items []string) {
+	col := e.CommandCursor()
+	start = max(min(start, col), 0)
+	e.Wildmenu = &Wildmenu{
+		Items:    items,
+		Selected: -1,
+		Start:    start,
+		Original: e.CommandBuffer[start:col],
+	}
+	e.SelectWildmenu(0)
+	if len(items) == 1 {
+		e.Wildmenu = nil
+	}
+}
+
+// SelectWildmenu selects item i of the completion in progress, wrapping
+// around through the original text, and puts it on the command line.
+func (e *Editor) SelectWildmenu(i int) {
+	w := e.Wildmenu
+	if w == nil {
+		return
+	}
+	n := len(w.Items) + 1 // The items and the original text
+	w.Selected = ((i+1)%n+n)%n - 1
+
+	text := w.Original
+	if w.Selected >= 0 {
+		text = w.Items[w.Selected]
+	}
+	e.DeleteCommandText(w.Start)
+	e.InsertCommandText(text)
+}
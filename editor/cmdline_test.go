@@ -0,0 +1,124 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandLineEditing(t *testing.T) {
+	e := NewEditor(80, 24)
+	e.StartCommandLine(ModeCommand, "set nu")
+	if e.CommandCursor() != 6 {
+		t.Errorf("Expected cursor 6, got %d", e.CommandCursor())
+	}
+	e.SetCommandCursor(3)
+	e.InsertCommandText("!")
+	if e.CommandBuffer != "set! nu" || e.CommandCursor() != 4 {
+		t.Errorf("Expected \"set! nu\" with cursor 4, got %q with cursor %d", e.CommandBuffer, e.CommandCursor())
+	}
+	e.SetCommandCursor(100)
+	if e.CommandCursor() != 7 {
+		t.Errorf("Expected cursor kept at the end 7, got %d", e.CommandCursor())
+	}
+
+	// A command buffer set directly has the cursor at its end.
+	e.CommandBuffer = "w foo.txt"
+	if e.CommandCursor() != 9 {
+		t.Errorf("Expected cursor 9, got %d", e.CommandCursor())
+	}
+}
+
+func TestCommandWordStart(t *testing.T) {
+	tests := []struct {
+		text     string
+		expected int
+	}{
+		{"", 0},
+		{"set number", 4},
+		{"set number  ", 4},
+		{"w ~/src/", 7},
+		{"w ~/src", 4},
+		{"r !ls", 3},
+	}
+	e := NewEditor(80, 24)
+	for _, tt := range tests {
+		e.StartCommandLine(ModeCommand, tt.text)
+		if got := e.CommandWordStart(); got != tt.expected {
+			t.Errorf("CommandWordStart(%q): expected %d, got %d", tt.text, tt.expected, got)
+		}
+	}
+	e.StartCommandLine(ModeCommand, "set number")
+	e.DeleteCommandText(e.CommandWordStart())
+	if e.CommandBuffer != "set " {
+		t.Errorf("Expected \"set \", got %q", e.CommandBuffer)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	e := NewEditor(80, 24)
+	_ = e.SetOption("history", 3)
+	for _, line := range []string{"set nu", "w", "", "set list", "w", "q"} {
+		e.AddHistory(HistoryCommand, line)
+	}
+	var lines []string
+	for _, h := range e.History[HistoryCommand] {
+		lines = append(lines, h.Text)
+	}
+	if got := strings.Join(lines, ","); got != "set list,w,q" {
+		t.Errorf("Expected history \"set list,w,q\", got %q", got)
+	}
+	e.AddHistory(HistoryInput, "a.txt")
+	if len(e.History[HistoryInput]) != 1 {
+		t.Errorf("Expected 1 input history line, got %d", len(e.History[HistoryInput]))
+	}
+
+	e.StartCommandLine(ModeCommand, "")
+	var seen []string
+	for e.BrowseHistory(HistoryCommand, -1) {
+		seen = append(seen, e.CommandBuffer)
+	}
+	if got := strings.Join(seen, ","); got != "q,w,set list" {
+		t.Errorf("Expected to browse \"q,w,set list\", got %q", got)
+	}
+	for e.BrowseHistory(HistoryCommand, 1) {
+	}
+	if e.CommandBuffer != "" {
+		t.Errorf("Expected the typed text back, got %q", e.CommandBuffer)
+	}
+
+	// Only lines starting with the typed text are browsed.
+	e.StartCommandLine(ModeCommand, "se")
+	if !e.BrowseHistory(HistoryCommand, -1) || e.CommandBuffer != "set list" {
+		t.Errorf("Expected \"set list\", got %q", e.CommandBuffer)
+	}
+	if e.BrowseHistory(HistoryCommand, -1) {
+		t.Errorf("Expected no older line, got %q", e.CommandBuffer)
+	}
+	if !e.BrowseHistory(HistoryCommand, 1) || e.CommandBuffer != "se" {
+		t.Errorf("Expected \"se\" back, got %q", e.CommandBuffer)
+	}
+}
+
+func TestWildmenu(t *testing.T) {
+	e := NewEditor(80, 24)
+	e.StartCommandLine(ModeCommand, "set nu")
+	e.StartWildmenu(4, []string{"number", "numberwidth"})
+	if e.CommandBuffer != "set number" || e.Wildmenu == nil {
+		t.Fatalf("Expected \"set number\" with a menu, got %q", e.CommandBuffer)
+	}
+	e.SelectWildmenu(1)
+	if e.CommandBuffer != "set numberwidth" {
+		t.Errorf("Expected \"set numberwidth\", got %q", e.CommandBuffer)
+	}
+	e.SelectWildmenu(2)
+	if e.CommandBuffer != "set nu" || e.Wildmenu.Selected != -1 {
+		t.Errorf("Expected the original \"set nu\", got %q", e.CommandBuffer)
+	}
+
+	// A single item is put in without a menu.
+	e.StartCommandLine(ModeCommand, "se")
+	e.StartWildmenu(0, []string{"set"})
+	if e.CommandBuffer != "set" || e.Wildmenu != nil {
+		t.Errorf("Expected \"set\" without a menu, got %q", e.CommandBuffer)
+	}
+}
@@ -0,0 +1,227 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// CompletionKind is the source of Insert mode completion candidates.
+type CompletionKind int
+
+const (
+	CompleteWord CompletionKind = iota // Words in the buffer (Ctrl-N, Ctrl-P)
+	CompleteFile                       // File paths (Ctrl-X Ctrl-F)
+	CompleteLine                       // Whole lines (Ctrl-X Ctrl-L)
+	CompleteOmni                       // Candidates from OmniFunc (Ctrl-X Ctrl-O)
+)
+
+// Completion is an Insert mode completion in progress. The selected item
+// replaces the text from Start to the cursor; with no item selected the
+// text typed before completion began is restored.
+type Completion struct {
+	Kind     CompletionKind
+	Items    []string
+	Selected int      // Index into Items, or -1 for the original text
+	Start    Position // Where the completed text starts
+	Original string   // Text from Start to the cursor when completion began
+}
+
+// completionDescriptions are shown in the status bar while completing.
+var completionDescriptions = map[CompletionKind]string{
+	CompleteWord: "Keyword completion (^N^P)",
+	CompleteFile: "File name completion (^F^N^P)",
+	CompleteLine: "Whole line completion (^L^N^P)",
+	CompleteOmni: "Omni completion (^O^N^P)",
+}
+
+// isKeywordChar reports whether c can be part of a completed word.
+func isKeywordChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// isFileNameChar reports whether c can be part of a completed file path.
+func isFileNameChar(c byte) bool {
+	return isKeywordChar(c) || strings.IndexByte("/.-~+", c) >= 0
+}
+
+// StartCompletion begins completing the text before the cursor. The items
+// are found from the buffer or the file system according to kind; it returns
+// false, and sets a status message, if there are none.
+func (e *Editor) StartCompletion(kind CompletionKind) bool {
+	e.ensureLineExists(e.CursorY)
+	line := e.EditorContent[e.CursorY]
+	col := min(e.CursorX, len(line))
+
+	start := col
+	var items []string
+	switch kind {
+	case CompleteWord:
+		for start > 0 && isKeywordChar(line[start-1]) {
+			start--
+		}
+		items = e.wordCandidates(line[start:col])
+	case CompleteFile:
+		for start > 0 && isFileNameChar(line[start-1]) {
+			start--
+		}
+		items = FileCandidates(line[start:col])
+	case CompleteLine:
+		start = FirstNonBlank(line[:col])
+		items = e.lineCandidates(line[start:col])
+	case CompleteOmni:
+		if e.OmniFunc != nil {
+			start, items = e.OmniFunc()
+			start = max(min(start, col), 0)
+		}
+	}
+
+	if len(items) == 0 {
+		e.Completion = nil
+		e.SetStatusMessage("-- " + completionDescriptions[kind] + " Pattern not found")
+		return false
+	}
+	e.Completion = &Completion{
+		Kind:     kind,
+		Items:    items,
+		Selected: -1,
+		Start:    Position{Line: e.CursorY, Col: start},
+		Original: line[start:col],
+	}
+	return true
+}
+
+// wordCandidates returns the words that start with prefix: those of the
+// buffer, nearest first, searching forward from the cursor and wrapping
+// around, then those of the other files of the argument list, which are
+// read from disk.
+func (e *Editor) wordCandidates(prefix string) []string {
+	seen := map[string]bool{prefix: true}
+	var words []string
+	n := len(e.EditorContent)
+	for i := 0; i < n; i++ {
+		y := (e.CursorY + i) % n
+		skip := -1
+		if y == e.CursorY {
+			skip = e.CursorX // The word being completed
+		}
+		words = appendWords(words, seen, e.EditorContent[y], prefix, skip)
+	}
+	for i, name := range e.ArgList {
+		if i == e.ArgIndex || name == e.Filename {
+			continue
+		}
+		content, err := os.ReadFile(name)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			words = appendWords(words, seen, line, prefix, -1)
+		}
+	}
+	return words
+}
+
+// appendWords appends to words those of line that start with prefix and
+// are not yet seen, except the one at column skip.
+func appendWords(words []string, seen map[string]bool, line, prefix string, skip int) []string {
+	for x := 0; x < len(line); {
+		if !isKeywordChar(line[x]) {
+			x++
+			continue
+		}
+		end := x
+		for end < len(line) && isKeywordChar(line[end]) {
+			end++
+		}
+		if word := line[x:end]; !(x <= skip && skip <= end) && strings.HasPrefix(word, prefix) && !seen[word] {
+			seen[word] = true
+			words = append(words, word)
+		}
+		x = end
+	}
+	return words
+}
+
+// lineCandidates returns the lines of the buffer that start with prefix
+// after their indent, nearest first, searching backwards from the cursor.
+func (e *Editor) lineCandidates(prefix string) []string {
+	seen := map[string]bool{}
+	var lines []string
+	n := len(e.EditorContent)
+	for i := 1; i < n; i++ {
+		y := (e.CursorY - i + n) % n
+		text := strings.TrimLeft(e.EditorContent[y], " \t")
+		if text != prefix && strings.HasPrefix(text, prefix) && !seen[text] {
+			seen[text] = true
+			lines = append(lines, text)
+		}
+	}
+	return lines
+}
+
+// FileCandidates returns the paths that start with prefix, in name order.
+// Directories end in a slash. Relative paths are taken from the working
+// directory and "~/" means the home directory.
+func FileCandidates(prefix string) []string {
+	dir, base := filepath.Split(prefix)
+	readDir := dir
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			readDir = filepath.Join(home, rest)
+		}
+	}
+	if readDir == "" {
+		readDir = "."
+	}
+	entries, err := os.ReadDir(readDir)
+	if err != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if !strings.HasPrefix(name, base) || (strings.HasPrefix(name, ".") && !strings.HasPrefix(base, ".")) {
+			continue
+		}
+		if entry.IsDir() {
+			name += "/"
+		}
+		paths = append(paths, dir+name)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// SelectCompletion selects item i of the completion in progress, wrapping
+// around through the original text, and puts it in the buffer.
+func (e *Editor) SelectCompletion(i int) {
+	if e.beginChange() != nil {
+		return
+	}
+	c := e.Completion
+	if c == nil {
+		return
+	}
+	n := len(c.Items) + 1 // The items and the original text
+	c.Selected = ((i+1)%n+n)%n - 1
+
+	text := c.Original
+	if c.Selected >= 0 {
+		text = c.Items[c.Selected]
+	}
+	line := e.EditorContent[c.Start.Line]
+	end := min(e.CursorX, len(line))
+	e.EditorContent[c.Start.Line] = line[:c.Start.Col] + text + line[end:]
+	e.CursorY, e.CursorX = c.Start.Line, c.Start.Col+len(text)
+	e.markChanged(LineChange{Line: c.Start.Line, Old: 1, New: 1})
+
+	if c.Selected >= 0 {
+		e.SetStatusMessage(fmt.Sprintf("-- %s match %d of %d", completionDescriptions[c.Kind], c.Selected+1, len(c.Items)))
+	} else {
+		e.SetStatusMessage("-- " + completionDescriptions[c.Kind] + " Back at original")
+	}
+}
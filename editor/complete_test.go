@@ -0,0 +1,136 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestStartCompletion(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"alpha.go", "alps.txt", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Mkdir(filepath.Join(dir, "almanac"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	other, self := filepath.Join(dir, "other.txt"), filepath.Join(dir, "self.txt")
+	_ = os.WriteFile(other, []byte("value vast\nvariable\n"), 0644)
+	_ = os.WriteFile(self, []byte("vanished\n"), 0644) // Its text on disk is out of date
+
+	tests := []struct {
+		name          string
+		kind          CompletionKind
+		content       []string
+		cursorX       int
+		cursorY       int
+		argList       []string // The last file is the one edited
+		expectedItems []string
+		expectedStart int
+	}{
+		{
+			name:          "Words nearest first",
+			kind:          CompleteWord,
+			content:       []string{"format fork", "fo", "foo for_each format"},
+			cursorX:       2,
+			cursorY:       1,
+			expectedItems: []string{"foo", "for_each", "format", "fork"},
+		},
+		{
+			name:          "Word after text",
+			kind:          CompleteWord,
+			content:       []string{"x := value + va"},
+			cursorX:       15,
+			expectedItems: []string{"value"},
+			expectedStart: 13,
+		},
+		{
+			name:          "Words in other files",
+			kind:          CompleteWord,
+			content:       []string{"x := value + va"},
+			cursorX:       15,
+			argList:       []string{other, filepath.Join(dir, "missing.txt"), self},
+			expectedItems: []string{"value", "vast", "variable"},
+			expectedStart: 13,
+		},
+		{
+			name:          "No words",
+			kind:          CompleteWord,
+			content:       []string{"abc zz"},
+			cursorX:       6,
+			expectedItems: nil,
+		},
+		{
+			name:          "Lines",
+			kind:          CompleteLine,
+			content:       []string{"if err != nil {", "\treturn err", "}", "\tif e"},
+			cursorX:       5,
+			cursorY:       3,
+			expectedItems: []string{"if err != nil {"},
+			expectedStart: 1,
+		},
+		{
+			name:          "Files",
+			kind:          CompleteFile,
+			content:       []string{"see " + dir + "/al"},
+			cursorX:       len(dir) + 7,
+			expectedItems: []string{dir + "/almanac/", dir + "/alpha.go", dir + "/alps.txt"},
+			expectedStart: 4,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = tt.content
+			ed.CursorX, ed.CursorY = tt.cursorX, tt.cursorY
+			ed.ArgList, ed.ArgIndex = tt.argList, len(tt.argList)-1
+
+			ok := ed.StartCompletion(tt.kind)
+			if ok != (tt.expectedItems != nil) {
+				t.Fatalf("Expected completion to start %t, got %t", tt.expectedItems != nil, ok)
+			}
+			if !ok {
+				return
+			}
+			if !slices.Equal(ed.Completion.Items, tt.expectedItems) {
+				t.Errorf("Expected items %q, got %q", tt.expectedItems, ed.Completion.Items)
+			}
+			if ed.Completion.Start.Col != tt.expectedStart {
+				t.Errorf("Expected start column %d, got %d", tt.expectedStart, ed.Completion.Start.Col)
+			}
+		})
+	}
+}
+
+func TestSelectCompletion(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"apple apricot", "ap)"}
+	ed.CursorY, ed.CursorX = 1, 2
+	if !ed.StartCompletion(CompleteWord) {
+		t.Fatal("Expected completion to start")
+	}
+
+	steps := []struct {
+		index        int
+		expectedLine string
+	}{
+		{index: 0, expectedLine: "apple)"},
+		{index: 1, expectedLine: "apricot)"},
+		{index: 2, expectedLine: "ap)"}, // Wraps to the original text
+		{index: 3, expectedLine: "apple)"},
+		{index: -2, expectedLine: "apricot)"},
+	}
+	for _, step := range steps {
+		ed.SelectCompletion(step.index)
+		if ed.EditorContent[1] != step.expectedLine {
+			t.Errorf("Selecting %d: Expected line %q, got %q", step.index, step.expectedLine, ed.EditorContent[1])
+		}
+		if ed.CursorX != len(step.expectedLine)-1 {
+			t.Errorf("Selecting %d: Expected CursorX %d, got %d", step.index, len(step.expectedLine)-1, ed.CursorX)
+		}
+	}
+}
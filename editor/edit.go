@@ -0,0 +1,126 @@
+package editor
+
+import "strings"
+
+// OverwriteChar replaces the character under the cursor with char, or
+// appends it at the end of the line, and moves the cursor right. It returns
+// the character that was replaced, or 0 if char was appended.
+func (e *Editor) OverwriteChar(char byte) byte {
+	if e.beginChange() != nil {
+		return 0
+	}
+	e.ensureLineExists(e.CursorY)
+	line := e.EditorContent[e.CursorY]
+	if e.CursorX >= len(line) {
+		e.InsertChar(char)
+		return 0
+	}
+	replaced := line[e.CursorX]
+	e.EditorContent[e.CursorY] = line[:e.CursorX] + string(char) + line[e.CursorX+1:]
+	e.CursorX++
+	e.markChanged(LineChange{Line: e.CursorY, Old: 1, New: 1})
+	return replaced
+}
+
+// RestoreChar undoes an OverwriteChar to the left of the cursor: it puts back
+// the replaced character, or deletes the appended one if replaced is 0, and
+// moves the cursor left.
+func (e *Editor) RestoreChar(replaced byte) {
+	if e.beginChange() != nil {
+		return
+	}
+	if e.CursorX == 0 || e.CursorY >= len(e.EditorContent) {
+		return
+	}
+	line := e.EditorContent[e.CursorY]
+	e.CursorX--
+	if e.CursorX >= len(line) {
+		return
+	}
+	if replaced == 0 {
+		e.EditorContent[e.CursorY] = line[:e.CursorX] + line[e.CursorX+1:]
+	} else {
+		e.EditorContent[e.CursorY] = line[:e.CursorX] + string(replaced) + line[e.CursorX+1:]
+	}
+	e.markChanged(LineChange{Line: e.CursorY, Old: 1, New: 1})
+}
+
+// ReplaceChars replaces count characters starting at the cursor with char,
+// leaving the cursor on the last one. It returns false, changing nothing, if
+// the line is too short.
+func (e *Editor) ReplaceChars(char byte, count int) bool {
+	if e.beginChange() != nil {
+		return false
+	}
+	if e.CursorY >= len(e.EditorContent) {
+		return false
+	}
+	line := e.EditorContent[e.CursorY]
+	count = max(count, 1)
+	if e.CursorX+count > len(line) {
+		return false
+	}
+	e.EditorContent[e.CursorY] = line[:e.CursorX] + strings.Repeat(string(char), count) + line[e.CursorX+count:]
+	e.CursorX += count - 1
+	e.markChanged(LineChange{Line: e.CursorY, Old: 1, New: 1})
+	return true
+}
+
+// ToggleCase switches the case of count characters starting at the cursor
+// and moves the cursor past them. It returns false if the cursor is at the
+// end of the line.
+func (e *Editor) ToggleCase(count int) bool {
+	if e.beginChange() != nil {
+		return false
+	}
+	if e.CursorY >= len(e.EditorContent) || e.CursorX >= len(e.EditorContent[e.CursorY]) {
+		return false
+	}
+	line := []byte(e.EditorContent[e.CursorY])
+	end := min(e.CursorX+max(count, 1), len(line))
+	for i := e.CursorX; i < end; i++ {
+		switch c := line[i]; {
+		case c >= 'a' && c <= 'z':
+			line[i] = c - 'a' + 'A'
+		case c >= 'A' && c <= 'Z':
+			line[i] = c - 'A' + 'a'
+		}
+	}
+	e.EditorContent[e.CursorY] = string(line)
+	e.CursorX = end
+	e.markChanged(LineChange{Line: e.CursorY, Old: 1, New: 1})
+	return true
+}
+
+// JoinLines joins count lines (at least two) starting at the cursor line, as
+// Vim's J does: leading white space is removed from each joined line and a
+// single space separates the parts, except before a ')' or after existing
+// white space. The cursor is left where the last join happened. It returns
+// false if there is no line to join.
+func (e *Editor) JoinLines(count int) bool {
+	if e.beginChange() != nil {
+		return false
+	}
+	joins := max(count-1, 1)
+	if e.CursorY+1 >= len(e.EditorContent) {
+		return false
+	}
+	joins = min(joins, len(e.EditorContent)-1-e.CursorY)
+
+	line := e.EditorContent[e.CursorY]
+	col := 0
+	for _, next := range e.EditorContent[e.CursorY+1 : e.CursorY+1+joins] {
+		next = strings.TrimLeft(next, " \t")
+		col = len(line)
+		if next != "" && line != "" && !strings.HasSuffix(line, " ") && !strings.HasSuffix(line, "\t") && next[0] != ')' {
+			line += " "
+		}
+		line += next
+	}
+	e.EditorContent[e.CursorY] = line
+	e.EditorContent = append(e.EditorContent[:e.CursorY+1], e.EditorContent[e.CursorY+1+joins:]...)
+	e.adjustMarks(e.CursorY+1, -joins)
+	e.CursorX = col
+	e.markChanged(LineChange{Line: e.CursorY, Old: joins + 1, New: 1})
+	return true
+}
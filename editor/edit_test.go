@@ -0,0 +1,114 @@
+package editor
+
+import (
+	"testing"
+)
+
+func TestJoinLines(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		initialCursorY  int
+		count           int
+		expectedOK      bool
+		expectedContent []string
+		expectedCursorX int
+	}{
+		{
+			name:            "Join strips indent and adds space",
+			initialContent:  []string{"if x {", "    y()"},
+			count:           1,
+			expectedOK:      true,
+			expectedContent: []string{"if x { y()"},
+			expectedCursorX: 6,
+		},
+		{
+			name:            "No space before closing paren",
+			initialContent:  []string{"f(a", ")"},
+			count:           2,
+			expectedOK:      true,
+			expectedContent: []string{"f(a)"},
+			expectedCursorX: 3,
+		},
+		{
+			name:            "No extra space after trailing space",
+			initialContent:  []string{"a ", "b"},
+			count:           1,
+			expectedOK:      true,
+			expectedContent: []string{"a b"},
+			expectedCursorX: 2,
+		},
+		{
+			name:            "Join with empty line",
+			initialContent:  []string{"a", "", "b"},
+			count:           3,
+			expectedOK:      true,
+			expectedContent: []string{"a b"},
+			expectedCursorX: 1,
+		},
+		{
+			name:            "Count past end joins what is left",
+			initialContent:  []string{"a", "b"},
+			count:           5,
+			expectedOK:      true,
+			expectedContent: []string{"a b"},
+			expectedCursorX: 1,
+		},
+		{
+			name:            "Last line cannot be joined",
+			initialContent:  []string{"a", "b"},
+			initialCursorY:  1,
+			count:           1,
+			expectedOK:      false,
+			expectedContent: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = tt.initialContent
+			ed.CursorY = tt.initialCursorY
+
+			ok := ed.JoinLines(tt.count)
+
+			if ok != tt.expectedOK {
+				t.Errorf("Expected ok %t, got %t", tt.expectedOK, ok)
+			}
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d", len(tt.expectedContent), len(ed.EditorContent))
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ok && ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+		})
+	}
+}
+
+func TestOverwriteAndRestoreChar(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"ab"}
+	ed.CursorX = 1
+
+	replaced := []byte{ed.OverwriteChar('x'), ed.OverwriteChar('y')}
+	if ed.EditorContent[0] != "axy" {
+		t.Fatalf("Expected %q after overwriting, got %q", "axy", ed.EditorContent[0])
+	}
+	if replaced[0] != 'b' || replaced[1] != 0 {
+		t.Errorf("Expected replaced chars %q, got %q", []byte{'b', 0}, replaced)
+	}
+
+	ed.RestoreChar(replaced[1])
+	ed.RestoreChar(replaced[0])
+	if ed.EditorContent[0] != "ab" {
+		t.Errorf("Expected %q after restoring, got %q", "ab", ed.EditorContent[0])
+	}
+	if ed.CursorX != 1 {
+		t.Errorf("Expected CursorX 1, got %d", ed.CursorX)
+	}
+}
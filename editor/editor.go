@@ -1,6 +1,7 @@
 package editor
 
 import (
+	"errors"
 	"strings"
 	"time"
 )
@@ -15,13 +16,87 @@ type Editor struct {
 	ColOffset           int // Leftmost column of the file visible on screen (0-based file index)
 	EditorContent       []string
 	CurrentMode         Mode
-	Filename            string    // Name of the file being edited
-	CommandBuffer       string    // Stores the currently typed command
-	StatusMessage       string    // Message to show at the bottom
-	StatusMessageTime   time.Time // When the status message was set
-	ShouldQuit          bool      // Flag to signal graceful exit
-	IsDirty             bool      // Flag for unsaved changes
-	PromptOriginCommand string    // Command (:w or :wq) that triggered filename prompt
+	Filename            string                  // Name of the file being edited
+	CommandBuffer       string                  // Stores the currently typed command
+	CommandTail         int                     // Bytes of CommandBuffer after the command-line cursor
+	CommandPrompt       byte                    // ':' for an Ex command line, or '/' or '?' for a search pattern
+	History             map[byte][]HistoryEntry // Command lines and other input typed, oldest first, for each HistoryCommand, HistorySearch or HistoryInput kind
+	HistoryIndex        int                     // How far back in the history the command line is; 0 for the text typed
+	HistoryPrefix       string                  // Text typed before browsing the history, which the lines browsed start with
+	Wildmenu            *Wildmenu               // Command-line completion in progress
+	StatusMessage       string                  // Message to show at the bottom
+	StatusMessageTime   time.Time               // When the status message was set
+	ShouldQuit          bool                    // Flag to signal graceful exit
+	IsDirty             bool                    // Flag for unsaved changes
+	PromptOriginCommand string                  // Command (:w or :wq) that triggered filename prompt
+	PendingKeys         []byte                  // Keys of a Normal/Visual mode command still being typed
+	VisualStart         Position                // Anchor of the Visual mode selection
+	VisualLinewise      bool                    // Whether the Visual selection covers whole lines
+	Registers           map[byte]Register
+	ChangeTick          int       // Incremented on every buffer modification
+	Dot                 DotRepeat // Keys of the last change, for the '.' command
+	RecordingRegister   byte      // Register a macro is being recorded into, or 0
+	MacroKeys           []byte    // Keys recorded so far
+	LastMacro           byte      // Register last executed with @, for @@
+	MacroDepth          int       // Nesting depth of macro and :normal execution
+	KeyError            bool      // Set when a key sequence fails; stops macro playback
+	InsertCount         int       // Times to repeat text typed in Insert mode, from a count
+	InsertStart         Position  // Where Insert mode was entered
+	InsertOpensLine     bool      // Whether Insert mode was entered with o or O
+	ReplacedChars       []byte    // Characters overwritten in Replace mode, for Backspace
+	Marks               map[byte]Position
+	FileMarks           map[byte]FileMark
+	FilePositions       map[string]FileState      // Where the cursor was left in each file edited, by absolute path
+	JumpList            []Position                // Positions jumped from, oldest first
+	JumpIndex           int                       // Current place in JumpList; len(JumpList) when not navigating it
+	Options             map[string]any            // Global option values changed from their defaults
+	LocalOptions        map[string]any            // Buffer- and window-local option values set with :setlocal
+	Signs               []Sign                    // Markers shown in the sign column
+	SourceDepth         int                       // Nesting depth of :source
+	SyntaxAt            func(p Position) string   // Highlight group at p, such as "String" or "Comment", if a highlighter is installed
+	Completion          *Completion               // Insert mode completion in progress, shown in a popup menu
+	CtrlXPending        bool                      // Ctrl-X was typed in Insert mode and awaits the completion kind
+	OmniFunc            func() (int, []string)    // Completion column and candidates at the cursor for Ctrl-X Ctrl-O, if a language server provides them
+	ChangeListeners     []func(LineChange)        // Called after every change to the buffer
+	Output              []string                  // Lines of command output shown above the status bar until a key is pressed
+	UndoStack           []UndoState               // Buffer states before each undoable change, oldest first
+	RedoStack           []UndoState               // Buffer states undone, most recently undone last
+	UndoBase            UndoState                 // Buffer before the command in progress, saved by its first change
+	UndoStepOpen        bool                      // BeginUndoStep has begun a step that EndUndoStep has not ended
+	UndoTick            int                       // ChangeTick when the command in progress began
+	Formatters          map[string]string         // Shell command that formats the buffer on save, for each filetype
+	EventHandlers       map[string][]EventHandler // Handlers of each event, including autocommands
+	EventDepth          int                       // Nesting depth of events being fired
+	LastKeyTime         time.Time                 // When the last key was typed, for CursorHold
+	CursorHeld          bool                      // CursorHold has fired since the last key was typed
+	KeyMappings         map[Mode]*MappingTrie     // Key sequences mapped in each mode
+	MappedKeys          []byte                    // Keys typed so far that may be the start of a mapping
+	MappedKeysTime      time.Time                 // When MappedKeys were typed; they stop waiting after timeoutlen
+	MapDepth            int                       // Nesting depth of mappings being run
+	NoRemapDepth        int                       // Nesting depth of input that is not mapped, such as :normal! keys
+	ArgList             []string                  // Files named on the command line, edited in turn with :next
+	ArgIndex            int                       // Index in ArgList of the file being edited, or -1 if it is not one of them
+	QuitWarned          bool                      // :q has warned that files in ArgList were not edited
+	ReadonlyWarned      bool                      // The buffer was changed with readonly set, and a warning given
+	LastPattern         string                    // The last pattern searched for or used by an Ex command, which an empty pattern stands for
+	LastSearchBackward  bool                      // The last search was made with ?, so n searches backward
+	GlobalLines         []int                     // Lines :global has still to visit, or nil when it is not running
+}
+
+// DotRepeat tracks the keys that make up a change so that '.' can replay it.
+type DotRepeat struct {
+	Keys      []byte // Keys typed since the current Normal mode command began
+	StartTick int    // ChangeTick when the current command began
+	LastKeys  []byte // Keys of the last completed change
+	Replaying bool   // Set while '.' is replaying LastKeys
+}
+
+// LineChange is a change to the buffer, as told to the ChangeListeners: the
+// Old lines starting at Line were replaced by the New lines now there.
+type LineChange struct {
+	Line int
+	Old  int
+	New  int
 }
 
 // Mode defines the current state of the editor
@@ -32,6 +107,8 @@ const (
 	ModeInsert
 	ModeCommand
 	ModeFileNamePrompt // Mode for entering filename on save
+	ModeVisual         // Mode for selecting text
+	ModeReplace        // Mode for overwriting text
 )
 
 // NewEditor creates and initializes a new Editor instance.
@@ -45,6 +122,9 @@ func NewEditor(width, height int) *Editor {
 		ColOffset:     0,
 		EditorContent: []string{""},
 		CurrentMode:   ModeNormal,
+		Registers:     make(map[byte]Register),
+		Marks:         make(map[byte]Position),
+		FileMarks:     make(map[byte]FileMark),
 	}
 }
 
@@ -54,6 +134,36 @@ func (e *Editor) SetStatusMessage(msg string) {
 	e.StatusMessageTime = time.Now()
 }
 
+// ErrNotModifiable is returned for a change to a buffer whose modifiable
+// option is off.
+var ErrNotModifiable = errors.New("Cannot make changes, 'modifiable' is off")
+
+// CheckModifiable returns ErrNotModifiable, also showing it in the status
+// bar, if the buffer may not be changed.
+func (e *Editor) CheckModifiable() error {
+	if e.BoolOption("modifiable") {
+		return nil
+	}
+	e.SetStatusMessage(ErrNotModifiable.Error())
+	return ErrNotModifiable
+}
+
+// markChanged flags the buffer as modified, records the cursor position as
+// the '. mark and tells the ChangeListeners about change c. The first change
+// to a readonly buffer gives a warning.
+func (e *Editor) markChanged(c LineChange) {
+	if e.BoolOption("readonly") && !e.ReadonlyWarned {
+		e.SetStatusMessage("Warning: Changing a readonly file")
+		e.ReadonlyWarned = true
+	}
+	e.IsDirty = true
+	e.ChangeTick++
+	e.SetMark(MarkLastChange, Position{Line: e.CursorY, Col: e.CursorX})
+	for _, fn := range e.ChangeListeners {
+		fn(c)
+	}
+}
+
 // ensureLineExists appends empty lines if needed to reach target row y.
 func (e *Editor) ensureLineExists(y int) {
 	for len(e.EditorContent) <= y {
@@ -63,6 +173,9 @@ func (e *Editor) ensureLineExists(y int) {
 
 // InsertChar inserts a character at the current cursor position.
 func (e *Editor) InsertChar(char byte) {
+	if e.beginChange() != nil {
+		return
+	}
 	e.ensureLineExists(e.CursorY)
 	line := e.EditorContent[e.CursorY]
 	// TODO: Adjust for colOffset when inserting/deleting
@@ -73,11 +186,14 @@ func (e *Editor) InsertChar(char byte) {
 	}
 	e.EditorContent[e.CursorY] = line
 	e.CursorX++
-	e.IsDirty = true
+	e.markChanged(LineChange{Line: e.CursorY, Old: 1, New: 1})
 }
 
 // InsertNewline inserts a newline by splitting the current line.
 func (e *Editor) InsertNewline() {
+	if e.beginChange() != nil {
+		return
+	}
 	e.ensureLineExists(e.CursorY)
 	line := e.EditorContent[e.CursorY]
 	// TODO: Adjust for colOffset
@@ -89,14 +205,18 @@ func (e *Editor) InsertNewline() {
 	// Insert new line into EditorContent slice
 	nextLine := afterCursor
 	e.EditorContent = append(e.EditorContent[:e.CursorY+1], append([]string{nextLine}, e.EditorContent[e.CursorY+1:]...)...)
+	e.adjustMarks(e.CursorY+1, 1)
 
 	e.CursorY++
 	e.CursorX = 0
-	e.IsDirty = true
+	e.markChanged(LineChange{Line: e.CursorY - 1, Old: 1, New: 2})
 }
 
 // DeleteChar handles backspace: deleting char or joining lines.
 func (e *Editor) DeleteChar() {
+	if e.beginChange() != nil {
+		return
+	}
 	if e.CursorX == 0 && e.CursorY == 0 {
 		return
 	}
@@ -107,6 +227,7 @@ func (e *Editor) DeleteChar() {
 		originalLineLen = len(e.EditorContent[e.CursorY])
 	}
 
+	change := LineChange{Old: 1, New: 1}
 	if e.CursorX == 0 { // At start of a line (not the first line)
 		// Join with the previous line
 		prevLineIndex := e.CursorY - 1
@@ -116,8 +237,10 @@ func (e *Editor) DeleteChar() {
 		e.EditorContent[prevLineIndex] = prevLine + currentLine
 		// Remove current line
 		e.EditorContent = append(e.EditorContent[:e.CursorY], e.EditorContent[e.CursorY+1:]...)
+		e.adjustMarks(e.CursorY, -1)
 		e.CursorY--
 		e.CursorX = newCursorX
+		change.Old = 2
 	} else {
 		e.ensureLineExists(e.CursorY)
 		line := e.EditorContent[e.CursorY]
@@ -133,7 +256,8 @@ func (e *Editor) DeleteChar() {
 
 	// Check if content actually changed before marking dirty
 	if len(e.EditorContent) != originalContentLen || (e.CursorY < len(e.EditorContent) && len(e.EditorContent[e.CursorY]) != originalLineLen) {
-		e.IsDirty = true
+		change.Line = e.CursorY
+		e.markChanged(change)
 	}
 }
 
@@ -160,11 +284,63 @@ func (e *Editor) EnsureCursorBounds() {
 	}
 }
 
-// LoadFile reads a file into the editorContent buffer.
+// VisualRegion returns the text selected in Visual mode, from the anchor to
+// the cursor in buffer order.
+func (e *Editor) VisualRegion() Region {
+	start := e.VisualStart
+	end := Position{Line: e.CursorY, Col: e.CursorX}
+	if end.Line < start.Line || (end.Line == start.Line && end.Col < start.Col) {
+		start, end = end, start
+	}
+	return Region{Start: start, End: end, Linewise: e.VisualLinewise}
+}
+
+// ShowOutput displays lines of command output, such as hover text or the
+// output of a shell command, until the next key press.
+func (e *Editor) ShowOutput(lines []string) {
+	e.Output = lines
+}
+
+// ScrollToCursor adjusts RowOffset and ColOffset so that the cursor is on
+// screen, allowing for the gutter to the left of the text.
+func (e *Editor) ScrollToCursor() {
+	textRows := max(e.TermHeight-1, 1)
+	if e.CursorY < e.RowOffset {
+		e.RowOffset = e.CursorY
+	}
+	if e.CursorY >= e.RowOffset+textRows {
+		e.RowOffset = e.CursorY - textRows + 1
+	}
+
+	textWidth := e.TextWidth()
+	col := e.CursorDisplayCol()
+	if col < e.ColOffset {
+		e.ColOffset = col
+	}
+	if col >= e.ColOffset+textWidth {
+		e.ColOffset = col - textWidth + 1
+	}
+}
+
+// LoadFile reads a file into the editorContent buffer. The fileformat,
+// fileencoding and endofline options are set to match the content. Content
+// without a byte order mark is decoded with the charset that .editorconfig
+// gives the file, if any.
 func (e *Editor) LoadFile(content []byte) {
+	e.resetBufferOptions()
+	fileStr, encoding := decodeContent(content, e.editorConfigCharset())
+	_ = e.SetLocalOption("fileencoding", encoding)
+
 	// Replace CRLF with LF and split into lines
-	fileStr := string(content)
-	fileStr = strings.ReplaceAll(fileStr, "\r\n", "\n")
+	switch {
+	case strings.Contains(fileStr, "\r\n"):
+		_ = e.SetLocalOption("fileformat", "dos")
+		fileStr = strings.ReplaceAll(fileStr, "\r\n", "\n")
+	case strings.Contains(fileStr, "\r") && !strings.Contains(fileStr, "\n"):
+		_ = e.SetLocalOption("fileformat", "mac")
+		fileStr = strings.ReplaceAll(fileStr, "\r", "\n")
+	}
+	_ = e.SetLocalOption("endofline", fileStr == "" || strings.HasSuffix(fileStr, "\n"))
 	lines := strings.Split(fileStr, "\n")
 
 	// If the original content ended with a newline, Split leaves a trailing empty string.
@@ -181,12 +357,22 @@ func (e *Editor) LoadFile(content []byte) {
 		e.EditorContent = lines
 	}
 
+	// Marks and jumps belong to the previous contents.
+	e.Marks = make(map[byte]Position)
+	e.JumpList, e.JumpIndex = nil, 0
+	e.ClearUndo()
 	e.IsDirty = false
+	e.ReadonlyWarned = false
 }
 
-// ContentAsString joins the editor content into a single string for saving.
+// ContentAsString joins the editor content into a single string for saving,
+// using the line ending of the fileformat option. A final line ending is
+// added unless both endofline and fixendofline are off.
 func (e *Editor) ContentAsString() string {
-	content := strings.Join(e.EditorContent, "\n")
-	content += "\n" // Always add trailing newline for POSIX compatibility
+	eol := e.lineEnding()
+	content := strings.Join(e.EditorContent, eol)
+	if e.BoolOption("endofline") || e.BoolOption("fixendofline") {
+		content += eol // Add trailing newline for POSIX compatibility
+	}
 	return content
 }
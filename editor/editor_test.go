@@ -1,6 +1,7 @@
 package editor
 
 import (
+	"slices"
 	"testing"
 )
 
@@ -566,3 +567,45 @@ func TestContentAsString(t *testing.T) {
 		})
 	}
 }
+
+func TestNotModifiable(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"hello", "world"}
+	_ = ed.SetLocalOption("modifiable", false)
+
+	ed.CursorY, ed.CursorX = 0, 2
+	ed.InsertChar('x')
+	ed.InsertNewline()
+	ed.DeleteChar()
+	if !slices.Equal(ed.EditorContent, []string{"hello", "world"}) {
+		t.Errorf("Expected the buffer unchanged, got %q", ed.EditorContent)
+	}
+	if ed.IsDirty {
+		t.Errorf("Expected the buffer not dirty")
+	}
+	if ed.StatusMessage != ErrNotModifiable.Error() {
+		t.Errorf("Expected status %q, got %q", ErrNotModifiable.Error(), ed.StatusMessage)
+	}
+	if err := ed.CheckModifiable(); err != ErrNotModifiable {
+		t.Errorf("Expected ErrNotModifiable, got %v", err)
+	}
+}
+
+func TestReadonlyWarning(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"hello"}
+	_ = ed.SetLocalOption("readonly", true)
+
+	ed.InsertChar('x')
+	if ed.EditorContent[0] != "xhello" {
+		t.Errorf("Expected a readonly buffer to be changed, got %q", ed.EditorContent[0])
+	}
+	if ed.StatusMessage != "Warning: Changing a readonly file" {
+		t.Errorf("Expected a warning, got %q", ed.StatusMessage)
+	}
+	ed.StatusMessage = ""
+	ed.InsertChar('y')
+	if ed.StatusMessage != "" {
+		t.Errorf("Expected the warning only once, got %q", ed.StatusMessage)
+	}
+}
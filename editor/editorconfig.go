@@ -0,0 +1,268 @@
+package editor
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// editorConfigSection is one "[glob]" section of an .editorconfig file.
+type editorConfigSection struct {
+	glob  string
+	props map[string]string
+}
+
+// parseEditorConfig reads an .editorconfig file. Property names and values
+// are lowercased, as the specification asks.
+func parseEditorConfig(r io.Reader) (root bool, sections []editorConfigSection, err error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if line[0] == '[' && strings.HasSuffix(line, "]") {
+			sections = append(sections, editorConfigSection{glob: line[1 : len(line)-1], props: map[string]string{}})
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		key = strings.ToLower(strings.TrimSpace(key))
+		value = strings.ToLower(strings.TrimSpace(value))
+		if len(sections) == 0 {
+			if key == "root" {
+				root = value == "true"
+			}
+			continue
+		}
+		sections[len(sections)-1].props[key] = value
+	}
+	return root, sections, scanner.Err()
+}
+
+// numericRange matches the "{n1..n2}" form of an EditorConfig glob.
+var numericRange = regexp.MustCompile(`^\{(-?\d+)\.\.(-?\d+)\}`)
+
+// editorConfigPattern converts an EditorConfig glob to a regular expression
+// matched against a slash-separated path relative to the .editorconfig file.
+// It also returns the bounds of each {n1..n2} range, in the order of the
+// groups that capture them.
+func editorConfigPattern(glob string) (*regexp.Regexp, [][2]int, error) {
+	switch {
+	case strings.HasPrefix(glob, "/"):
+		glob = glob[1:]
+	case !strings.Contains(glob, "/"):
+		glob = "**/" + glob // A name without a slash matches in any directory
+	}
+
+	var re strings.Builder
+	var ranges [][2]int
+	braces := 0
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case c == '\\' && i+1 < len(glob):
+			i++
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		case strings.HasPrefix(glob[i:], "**/"):
+			re.WriteString("(?:.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			re.WriteString(".*")
+			i++
+		case c == '*':
+			re.WriteString("[^/]*")
+		case c == '?':
+			re.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i:], ']')
+			if end < 0 {
+				re.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			re.WriteString("[" + class + "]")
+			i += end
+		case c == '{':
+			if m := numericRange.FindStringSubmatch(glob[i:]); m != nil {
+				lo, _ := strconv.Atoi(m[1])
+				hi, _ := strconv.Atoi(m[2])
+				ranges = append(ranges, [2]int{lo, hi})
+				re.WriteString(`([+-]?\d+)`)
+				i += len(m[0]) - 1
+				continue
+			}
+			braces++
+			re.WriteString("(?:")
+		case c == '}' && braces > 0:
+			braces--
+			re.WriteString(")")
+		case c == ',' && braces > 0:
+			re.WriteString("|")
+		default:
+			re.WriteString(regexp.QuoteMeta(glob[i : i+1]))
+		}
+	}
+	pattern, err := regexp.Compile("^" + re.String() + "$")
+	return pattern, ranges, err
+}
+
+// editorConfigMatch reports whether path, relative to the directory of the
+// .editorconfig file, matches glob.
+func editorConfigMatch(glob, path string) bool {
+	pattern, ranges, err := editorConfigPattern(glob)
+	if err != nil {
+		return false
+	}
+	m := pattern.FindStringSubmatch(path)
+	if m == nil {
+		return false
+	}
+	// Numeric ranges are the only capturing groups.
+	for i, r := range ranges {
+		n, err := strconv.Atoi(m[i+1])
+		if err != nil || n < r[0] || n > r[1] {
+			return false
+		}
+	}
+	return true
+}
+
+// FindEditorConfig returns the EditorConfig properties for the file at path,
+// from the .editorconfig files in its directory and the directories above,
+// up to one marked "root = true". Properties in nearer files win.
+func FindEditorConfig(path string) (map[string]string, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+
+	props := map[string]string{}
+	for dir := filepath.Dir(abs); ; dir = filepath.Dir(dir) {
+		f, err := os.Open(filepath.Join(dir, ".editorconfig"))
+		if err == nil {
+			root, sections, err := parseEditorConfig(f)
+			f.Close()
+			if err != nil {
+				return nil, err
+			}
+			rel, _ := filepath.Rel(dir, abs)
+			rel = filepath.ToSlash(rel)
+			// Walk sections backwards so later sections, then nearer files, win.
+			for i := len(sections) - 1; i >= 0; i-- {
+				if !editorConfigMatch(sections[i].glob, rel) {
+					continue
+				}
+				for k, v := range sections[i].props {
+					if _, set := props[k]; !set {
+						props[k] = v
+					}
+				}
+			}
+			if root {
+				break
+			}
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	return props, nil
+}
+
+// editorConfigCharsets maps EditorConfig charset values to fileencoding.
+var editorConfigCharsets = map[string]string{
+	"utf-8":     "utf-8",
+	"utf-8-bom": "utf-8-bom",
+	"latin1":    "latin1",
+	"utf-16be":  "utf-16be",
+	"utf-16le":  "utf-16le",
+}
+
+// editorConfigCharset returns the fileencoding of the charset property that
+// the .editorconfig files give the current file, or "" if there is none.
+// An error reading them is left for ApplyEditorConfig to report.
+func (e *Editor) editorConfigCharset() string {
+	if e.Filename == "" {
+		return ""
+	}
+	props, err := FindEditorConfig(e.Filename)
+	if err != nil {
+		return ""
+	}
+	return editorConfigCharsets[props["charset"]]
+}
+
+// ApplyEditorConfig sets buffer-local options from the .editorconfig files
+// that apply to the current file. Unknown properties and values, and "unset",
+// are ignored.
+func (e *Editor) ApplyEditorConfig() error {
+	if e.Filename == "" {
+		return nil
+	}
+	props, err := FindEditorConfig(e.Filename)
+	if err != nil {
+		return err
+	}
+
+	set := func(name string, value any) {
+		_ = e.SetLocalOption(name, value)
+	}
+	switch props["indent_style"] {
+	case "tab":
+		set("expandtab", false)
+	case "space":
+		set("expandtab", true)
+	}
+	if n, err := strconv.Atoi(props["tab_width"]); err == nil && n > 0 {
+		set("tabstop", n)
+	}
+	switch size := props["indent_size"]; size {
+	case "tab":
+		set("shiftwidth", 0) // Use tabstop
+	default:
+		if n, err := strconv.Atoi(size); err == nil && n > 0 {
+			set("shiftwidth", n)
+			if _, ok := props["tab_width"]; !ok {
+				set("tabstop", n) // tab_width defaults to indent_size
+			}
+		}
+	}
+	switch props["end_of_line"] {
+	case "lf":
+		set("fileformat", "unix")
+	case "crlf":
+		set("fileformat", "dos")
+	case "cr":
+		set("fileformat", "mac")
+	}
+	if enc, ok := editorConfigCharsets[props["charset"]]; ok {
+		set("fileencoding", enc) // LoadFile has decoded the content with it
+	}
+	switch props["trim_trailing_whitespace"] {
+	case "true":
+		set("trimtrailingwhitespace", true)
+	case "false":
+		set("trimtrailingwhitespace", false)
+	}
+	switch props["insert_final_newline"] {
+	case "true":
+		set("fixendofline", true)
+	case "false":
+		set("fixendofline", false)
+		set("endofline", false)
+	}
+	return nil
+}
@@ -0,0 +1,166 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEditorConfigMatch(t *testing.T) {
+	tests := []struct {
+		glob     string
+		path     string
+		expected bool
+	}{
+		{glob: "*", path: "main.go", expected: true},
+		{glob: "*", path: "cmd/main.go", expected: true},
+		{glob: "*.go", path: "cmd/main.go", expected: true},
+		{glob: "*.go", path: "main.py", expected: false},
+		{glob: "*.{js,ts}", path: "web/app.ts", expected: true},
+		{glob: "*.{js,ts}", path: "web/app.go", expected: false},
+		{glob: "Makefile", path: "sub/Makefile", expected: true},
+		{glob: "lib/*.c", path: "lib/a.c", expected: true},
+		{glob: "lib/*.c", path: "src/lib/a.c", expected: false},
+		{glob: "lib/*.c", path: "lib/x/a.c", expected: false},
+		{glob: "lib/**.c", path: "lib/x/a.c", expected: true},
+		{glob: "/top.txt", path: "top.txt", expected: true},
+		{glob: "/top.txt", path: "sub/top.txt", expected: false},
+		{glob: "file?.txt", path: "file1.txt", expected: true},
+		{glob: "file[ab].txt", path: "fileb.txt", expected: true},
+		{glob: "file[!ab].txt", path: "fileb.txt", expected: false},
+		{glob: "test{1..10}.txt", path: "test7.txt", expected: true},
+		{glob: "test{1..10}.txt", path: "test11.txt", expected: false},
+		{glob: "a.b", path: "axb", expected: false},
+	}
+
+	for _, tt := range tests {
+		if got := editorConfigMatch(tt.glob, tt.path); got != tt.expected {
+			t.Errorf("Expected %q matching %q to be %t, got %t", tt.glob, tt.path, tt.expected, got)
+		}
+	}
+}
+
+// writeFile creates a file, and any missing directories, for a test.
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFindEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".editorconfig"), "root = true\n\n[*]\nindent_style = tab\nend_of_line = lf\n\n[*.py]\nindent_style = space\nindent_size = 4\n")
+	writeFile(t, filepath.Join(dir, "sub", ".editorconfig"), "# Nearer file\n[*.py]\nIndent_Size = 2\n[lib/**]\ncharset = LATIN1\n")
+
+	tests := []struct {
+		name     string
+		path     string
+		expected map[string]string
+	}{
+		{
+			name:     "Top level",
+			path:     filepath.Join(dir, "main.go"),
+			expected: map[string]string{"indent_style": "tab", "end_of_line": "lf"},
+		},
+		{
+			name:     "Later section wins",
+			path:     filepath.Join(dir, "tool.py"),
+			expected: map[string]string{"indent_style": "space", "indent_size": "4", "end_of_line": "lf"},
+		},
+		{
+			name:     "Nearer file wins",
+			path:     filepath.Join(dir, "sub", "tool.py"),
+			expected: map[string]string{"indent_style": "space", "indent_size": "2", "end_of_line": "lf"},
+		},
+		{
+			name:     "Path relative to nearer file",
+			path:     filepath.Join(dir, "sub", "lib", "x", "data.txt"),
+			expected: map[string]string{"indent_style": "tab", "end_of_line": "lf", "charset": "latin1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			props, err := FindEditorConfig(tt.path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(props) != len(tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, props)
+			}
+			for k, v := range tt.expected {
+				if props[k] != v {
+					t.Errorf("Expected %s = %q, got %q", k, v, props[k])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyEditorConfig(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, ".editorconfig"), `root = true
+[*.txt]
+indent_style = space
+indent_size = 4
+end_of_line = crlf
+charset = utf-8-bom
+trim_trailing_whitespace = true
+insert_final_newline = false
+[*.go]
+indent_style = tab
+indent_size = tab
+tab_width = 4
+end_of_line = unset
+[*.u16]
+charset = utf-16le
+`)
+
+	t.Run("All properties", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		ed.Filename = filepath.Join(dir, "notes.txt")
+		if err := ed.ApplyEditorConfig(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !ed.BoolOption("expandtab") || ed.IntOption("shiftwidth") != 4 || ed.IntOption("tabstop") != 4 {
+			t.Errorf("Expected expandtab sw=4 ts=4, got et=%t sw=%d ts=%d", ed.BoolOption("expandtab"), ed.IntOption("shiftwidth"), ed.IntOption("tabstop"))
+		}
+		if ff, fenc := ed.StringOption("fileformat"), ed.StringOption("fileencoding"); ff != "dos" || fenc != "utf-8-bom" {
+			t.Errorf("Expected ff=dos fenc=utf-8-bom, got ff=%s fenc=%s", ff, fenc)
+		}
+		if !ed.BoolOption("trimtrailingwhitespace") || ed.BoolOption("fixendofline") || ed.BoolOption("endofline") {
+			t.Error("Expected trimtrailingwhitespace, nofixendofline and noendofline")
+		}
+		if ed.GlobalOptionValue("expandtab") != false {
+			t.Error("Expected settings to be buffer-local")
+		}
+	})
+
+	t.Run("Charset decodes", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		ed.Filename = filepath.Join(dir, "wide.u16")
+		ed.LoadFile([]byte("h\x00\xe9\x00\n\x00")) // "hé\n" in UTF-16LE, without a byte order mark
+		if err := ed.ApplyEditorConfig(); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(ed.EditorContent) != 1 || ed.EditorContent[0] != "hé" || ed.StringOption("fileencoding") != "utf-16le" {
+			t.Errorf("Expected \"hé\" decoded from utf-16le, got %q with fenc=%s", ed.EditorContent, ed.StringOption("fileencoding"))
+		}
+	})
+
+	t.Run("Tab indent", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		ed.Filename = filepath.Join(dir, "main.go")
+		_ = ed.ApplyEditorConfig()
+		if ed.BoolOption("expandtab") || ed.ShiftWidth() != 4 || ed.IntOption("shiftwidth") != 0 {
+			t.Errorf("Expected noexpandtab and indent of tabstop 4, got sw=%d", ed.IntOption("shiftwidth"))
+		}
+		if ff := ed.StringOption("fileformat"); ff != "unix" {
+			t.Errorf("Expected unset end_of_line to leave fileformat %q, got %q", "unix", ff)
+		}
+	})
+}
@@ -0,0 +1,108 @@
+package editor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"strings"
+	"unicode/utf16"
+)
+
+// Byte order marks recognised when loading a file.
+var (
+	bomUTF8    = []byte{0xEF, 0xBB, 0xBF}
+	bomUTF16BE = []byte{0xFE, 0xFF}
+	bomUTF16LE = []byte{0xFF, 0xFE}
+)
+
+// decodeContent converts file content to UTF-8 text, using a byte order
+// mark if there is one, or else charset, the fileencoding the content is
+// known to have, if it is not "". It returns the text and the fileencoding
+// it was decoded from. Other content is kept byte for byte.
+func decodeContent(content []byte, charset string) (string, string) {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return string(content[len(bomUTF8):]), "utf-8-bom"
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return decodeUTF16(content[2:], binary.BigEndian), "utf-16be"
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return decodeUTF16(content[2:], binary.LittleEndian), "utf-16le"
+	case charset == "utf-16be":
+		return decodeUTF16(content, binary.BigEndian), charset
+	case charset == "utf-16le":
+		return decodeUTF16(content, binary.LittleEndian), charset
+	case charset != "":
+		return string(content), charset
+	}
+	return string(content), "utf-8"
+}
+
+// decodeUTF16 converts UTF-16 content in the given byte order to UTF-8.
+func decodeUTF16(content []byte, order binary.ByteOrder) string {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		units[i] = order.Uint16(content[2*i:])
+	}
+	return string(utf16.Decode(units))
+}
+
+// encodeUTF16 converts UTF-8 text to UTF-16 in the given byte order,
+// preceded by a byte order mark.
+func encodeUTF16(text string, order binary.AppendByteOrder, bom []byte) []byte {
+	out := append([]byte{}, bom...)
+	for _, u := range utf16.Encode([]rune(text)) {
+		out = order.AppendUint16(out, u)
+	}
+	return out
+}
+
+// EncodedContent returns the buffer as it should be written to disk, with
+// the line endings of the fileformat option and the encoding of the
+// fileencoding option. latin1 content is written byte for byte, as the
+// buffer holds it.
+func (e *Editor) EncodedContent() []byte {
+	text := e.ContentAsString()
+	switch e.StringOption("fileencoding") {
+	case "utf-8-bom":
+		return append(append([]byte{}, bomUTF8...), text...)
+	case "utf-16be":
+		return encodeUTF16(text, binary.BigEndian, bomUTF16BE)
+	case "utf-16le":
+		return encodeUTF16(text, binary.LittleEndian, bomUTF16LE)
+	}
+	return []byte(text)
+}
+
+// lineEnding returns the line separator for the fileformat option.
+func (e *Editor) lineEnding() string {
+	switch e.StringOption("fileformat") {
+	case "dos":
+		return "\r\n"
+	case "mac":
+		return "\r"
+	}
+	return "\n"
+}
+
+// TrimTrailingWhitespace removes spaces and tabs from the end of every line.
+// It returns true if anything was removed.
+func (e *Editor) TrimTrailingWhitespace() bool {
+	if e.beginChange() != nil {
+		return false
+	}
+	first, last := -1, -1
+	for i, line := range e.EditorContent {
+		if trimmed := strings.TrimRight(line, " \t"); trimmed != line {
+			e.EditorContent[i] = trimmed
+			if first < 0 {
+				first = i
+			}
+			last = i
+		}
+	}
+	if first < 0 {
+		return false
+	}
+	e.CursorX = min(e.CursorX, len(e.EditorContent[e.CursorY]))
+	e.markChanged(LineChange{Line: first, Old: last - first + 1, New: last - first + 1})
+	return true
+}
@@ -0,0 +1,71 @@
+package editor
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestLoadFileFormat(t *testing.T) {
+	tests := []struct {
+		name             string
+		content          []byte
+		expectedContent  []string
+		expectedFormat   string
+		expectedEncoding string
+		expectedEOL      bool
+	}{
+		{name: "Unix", content: []byte("a\nb\n"), expectedContent: []string{"a", "b"}, expectedFormat: "unix", expectedEncoding: "utf-8", expectedEOL: true},
+		{name: "DOS", content: []byte("a\r\nb\r\n"), expectedContent: []string{"a", "b"}, expectedFormat: "dos", expectedEncoding: "utf-8", expectedEOL: true},
+		{name: "Mac", content: []byte("a\rb"), expectedContent: []string{"a", "b"}, expectedFormat: "mac", expectedEncoding: "utf-8", expectedEOL: false},
+		{name: "No final newline", content: []byte("a\nb"), expectedContent: []string{"a", "b"}, expectedFormat: "unix", expectedEncoding: "utf-8", expectedEOL: false},
+		{name: "UTF-8 BOM", content: []byte("\xEF\xBB\xBFa\n"), expectedContent: []string{"a"}, expectedFormat: "unix", expectedEncoding: "utf-8-bom", expectedEOL: true},
+		{name: "UTF-16LE", content: []byte("\xFF\xFEa\x00\n\x00"), expectedContent: []string{"a"}, expectedFormat: "unix", expectedEncoding: "utf-16le", expectedEOL: true},
+		{name: "UTF-16BE", content: []byte("\xFE\xFF\x00a\x00\n"), expectedContent: []string{"a"}, expectedFormat: "unix", expectedEncoding: "utf-16be", expectedEOL: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.LoadFile(tt.content)
+			if len(ed.EditorContent) != len(tt.expectedContent) || ed.EditorContent[0] != tt.expectedContent[0] {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, ed.EditorContent)
+			}
+			if ff := ed.StringOption("fileformat"); ff != tt.expectedFormat {
+				t.Errorf("Expected fileformat %q, got %q", tt.expectedFormat, ff)
+			}
+			if fenc := ed.StringOption("fileencoding"); fenc != tt.expectedEncoding {
+				t.Errorf("Expected fileencoding %q, got %q", tt.expectedEncoding, fenc)
+			}
+			if eol := ed.BoolOption("endofline"); eol != tt.expectedEOL {
+				t.Errorf("Expected endofline %t, got %t", tt.expectedEOL, eol)
+			}
+
+			// Saving writes the file back unchanged.
+			if !tt.expectedEOL {
+				_ = ed.SetOption("fixendofline", false)
+			}
+			if out := ed.EncodedContent(); !bytes.Equal(out, tt.content) {
+				t.Errorf("Expected saved content %q, got %q", tt.content, out)
+			}
+		})
+	}
+}
+
+func TestTrimTrailingWhitespace(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"a  ", "b", "c\t \t"}
+	ed.CursorY, ed.CursorX = 2, 4
+
+	if !ed.TrimTrailingWhitespace() {
+		t.Fatal("Expected whitespace to be trimmed")
+	}
+	if ed.EditorContent[0] != "a" || ed.EditorContent[2] != "c" {
+		t.Errorf("Expected trimmed lines, got %q", ed.EditorContent)
+	}
+	if ed.CursorX != 1 {
+		t.Errorf("Expected CursorX 1, got %d", ed.CursorX)
+	}
+	if ed.TrimTrailingWhitespace() {
+		t.Error("Expected nothing to trim the second time")
+	}
+}
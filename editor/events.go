@@ -0,0 +1,124 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// Events fired by the editor, for autocommands and plugins to hook into.
+const (
+	EventBufRead      = "BufRead"      // After a file is loaded and its options set
+	EventBufNewFile   = "BufNewFile"   // After starting to edit a file that does not exist
+	EventFileType     = "FileType"     // After the filetype is set; matched against the filetype
+	EventBufWritePre  = "BufWritePre"  // Before the buffer is written
+	EventBufWritePost = "BufWritePost" // After the buffer is written
+	EventInsertEnter  = "InsertEnter"  // After entering Insert or Replace mode
+	EventInsertLeave  = "InsertLeave"  // After leaving Insert or Replace mode
+	EventModeChanged  = "ModeChanged"  // After any mode change; matched against "old:new", such as "n:i"
+	EventCursorHold   = "CursorHold"   // After no key is typed for updatetime in Normal mode
+	EventCursorHoldI  = "CursorHoldI"  // After no key is typed for updatetime in Insert mode
+	EventQuitPre      = "QuitPre"      // When a quit command runs, before checking for unsaved changes
+	EventVimLeavePre  = "VimLeavePre"  // Before the editor exits
+)
+
+// EventNames lists the events in the order they are described above.
+var EventNames = []string{
+	EventBufRead, EventBufNewFile, EventFileType, EventBufWritePre, EventBufWritePost,
+	EventInsertEnter, EventInsertLeave, EventModeChanged, EventCursorHold, EventCursorHoldI,
+	EventQuitPre, EventVimLeavePre,
+}
+
+// maxEventDepth limits how deeply handlers may fire events, so that a
+// plugin handler that causes its own event still stops.
+const maxEventDepth = 10
+
+// Event describes an event passed to its handlers.
+type Event struct {
+	Name  string
+	File  string // The file being edited
+	Match string // What handler patterns are matched against, usually File
+}
+
+// EventHandler runs when an event fires for a match of its pattern. It is
+// an autocommand if it has a command.
+type EventHandler struct {
+	Pattern string // Comma-separated file patterns; "" matches everything
+	Command string // Ex command of an autocommand, for listing
+	Func    func(ev Event)
+}
+
+// On adds a handler for the named event, for any file. Handlers run in the
+// order they were added.
+func (e *Editor) On(name string, fn func(ev Event)) {
+	e.AddEventHandler(name, EventHandler{Func: fn})
+}
+
+// AddEventHandler adds a handler for the named event.
+func (e *Editor) AddEventHandler(name string, h EventHandler) {
+	if e.EventHandlers == nil {
+		e.EventHandlers = make(map[string][]EventHandler)
+	}
+	e.EventHandlers[name] = append(e.EventHandlers[name], h)
+}
+
+// RemoveAutocmds removes the autocommands of the named event whose pattern
+// is pattern, or all of them if pattern is "". Other handlers stay. It
+// returns the number removed.
+func (e *Editor) RemoveAutocmds(name, pattern string) int {
+	handlers := e.EventHandlers[name]
+	kept := handlers[:0]
+	for _, h := range handlers {
+		if h.Command == "" || pattern != "" && h.Pattern != pattern {
+			kept = append(kept, h)
+		}
+	}
+	removed := len(handlers) - len(kept)
+	clear(handlers[len(kept):])
+	e.EventHandlers[name] = kept
+	return removed
+}
+
+// Fire runs the handlers of the named event for the file being edited.
+func (e *Editor) Fire(name string) {
+	e.FireEvent(Event{Name: name, File: e.Filename, Match: e.Filename})
+}
+
+// FireEvent runs the handlers of ev.Name whose pattern matches ev.Match.
+// As in Vim, autocommands do not nest: an event fired while the handlers of
+// another run does not run autocommands, only other handlers.
+func (e *Editor) FireEvent(ev Event) {
+	if e.EventDepth >= maxEventDepth {
+		return
+	}
+	e.EventDepth++
+	defer func() { e.EventDepth-- }()
+	for _, h := range e.EventHandlers[ev.Name] {
+		if (h.Command == "" || e.EventDepth == 1) && MatchPattern(h.Pattern, ev.Match) {
+			h.Func(ev)
+		}
+	}
+}
+
+// MatchPattern reports whether name matches one of a comma-separated list
+// of glob patterns. A pattern without a "/" may match just the last element
+// of name, so "*.go" matches "src/main.go". An empty pattern matches
+// anything.
+func MatchPattern(pattern, name string) bool {
+	if pattern == "" {
+		return true
+	}
+	for _, p := range strings.Split(pattern, ",") {
+		if p == "*" {
+			return true
+		}
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+		if !strings.Contains(p, "/") {
+			if ok, _ := filepath.Match(p, filepath.Base(name)); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
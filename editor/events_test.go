@@ -0,0 +1,77 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern  string
+		name     string
+		expected bool
+	}{
+		{"", "a.go", true},
+		{"*", "/src/a.go", true},
+		{"*.go", "/src/a.go", true},
+		{"*.go", "a.txt", false},
+		{"*.c,*.h", "/src/a.h", true},
+		{"/src/*.go", "/src/a.go", true},
+		{"/lib/*.go", "/src/a.go", false},
+		{"Makefile", "/src/Makefile", true},
+		{"*:i", "n:i", true},
+		{"i:*", "n:i", false},
+	}
+	for _, tt := range tests {
+		if got := MatchPattern(tt.pattern, tt.name); got != tt.expected {
+			t.Errorf("MatchPattern(%q, %q): expected %v, got %v", tt.pattern, tt.name, tt.expected, got)
+		}
+	}
+}
+
+func TestFire(t *testing.T) {
+	e := NewEditor(80, 24)
+	e.Filename = "/src/main.go"
+	var calls []string
+	handler := func(label string) func(ev Event) {
+		return func(ev Event) { calls = append(calls, label+":"+ev.Name+":"+ev.Match) }
+	}
+	e.On(EventBufWritePre, handler("plugin"))
+	e.AddEventHandler(EventBufWritePre, EventHandler{Pattern: "*.go", Command: "a", Func: handler("go")})
+	e.AddEventHandler(EventBufWritePre, EventHandler{Pattern: "*.py", Command: "b", Func: handler("py")})
+	e.AddEventHandler(EventBufWritePost, EventHandler{Pattern: "*", Command: "c", Func: handler("all")})
+
+	e.Fire(EventBufWritePre)
+	e.Fire(EventBufWritePost)
+	if want := "plugin:BufWritePre:/src/main.go,go:BufWritePre:/src/main.go,all:BufWritePost:/src/main.go"; strings.Join(calls, ",") != want {
+		t.Errorf("Expected calls %q, got %q", want, strings.Join(calls, ","))
+	}
+
+	if n := e.RemoveAutocmds(EventBufWritePre, "*.go"); n != 1 {
+		t.Errorf("Expected 1 autocommand removed, got %d", n)
+	}
+	if n := e.RemoveAutocmds(EventBufWritePre, ""); n != 1 {
+		t.Errorf("Expected 1 autocommand removed, got %d", n)
+	}
+	if len(e.EventHandlers[EventBufWritePre]) != 1 {
+		t.Errorf("Expected the plugin handler to stay, got %d handlers", len(e.EventHandlers[EventBufWritePre]))
+	}
+
+	// Autocommands do not run for events fired by handlers.
+	calls = nil
+	e.AddEventHandler(EventQuitPre, EventHandler{Pattern: "*", Command: "d", Func: handler("nested")})
+	e.On(EventBufWritePost, func(ev Event) { e.Fire(EventQuitPre) })
+	e.Fire(EventBufWritePost)
+	if want := "all:BufWritePost:/src/main.go"; strings.Join(calls, ",") != want {
+		t.Errorf("Expected calls %q, got %q", want, strings.Join(calls, ","))
+	}
+	e.RemoveAutocmds(EventQuitPre, "")
+
+	// A handler that fires its own event stops.
+	depth := 0
+	e.On(EventQuitPre, func(ev Event) { depth++; e.Fire(EventQuitPre) })
+	e.Fire(EventQuitPre)
+	if depth != maxEventDepth {
+		t.Errorf("Expected %d nested calls, got %d", maxEventDepth, depth)
+	}
+}
@@ -0,0 +1,53 @@
+package editor
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// filetypeExtensions maps file name extensions to filetypes.
+var filetypeExtensions = map[string]string{
+	".c":    "c",
+	".h":    "c",
+	".cc":   "cpp",
+	".cpp":  "cpp",
+	".hpp":  "cpp",
+	".cs":   "cs",
+	".css":  "css",
+	".go":   "go",
+	".html": "html",
+	".java": "java",
+	".js":   "javascript",
+	".json": "json",
+	".md":   "markdown",
+	".py":   "python",
+	".rs":   "rust",
+	".sh":   "sh",
+	".ts":   "typescript",
+	".yaml": "yaml",
+	".yml":  "yaml",
+}
+
+// filetypeNames maps whole file names to filetypes.
+var filetypeNames = map[string]string{
+	"Makefile":   "make",
+	"makefile":   "make",
+	"Dockerfile": "dockerfile",
+	"go.mod":     "gomod",
+}
+
+// DetectFiletype returns the filetype for a file name, or "" if it is not
+// recognised.
+func DetectFiletype(filename string) string {
+	base := filepath.Base(filename)
+	if ft, ok := filetypeNames[base]; ok {
+		return ft
+	}
+	return filetypeExtensions[strings.ToLower(filepath.Ext(base))]
+}
+
+// SetFiletype sets the buffer-local filetype option from the current file
+// name.
+func (e *Editor) SetFiletype() {
+	_ = e.SetLocalOption("filetype", DetectFiletype(e.Filename))
+}
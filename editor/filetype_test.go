@@ -0,0 +1,22 @@
+package editor
+
+import "testing"
+
+func TestDetectFiletype(t *testing.T) {
+	tests := []struct {
+		filename string
+		expected string
+	}{
+		{filename: "main.go", expected: "go"},
+		{filename: "/src/app/Tool.PY", expected: "python"},
+		{filename: "lib/Makefile", expected: "make"},
+		{filename: "notes.txt", expected: ""},
+		{filename: "", expected: ""},
+	}
+
+	for _, tt := range tests {
+		if ft := DetectFiletype(tt.filename); ft != tt.expected {
+			t.Errorf("Expected filetype %q for %q, got %q", tt.expected, tt.filename, ft)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package editor
+
+import "strconv"
+
+// Sign is a marker shown in the sign column next to a line, such as a
+// diagnostic or a diff marker.
+type Sign struct {
+	Line    int    // 0-based buffer line
+	Text    string // Up to two characters
+	Group   string // Source of the sign, e.g. "diagnostics" or "diff"
+	Message string // Shown in the status bar when the cursor is on the line
+}
+
+// SetSigns replaces all signs of group with signs.
+func (e *Editor) SetSigns(group string, signs []Sign) {
+	var kept []Sign
+	for _, s := range e.Signs {
+		if s.Group != group {
+			kept = append(kept, s)
+		}
+	}
+	for _, s := range signs {
+		s.Group = group
+		kept = append(kept, s)
+	}
+	e.Signs = kept
+}
+
+// SignAt returns the first sign placed on line.
+func (e *Editor) SignAt(line int) (Sign, bool) {
+	for _, s := range e.Signs {
+		if s.Line == line {
+			return s, true
+		}
+	}
+	return Sign{}, false
+}
+
+// ShowSignColumn reports whether the sign column is drawn, according to the
+// signcolumn option: always, never, or when there are signs ("auto").
+func (e *Editor) ShowSignColumn() bool {
+	switch e.StringOption("signcolumn") {
+	case "yes":
+		return true
+	case "no":
+		return false
+	}
+	return len(e.Signs) > 0
+}
+
+// ShowLineNumbers reports whether absolute or relative line numbers are drawn.
+func (e *Editor) ShowLineNumbers() bool {
+	return e.BoolOption("number") || e.BoolOption("relativenumber")
+}
+
+// NumberWidth returns the number of digits used for line numbers: enough for
+// the last line, and at least three.
+func (e *Editor) NumberWidth() int {
+	return max(len(strconv.Itoa(len(e.EditorContent))), 3)
+}
+
+// GutterWidth returns the number of screen columns to the left of the text
+// taken by the sign column and the line numbers.
+func (e *Editor) GutterWidth() int {
+	width := 0
+	if e.ShowSignColumn() {
+		width += 2
+	}
+	if e.ShowLineNumbers() {
+		width += e.NumberWidth() + 1 // Digits and a separating space
+	}
+	return width
+}
+
+// TextWidth returns the number of screen columns available for text.
+func (e *Editor) TextWidth() int {
+	return max(e.TermWidth-e.GutterWidth(), 1)
+}
+
+// LineNumberLabel returns the number shown in the gutter for line. With
+// relativenumber lines show their distance from the cursor line; if number
+// is also set (hybrid mode) the cursor line shows its absolute number.
+func (e *Editor) LineNumberLabel(line int) string {
+	relative := e.BoolOption("relativenumber")
+	if !relative || (line == e.CursorY && e.BoolOption("number")) {
+		return strconv.Itoa(line + 1)
+	}
+	distance := line - e.CursorY
+	if distance < 0 {
+		distance = -distance
+	}
+	return strconv.Itoa(distance)
+}
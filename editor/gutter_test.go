@@ -0,0 +1,94 @@
+package editor
+
+import "testing"
+
+func TestGutterWidth(t *testing.T) {
+	tests := []struct {
+		name          string
+		options       map[string]any
+		signs         []Sign
+		lines         int
+		expectedWidth int
+	}{
+		{name: "No gutter", lines: 5, expectedWidth: 0},
+		{name: "Line numbers", options: map[string]any{"number": true}, lines: 5, expectedWidth: 4},
+		{name: "Relative numbers", options: map[string]any{"relativenumber": true}, lines: 5, expectedWidth: 4},
+		{name: "Wide line numbers", options: map[string]any{"number": true}, lines: 12345, expectedWidth: 6},
+		{name: "Automatic sign column", signs: []Sign{{Line: 0, Text: "E"}}, lines: 5, expectedWidth: 2},
+		{name: "Sign column always", options: map[string]any{"signcolumn": "yes"}, lines: 5, expectedWidth: 2},
+		{name: "Sign column never", options: map[string]any{"signcolumn": "no"}, signs: []Sign{{Line: 0, Text: "E"}}, lines: 5, expectedWidth: 0},
+		{name: "Signs and numbers", options: map[string]any{"number": true, "signcolumn": "yes"}, lines: 5, expectedWidth: 6},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = make([]string, tt.lines)
+			for name, value := range tt.options {
+				if err := ed.SetOption(name, value); err != nil {
+					t.Fatalf("Unexpected error setting %s: %v", name, err)
+				}
+			}
+			ed.SetSigns("test", tt.signs)
+
+			if w := ed.GutterWidth(); w != tt.expectedWidth {
+				t.Errorf("Expected gutter width %d, got %d", tt.expectedWidth, w)
+			}
+			if w := ed.TextWidth(); w != 80-tt.expectedWidth {
+				t.Errorf("Expected text width %d, got %d", 80-tt.expectedWidth, w)
+			}
+		})
+	}
+}
+
+func TestLineNumberLabel(t *testing.T) {
+	tests := []struct {
+		name           string
+		number         bool
+		relative       bool
+		line           int
+		expectedNumber string
+	}{
+		{name: "Absolute", number: true, line: 0, expectedNumber: "1"},
+		{name: "Relative above", relative: true, line: 0, expectedNumber: "2"},
+		{name: "Relative below", relative: true, line: 5, expectedNumber: "3"},
+		{name: "Relative cursor line", relative: true, line: 2, expectedNumber: "0"},
+		{name: "Hybrid cursor line", number: true, relative: true, line: 2, expectedNumber: "3"},
+		{name: "Hybrid other line", number: true, relative: true, line: 4, expectedNumber: "2"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = make([]string, 10)
+			ed.CursorY = 2
+			_ = ed.SetOption("number", tt.number)
+			_ = ed.SetOption("relativenumber", tt.relative)
+
+			if label := ed.LineNumberLabel(tt.line); label != tt.expectedNumber {
+				t.Errorf("Expected label %q, got %q", tt.expectedNumber, label)
+			}
+		})
+	}
+}
+
+func TestSigns(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"a", "b", "c"}
+	ed.SetSigns("diagnostics", []Sign{{Line: 2, Text: "E"}})
+	ed.SetSigns("diff", []Sign{{Line: 0, Text: "+"}})
+	ed.SetSigns("diagnostics", []Sign{{Line: 1, Text: "W"}})
+
+	if len(ed.Signs) != 2 {
+		t.Fatalf("Expected 2 signs after replacing a group, got %d", len(ed.Signs))
+	}
+	if s, ok := ed.SignAt(1); !ok || s.Text != "W" || s.Group != "diagnostics" {
+		t.Errorf("Expected diagnostics sign W on line 1, got %+v", s)
+	}
+
+	ed.CursorY = 0
+	ed.InsertNewline()
+	if _, ok := ed.SignAt(2); !ok {
+		t.Error("Expected sign to move down with its line")
+	}
+}
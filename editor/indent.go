@@ -0,0 +1,187 @@
+package editor
+
+import (
+	"slices"
+	"strings"
+)
+
+// IndentWidth returns the number of screen columns taken by the leading white
+// space of line.
+func (e *Editor) IndentWidth(line string) int {
+	return e.DisplayCol(line, FirstNonBlank(line))
+}
+
+// MakeIndent returns white space that is width columns wide: tabs and
+// spaces, or only spaces with expandtab.
+func (e *Editor) MakeIndent(width int) string {
+	if e.BoolOption("expandtab") {
+		return strings.Repeat(" ", max(width, 0))
+	}
+	return e.whiteSpaceBetween(0, width)
+}
+
+// SetIndent replaces the leading white space of line y with an indent width
+// columns wide, keeping the cursor on the same text.
+func (e *Editor) SetIndent(y, width int) {
+	if e.beginChange() != nil {
+		return
+	}
+	line := e.EditorContent[y]
+	old := FirstNonBlank(line)
+	indent := e.MakeIndent(width)
+	if indent == line[:old] {
+		return
+	}
+	e.EditorContent[y] = indent + line[old:]
+	if y == e.CursorY {
+		e.CursorX = max(e.CursorX-old, 0) + len(indent)
+	}
+	e.markChanged(LineChange{Line: y, Old: 1, New: 1})
+}
+
+// ShiftLines indents lines start to end by count levels of shiftwidth, or
+// removes that much indent if count is negative, as >> and << do. Empty
+// lines are left alone. The cursor moves to the first non-blank of start.
+func (e *Editor) ShiftLines(start, end, count int) {
+	for y := start; y <= end && y < len(e.EditorContent); y++ {
+		if e.EditorContent[y] == "" {
+			continue
+		}
+		e.SetIndent(y, max(e.IndentWidth(e.EditorContent[y])+count*e.ShiftWidth(), 0))
+	}
+	e.CursorY = start
+	e.CursorX = FirstNonBlank(e.EditorContent[start])
+}
+
+// indentRules describes how the lines of a filetype affect the indent of
+// the lines that follow them. Brackets always open and close levels.
+type indentRules struct {
+	comment     string   // Start of a line comment, where bracket counting stops
+	colonBlocks bool     // A line ending in ':' opens a level, and indent is significant
+	dedentWords []string // Statements that end the current block
+}
+
+// defaultIndentRules are used for C-like and unknown filetypes.
+var defaultIndentRules = indentRules{comment: "//"}
+
+// filetypeIndentRules holds the rules of filetypes that differ from the
+// defaults.
+var filetypeIndentRules = map[string]indentRules{
+	"python": {comment: "#", colonBlocks: true, dedentWords: []string{"return", "pass", "break", "continue", "raise"}},
+	"sh":     {comment: "#"},
+	"make":   {comment: "#"},
+	"yaml":   {comment: "#"},
+}
+
+// indentRules returns the indent rules for the buffer's filetype.
+func (e *Editor) indentRules() indentRules {
+	if rules, ok := filetypeIndentRules[e.StringOption("filetype")]; ok {
+		return rules
+	}
+	return defaultIndentRules
+}
+
+// bracketBalance counts the brackets of line outside strings and comments.
+// It returns the number of closing brackets the line starts with, and the
+// number of levels the rest of the line opens (negative if it closes more
+// than it opens).
+func bracketBalance(line string, rules indentRules) (leading, net int) {
+	text := strings.TrimLeft(line, " \t")
+	for leading < len(text) && strings.IndexByte(")]}", text[leading]) >= 0 {
+		leading++
+	}
+	var quote byte
+	for i := leading; i < len(text); i++ {
+		c := text[i]
+		switch {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'' || c == '`':
+			quote = c
+		case rules.comment != "" && strings.HasPrefix(text[i:], rules.comment):
+			return leading, net
+		case strings.IndexByte("([{", c) >= 0:
+			net++
+		case strings.IndexByte(")]}", c) >= 0:
+			net--
+		}
+	}
+	return leading, net
+}
+
+// prevNonBlank returns the last line before y that is not blank, or -1.
+func (e *Editor) prevNonBlank(y int) int {
+	for y--; y >= 0; y-- {
+		if strings.TrimSpace(e.EditorContent[y]) != "" {
+			return y
+		}
+	}
+	return -1
+}
+
+// ComputeIndent returns the indent width for line y implied by the line
+// above it: that line's indent, one level more for each bracket it leaves
+// open (or a trailing ':' in Python), one less after a block-ending
+// statement, and one less for each closing bracket line y starts with.
+func (e *Editor) ComputeIndent(y int) int {
+	prev := e.prevNonBlank(y)
+	if prev < 0 {
+		return 0
+	}
+	rules := e.indentRules()
+	prevLine := e.EditorContent[prev]
+	indent := e.IndentWidth(prevLine)
+
+	_, net := bracketBalance(prevLine, rules)
+	code := strings.TrimSpace(prevLine)
+	if rules.comment != "" {
+		if i := strings.Index(code, rules.comment); i >= 0 {
+			code = strings.TrimSpace(code[:i])
+		}
+	}
+	if rules.colonBlocks && strings.HasSuffix(code, ":") {
+		net++
+	}
+	if first, _, _ := strings.Cut(code, " "); slices.Contains(rules.dedentWords, first) {
+		net--
+	}
+
+	leading := 0
+	if y < len(e.EditorContent) {
+		leading, _ = bracketBalance(e.EditorContent[y], rules)
+	}
+	return max(indent+(net-leading)*e.ShiftWidth(), 0)
+}
+
+// ReindentLines sets the indent of lines start to end from the lines above
+// them, as the = operator does. Where indent is significant (Python) and no
+// rule calls for a change, a line keeps its indent if that is already less
+// than the line above. Blank lines are emptied. The cursor moves to the
+// first non-blank of start.
+func (e *Editor) ReindentLines(start, end int) {
+	if e.beginChange() != nil {
+		return
+	}
+	significant := e.indentRules().colonBlocks
+	for y := start; y <= end && y < len(e.EditorContent); y++ {
+		line := e.EditorContent[y]
+		if strings.TrimSpace(line) == "" {
+			if line != "" {
+				e.EditorContent[y] = ""
+				e.markChanged(LineChange{Line: y, Old: 1, New: 1})
+			}
+			continue
+		}
+		width := e.ComputeIndent(y)
+		if prev := e.prevNonBlank(y); significant && prev >= 0 && width == e.IndentWidth(e.EditorContent[prev]) {
+			width = min(width, e.IndentWidth(line))
+		}
+		e.SetIndent(y, width)
+	}
+	e.CursorY = start
+	e.CursorX = FirstNonBlank(e.EditorContent[start])
+}
@@ -0,0 +1,124 @@
+package editor
+
+import "testing"
+
+func TestShiftLines(t *testing.T) {
+	tests := []struct {
+		name            string
+		expandtab       bool
+		content         []string
+		start, end      int
+		count           int
+		expectedContent []string
+	}{
+		{name: "Right with spaces", expandtab: true, content: []string{"a", "  b"}, start: 0, end: 1, count: 1, expectedContent: []string{"    a", "      b"}},
+		{name: "Right with tabs", content: []string{"    a"}, start: 0, end: 0, count: 1, expectedContent: []string{"\ta"}},
+		{name: "Two levels", expandtab: true, content: []string{"a"}, start: 0, end: 0, count: 2, expectedContent: []string{"        a"}},
+		{name: "Left", content: []string{"\t  a", "  b"}, start: 0, end: 1, count: -1, expectedContent: []string{"      a", "b"}},
+		{name: "Empty lines unchanged", expandtab: true, content: []string{"a", "", "b"}, start: 0, end: 2, count: 1, expectedContent: []string{"    a", "", "    b"}},
+		{name: "Only the range", expandtab: true, content: []string{"a", "b", "c"}, start: 1, end: 1, count: 1, expectedContent: []string{"a", "    b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			_ = ed.SetOption("shiftwidth", 4)
+			_ = ed.SetOption("expandtab", tt.expandtab)
+			ed.EditorContent = tt.content
+
+			ed.ShiftLines(tt.start, tt.end, tt.count)
+			for i, line := range tt.expectedContent {
+				if ed.EditorContent[i] != line {
+					t.Errorf("Expected line %d %q, got %q", i, line, ed.EditorContent[i])
+				}
+			}
+			if ed.CursorY != tt.start || ed.CursorX != FirstNonBlank(ed.EditorContent[tt.start]) {
+				t.Errorf("Expected cursor at first non-blank of line %d, got (%d, %d)", tt.start, ed.CursorX, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestComputeIndent(t *testing.T) {
+	tests := []struct {
+		name     string
+		filetype string
+		content  []string
+		expected int
+	}{
+		{name: "First line", content: []string{"x"}, expected: 0},
+		{name: "Same as above", content: []string{"  a", "b"}, expected: 2},
+		{name: "After open brace", content: []string{"func f() {", ""}, expected: 4},
+		{name: "Closing brace", content: []string{"    x", "}"}, expected: 0},
+		{name: "Else line", content: []string{"  } else {", "x"}, expected: 6},
+		{name: "After open call", content: []string{"f(a,", "b)"}, expected: 4},
+		{name: "After closed call", content: []string{"    b)", "c"}, expected: 0},
+		{name: "Brackets in strings", content: []string{`s := "{("`, "x"}, expected: 0},
+		{name: "Brackets in comments", content: []string{"x // {", "y"}, expected: 0},
+		{name: "Skips blank lines", content: []string{"if x {", "", "y"}, expected: 4},
+		{name: "Python colon", filetype: "python", content: []string{"def f():  # {", "x"}, expected: 4},
+		{name: "Python return", filetype: "python", content: []string{"    return x", "y"}, expected: 0},
+		{name: "Python list", filetype: "python", content: []string{"x = [", "1"}, expected: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			_ = ed.SetOption("shiftwidth", 4)
+			_ = ed.SetOption("filetype", tt.filetype)
+			ed.EditorContent = tt.content
+
+			if indent := ed.ComputeIndent(len(tt.content) - 1); indent != tt.expected {
+				t.Errorf("Expected indent %d, got %d", tt.expected, indent)
+			}
+		})
+	}
+}
+
+func TestReindentLines(t *testing.T) {
+	tests := []struct {
+		name            string
+		filetype        string
+		content         []string
+		start, end      int
+		expectedContent []string
+	}{
+		{
+			name:            "C-like block",
+			content:         []string{"int f() {", "if (x) {", "y();", "  }", "   ", "return 0;", "}"},
+			start:           0,
+			end:             6,
+			expectedContent: []string{"int f() {", "\tif (x) {", "\t\ty();", "\t}", "", "\treturn 0;", "}"},
+		},
+		{
+			name:            "Range uses line above",
+			content:         []string{"\tif (x) {", "y();", "z();"},
+			start:           1,
+			end:             1,
+			expectedContent: []string{"\tif (x) {", "\t\ty();", "z();"},
+		},
+		{
+			name:            "Python keeps dedent",
+			filetype:        "python",
+			content:         []string{"def f():", "x = 1", "            if x:", "return 1", "y = 2", "z = 3"},
+			start:           1,
+			end:             5,
+			expectedContent: []string{"def f():", "\tx = 1", "\tif x:", "\t\treturn 1", "\ty = 2", "z = 3"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			_ = ed.SetOption("filetype", tt.filetype)
+			ed.EditorContent = tt.content
+
+			ed.ReindentLines(tt.start, tt.end)
+			for i, line := range tt.expectedContent {
+				if ed.EditorContent[i] != line {
+					t.Errorf("Expected line %d %q, got %q", i, line, ed.EditorContent[i])
+				}
+			}
+		})
+	}
+}
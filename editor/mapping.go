@@ -0,0 +1,117 @@
+package editor
+
+// KeyMapping makes a key sequence typed in a mode stand for other keys, or
+// run a function instead, such as one provided by a plugin.
+type KeyMapping struct {
+	Keys    string // Keys typed
+	RHS     string // Keys they stand for
+	Noremap bool   // RHS is not mapped again
+	Func    func() // Called instead of typing RHS, if set
+}
+
+// MappingTrie holds the key mappings of a mode, with one node for each
+// prefix of a mapped key sequence, so that a sequence can be checked as it
+// is typed.
+type MappingTrie struct {
+	Mapping  *KeyMapping // Mapping of the keys that lead here, if any
+	Children map[byte]*MappingTrie
+}
+
+// find returns the node for keys, or nil if no mapping starts with them.
+func (t *MappingTrie) find(keys string) *MappingTrie {
+	for i := 0; t != nil && i < len(keys); i++ {
+		t = t.Children[keys[i]]
+	}
+	return t
+}
+
+// remove deletes the mapping of keys below t, along with nodes left with
+// no mappings under them. It reports whether there was a mapping.
+func (t *MappingTrie) remove(keys string) bool {
+	if keys == "" {
+		found := t.Mapping != nil
+		t.Mapping = nil
+		return found
+	}
+	child := t.Children[keys[0]]
+	if child == nil || !child.remove(keys[1:]) {
+		return false
+	}
+	if child.Mapping == nil && len(child.Children) == 0 {
+		delete(t.Children, keys[0])
+	}
+	return true
+}
+
+// walk calls fn for each mapping below t, in key order.
+func (t *MappingTrie) walk(fn func(m KeyMapping)) {
+	if t.Mapping != nil {
+		fn(*t.Mapping)
+	}
+	for b := 0; b < 256; b++ {
+		if child := t.Children[byte(b)]; child != nil {
+			child.walk(fn)
+		}
+	}
+}
+
+// Map adds a key mapping in mode, replacing any mapping of the same keys.
+func (e *Editor) Map(mode Mode, m KeyMapping) {
+	if e.KeyMappings == nil {
+		e.KeyMappings = make(map[Mode]*MappingTrie)
+	}
+	t := e.KeyMappings[mode]
+	if t == nil {
+		t = &MappingTrie{}
+		e.KeyMappings[mode] = t
+	}
+	for i := 0; i < len(m.Keys); i++ {
+		child := t.Children[m.Keys[i]]
+		if child == nil {
+			child = &MappingTrie{}
+			if t.Children == nil {
+				t.Children = make(map[byte]*MappingTrie)
+			}
+			t.Children[m.Keys[i]] = child
+		}
+		t = child
+	}
+	t.Mapping = &m
+}
+
+// MapKeys maps keys typed in mode to fn, replacing any mapping of the same
+// keys.
+func (e *Editor) MapKeys(mode Mode, keys string, fn func()) {
+	e.Map(mode, KeyMapping{Keys: keys, Func: fn})
+}
+
+// UnmapKeys removes the mapping of keys in mode. It returns false if there
+// was none.
+func (e *Editor) UnmapKeys(mode Mode, keys string) bool {
+	t := e.KeyMappings[mode]
+	return t != nil && keys != "" && t.remove(keys)
+}
+
+// LookupMapping finds the mapping of keys in mode. It also reports whether
+// a longer mapping starts with keys, in which case more keys are needed to
+// tell which one was meant.
+func (e *Editor) LookupMapping(mode Mode, keys string) (m KeyMapping, found, longer bool) {
+	t := e.KeyMappings[mode].find(keys)
+	if t == nil {
+		return m, false, false
+	}
+	if t.Mapping != nil {
+		m, found = *t.Mapping, true
+	}
+	return m, found, len(t.Children) > 0
+}
+
+// Mappings returns the mappings in mode whose keys start with prefix, in key
+// order.
+func (e *Editor) Mappings(mode Mode, prefix string) []KeyMapping {
+	var mappings []KeyMapping
+	if t := e.KeyMappings[mode].find(prefix); t != nil {
+		t.walk(func(m KeyMapping) { mappings = append(mappings, m) })
+	}
+	return mappings
+}
@@ -0,0 +1,53 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestKeyMappings(t *testing.T) {
+	e := NewEditor(80, 24)
+	for _, keys := range []string{"ab", "abc", "b", "a"} {
+		e.Map(ModeNormal, KeyMapping{Keys: keys, RHS: strings.ToUpper(keys)})
+	}
+	e.Map(ModeInsert, KeyMapping{Keys: "jk", RHS: "\x1b", Noremap: true})
+
+	tests := []struct {
+		keys   string
+		rhs    string
+		found  bool
+		longer bool
+	}{
+		{"a", "A", true, true},
+		{"ab", "AB", true, true},
+		{"abc", "ABC", true, false},
+		{"b", "B", true, false},
+		{"abd", "", false, false},
+		{"jk", "", false, false}, // Insert mode only
+		{"c", "", false, false},
+	}
+	for _, tt := range tests {
+		m, found, longer := e.LookupMapping(ModeNormal, tt.keys)
+		if m.RHS != tt.rhs || found != tt.found || longer != tt.longer {
+			t.Errorf("LookupMapping(%q): expected %q %v %v, got %q %v %v", tt.keys, tt.rhs, tt.found, tt.longer, m.RHS, found, longer)
+		}
+	}
+
+	var keys []string
+	for _, m := range e.Mappings(ModeNormal, "a") {
+		keys = append(keys, m.Keys)
+	}
+	if strings.Join(keys, ",") != "a,ab,abc" {
+		t.Errorf("Expected mappings a,ab,abc, got %v", keys)
+	}
+
+	if !e.UnmapKeys(ModeNormal, "abc") || e.UnmapKeys(ModeNormal, "abc") {
+		t.Errorf("Expected abc to be unmapped once")
+	}
+	if _, found, longer := e.LookupMapping(ModeNormal, "ab"); !found || longer {
+		t.Errorf("Expected ab to be mapped with nothing longer, got %v %v", found, longer)
+	}
+	if e.UnmapKeys(ModeVisual, "a") || e.UnmapKeys(ModeNormal, "") {
+		t.Errorf("Expected nothing to unmap")
+	}
+}
@@ -0,0 +1,189 @@
+package editor
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// maxJumps is the number of positions kept in the jump list.
+const maxJumps = 100
+
+// FileMark is an uppercase mark, which remembers the file it was set in.
+type FileMark struct {
+	Filename string
+	Pos      Position
+}
+
+// Automatic marks, maintained by the editor rather than set with m.
+const (
+	MarkPreviousJump byte = '\'' // Position before the latest jump ('' or ``)
+	MarkLastChange   byte = '.'  // Where the last change was made
+	MarkInsertExit   byte = '^'  // Where Insert mode was last left
+	MarkChangeStart  byte = '['  // Start of the last changed or yanked text
+	MarkChangeEnd    byte = ']'  // End of the last changed or yanked text
+	MarkVisualStart  byte = '<'  // Start of the last Visual selection
+	MarkVisualEnd    byte = '>'  // End of the last Visual selection
+)
+
+// IsSettableMark reports whether m{name} can set the mark name.
+func IsSettableMark(name byte) bool {
+	switch name {
+	case '`', MarkPreviousJump, MarkChangeStart, MarkChangeEnd, MarkVisualStart, MarkVisualEnd:
+		return true
+	}
+	return name >= 'a' && name <= 'z' || name >= 'A' && name <= 'Z'
+}
+
+// SetMark sets mark name to p. Uppercase marks are file marks and also record
+// the current filename; '`' is another name for the ” mark.
+func (e *Editor) SetMark(name byte, p Position) {
+	if name == '`' {
+		name = MarkPreviousJump
+	}
+	if name >= 'A' && name <= 'Z' {
+		if e.FileMarks == nil {
+			e.FileMarks = make(map[byte]FileMark)
+		}
+		e.FileMarks[name] = FileMark{Filename: e.Filename, Pos: p}
+		return
+	}
+	if e.Marks == nil {
+		e.Marks = make(map[byte]Position)
+	}
+	e.Marks[name] = p
+}
+
+// MarkPosition returns the position of mark name in the current buffer.
+func (e *Editor) MarkPosition(name byte) (Position, error) {
+	if name == '`' {
+		name = MarkPreviousJump
+	}
+	if name >= 'A' && name <= 'Z' {
+		fm, ok := e.FileMarks[name]
+		if !ok {
+			return Position{}, fmt.Errorf("Mark not set: %c", name)
+		}
+		if !sameFile(fm.Filename, e.Filename) {
+			return Position{}, fmt.Errorf("Mark %c is in another file: %s", name, fm.Filename)
+		}
+		return e.clampPosition(fm.Pos), nil
+	}
+	p, ok := e.Marks[name]
+	if !ok {
+		return Position{}, fmt.Errorf("Mark not set: %c", name)
+	}
+	return e.clampPosition(p), nil
+}
+
+// sameFile reports whether paths a and b name the same file, one perhaps
+// relative to the working directory.
+func sameFile(a, b string) bool {
+	if a == b {
+		return true
+	}
+	absA, errA := filepath.Abs(a)
+	absB, errB := filepath.Abs(b)
+	return errA == nil && errB == nil && absA == absB
+}
+
+// clampPosition limits p to the current buffer contents.
+func (e *Editor) clampPosition(p Position) Position {
+	p.Line = max(min(p.Line, len(e.EditorContent)-1), 0)
+	p.Col = max(min(p.Col, len(e.EditorContent[p.Line])), 0)
+	return p
+}
+
+// PushJump records the cursor position in the jump list and as the ” mark,
+// before a jump moves the cursor elsewhere. Older entries for the same line
+// are dropped.
+func (e *Editor) PushJump() {
+	cursor := Position{Line: e.CursorY, Col: e.CursorX}
+	var jumps []Position
+	for _, p := range e.JumpList {
+		if p.Line != cursor.Line {
+			jumps = append(jumps, p)
+		}
+	}
+	jumps = append(jumps, cursor)
+	if len(jumps) > maxJumps {
+		jumps = jumps[len(jumps)-maxJumps:]
+	}
+	e.JumpList = jumps
+	e.JumpIndex = len(jumps)
+	e.SetMark(MarkPreviousJump, cursor)
+}
+
+// JumpOlder moves the cursor count entries back in the jump list (Ctrl-O).
+// It returns false if there is no such entry.
+func (e *Editor) JumpOlder(count int) bool {
+	if e.JumpIndex >= len(e.JumpList) {
+		// Remember where we are so that JumpNewer can come back here.
+		e.PushJump()
+		e.JumpIndex = len(e.JumpList) - 1
+	}
+	return e.jumpTo(e.JumpIndex - max(count, 1))
+}
+
+// JumpNewer moves the cursor count entries forward in the jump list (Ctrl-I).
+// It returns false if there is no such entry.
+func (e *Editor) JumpNewer(count int) bool {
+	return e.jumpTo(e.JumpIndex + max(count, 1))
+}
+
+// jumpTo moves the cursor to jump list entry i.
+func (e *Editor) jumpTo(i int) bool {
+	if i < 0 || i >= len(e.JumpList) {
+		return false
+	}
+	e.JumpIndex = i
+	p := e.clampPosition(e.JumpList[i])
+	e.CursorY, e.CursorX = p.Line, p.Col
+	return true
+}
+
+// adjustMarks keeps marks, jump list entries, signs and the lines :global is
+// to visit on the same text after lines are added or removed. A positive
+// delta means lines were inserted before line; a negative one means -delta
+// lines starting at line were removed. Marks on removed lines move to the
+// line before them, which is where the text of a join or a multi-line
+// delete ends up; removed lines are no longer visited by :global.
+func (e *Editor) adjustMarks(line, delta int) {
+	if delta == 0 {
+		return
+	}
+	adjust := func(p Position) Position {
+		switch {
+		case p.Line < line:
+		case delta > 0 || p.Line >= line-delta:
+			p.Line += delta
+		default:
+			p.Line = max(line-1, 0)
+		}
+		return p
+	}
+
+	for name, p := range e.Marks {
+		e.Marks[name] = adjust(p)
+	}
+	for name, fm := range e.FileMarks {
+		if fm.Filename == e.Filename {
+			fm.Pos = adjust(fm.Pos)
+			e.FileMarks[name] = fm
+		}
+	}
+	for i, p := range e.JumpList {
+		e.JumpList[i] = adjust(p)
+	}
+	for i, s := range e.Signs {
+		e.Signs[i].Line = adjust(Position{Line: s.Line}).Line
+	}
+	if e.GlobalLines != nil {
+		kept := e.GlobalLines[:0]
+		for _, l := range e.GlobalLines {
+			if l < line || delta > 0 || l >= line-delta {
+				kept = append(kept, adjust(Position{Line: l}).Line)
+			}
+		}
+		e.GlobalLines = kept
+	}
+}
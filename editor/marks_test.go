@@ -0,0 +1,151 @@
+package editor
+
+import (
+	"testing"
+)
+
+func TestMarksFollowLineChanges(t *testing.T) {
+	tests := []struct {
+		name         string
+		content      []string
+		cursorX      int
+		cursorY      int
+		edit         func(e *Editor)
+		expectedLine int // Line of mark 'a, set on line 2
+	}{
+		{
+			name:         "Newline above shifts mark down",
+			content:      []string{"ab", "c", "d"},
+			cursorX:      1,
+			edit:         func(e *Editor) { e.InsertNewline() },
+			expectedLine: 3,
+		},
+		{
+			name:         "Newline below leaves mark alone",
+			content:      []string{"a", "b", "c", "d"},
+			cursorY:      3,
+			edit:         func(e *Editor) { e.InsertNewline() },
+			expectedLine: 2,
+		},
+		{
+			name:         "Joining a line above shifts mark up",
+			content:      []string{"a", "b", "c"},
+			cursorY:      1,
+			edit:         func(e *Editor) { e.DeleteChar() },
+			expectedLine: 1,
+		},
+		{
+			name:         "Joining the marked line moves mark to joined line",
+			content:      []string{"a", "b", "c"},
+			cursorY:      2,
+			edit:         func(e *Editor) { e.DeleteChar() },
+			expectedLine: 1,
+		},
+		{
+			name:    "Deleting lines above shifts mark up",
+			content: []string{"a", "b", "c"},
+			edit: func(e *Editor) {
+				e.DeleteRegion(Region{Start: Position{0, 0}, End: Position{1, 0}, Linewise: true})
+			},
+			expectedLine: 0,
+		},
+		{
+			name:         "Inserting text with newlines shifts mark down",
+			content:      []string{"a", "b", "c"},
+			edit:         func(e *Editor) { e.InsertText(Position{0, 1}, "x\ny\nz") },
+			expectedLine: 4,
+		},
+		{
+			name:         "Joining lines above shifts mark up",
+			content:      []string{"a", "b", "c", "d"},
+			edit:         func(e *Editor) { e.JoinLines(2) },
+			expectedLine: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = tt.content
+			ed.SetMark('a', Position{Line: 2})
+			ed.CursorX, ed.CursorY = tt.cursorX, tt.cursorY
+
+			tt.edit(ed)
+
+			p, err := ed.MarkPosition('a')
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if p.Line != tt.expectedLine {
+				t.Errorf("Expected mark on line %d, got %d", tt.expectedLine, p.Line)
+			}
+		})
+	}
+}
+
+func TestMarkPosition(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"abc", "de"}
+	ed.Filename = "one.txt"
+
+	if _, err := ed.MarkPosition('a'); err == nil {
+		t.Error("Expected error for unset mark")
+	}
+
+	ed.SetMark('b', Position{Line: 1, Col: 9})
+	if p, _ := ed.MarkPosition('b'); p != (Position{Line: 1, Col: 2}) {
+		t.Errorf("Expected mark clamped to line end, got %v", p)
+	}
+
+	ed.SetMark('F', Position{Line: 1})
+	if _, err := ed.MarkPosition('F'); err != nil {
+		t.Errorf("Unexpected error for file mark in current file: %v", err)
+	}
+	ed.Filename = "two.txt"
+	if _, err := ed.MarkPosition('F'); err == nil {
+		t.Error("Expected error for file mark in another file")
+	}
+
+	ed.SetMark('`', Position{Line: 1})
+	if p, _ := ed.MarkPosition(MarkPreviousJump); p.Line != 1 {
+		t.Errorf("Expected ` to set the '' mark, got %v", p)
+	}
+}
+
+func TestJumpList(t *testing.T) {
+	ed := NewEditor(80, 24)
+	ed.EditorContent = []string{"0", "1", "2", "3", "4"}
+
+	// Jump from line 0 to 2 to 4.
+	for _, line := range []int{2, 4} {
+		ed.PushJump()
+		ed.CursorY = line
+	}
+
+	steps := []struct {
+		older    bool
+		expectOK bool
+		expectY  int
+	}{
+		{older: true, expectOK: true, expectY: 2},
+		{older: true, expectOK: true, expectY: 0},
+		{older: true, expectOK: false, expectY: 0},
+		{older: false, expectOK: true, expectY: 2},
+		{older: false, expectOK: true, expectY: 4},
+		{older: false, expectOK: false, expectY: 4},
+	}
+	for i, step := range steps {
+		var ok bool
+		if step.older {
+			ok = ed.JumpOlder(1)
+		} else {
+			ok = ed.JumpNewer(1)
+		}
+		if ok != step.expectOK {
+			t.Errorf("Step %d: Expected ok %t, got %t", i, step.expectOK, ok)
+		}
+		if ed.CursorY != step.expectY {
+			t.Errorf("Step %d: Expected CursorY %d, got %d", i, step.expectY, ed.CursorY)
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package editor
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// OptionType is the kind of value an option holds.
+type OptionType int
+
+const (
+	OptionBool   OptionType = iota
+	OptionInt               // Values are ints
+	OptionString            // Values are strings
+	OptionList              // Values are comma-separated strings
+)
+
+// OptionScope says where an option's value applies.
+type OptionScope int
+
+const (
+	ScopeGlobal OptionScope = iota // One value for the whole editor
+	ScopeBuffer                    // Each buffer may have its own value
+	ScopeWindow                    // Each window may have its own value
+)
+
+// OptionDef describes an option that can be changed with :set.
+type OptionDef struct {
+	Name    string
+	Short   string      // Abbreviated name, e.g. "nu" for "number"
+	Type    OptionType  // Kind of value
+	Scope   OptionScope // Where the value applies
+	Default any         // bool, int or string, according to Type
+	Allowed []string    // Valid values (or list items) of a string or list option; any value if empty
+}
+
+// optionDefs lists every option the editor knows.
+var optionDefs = []OptionDef{
+	{Name: "number", Short: "nu", Type: OptionBool, Scope: ScopeWindow, Default: false},
+	{Name: "relativenumber", Short: "rnu", Type: OptionBool, Scope: ScopeWindow, Default: false},
+	{Name: "signcolumn", Short: "scl", Type: OptionString, Scope: ScopeWindow, Default: "auto", Allowed: []string{"auto", "yes", "no"}},
+	{Name: "statustimeout", Short: "stm", Type: OptionInt, Scope: ScopeGlobal, Default: 5000},
+	{Name: "undolevels", Short: "ul", Type: OptionInt, Scope: ScopeGlobal, Default: 1000},
+	{Name: "history", Short: "hi", Type: OptionInt, Scope: ScopeGlobal, Default: 100},
+	{Name: "timeoutlen", Short: "tm", Type: OptionInt, Scope: ScopeGlobal, Default: 1000},
+	{Name: "updatetime", Short: "ut", Type: OptionInt, Scope: ScopeGlobal, Default: 4000},
+	{Name: "mapleader", Type: OptionString, Scope: ScopeGlobal, Default: "\\"},
+	{Name: "shell", Short: "sh", Type: OptionString, Scope: ScopeGlobal, Default: defaultShell()},
+	{Name: "tabstop", Short: "ts", Type: OptionInt, Scope: ScopeBuffer, Default: 8},
+	{Name: "shiftwidth", Short: "sw", Type: OptionInt, Scope: ScopeBuffer, Default: 8},
+	{Name: "softtabstop", Short: "sts", Type: OptionInt, Scope: ScopeBuffer, Default: 0},
+	{Name: "expandtab", Short: "et", Type: OptionBool, Scope: ScopeBuffer, Default: false},
+	{Name: "autoindent", Short: "ai", Type: OptionBool, Scope: ScopeBuffer, Default: false},
+	{Name: "smartindent", Short: "si", Type: OptionBool, Scope: ScopeBuffer, Default: false},
+	{Name: "autopair", Short: "ap", Type: OptionBool, Scope: ScopeBuffer, Default: false},
+	{Name: "autopairs", Short: "aps", Type: OptionList, Scope: ScopeBuffer, Default: ""},
+	{Name: "filetype", Short: "ft", Type: OptionString, Scope: ScopeBuffer, Default: ""},
+	{Name: "fileformat", Short: "ff", Type: OptionString, Scope: ScopeBuffer, Default: "unix", Allowed: []string{"unix", "dos", "mac"}},
+	{Name: "fileencoding", Short: "fenc", Type: OptionString, Scope: ScopeBuffer, Default: "utf-8", Allowed: []string{"utf-8", "utf-8-bom", "latin1", "utf-16be", "utf-16le"}},
+	{Name: "endofline", Short: "eol", Type: OptionBool, Scope: ScopeBuffer, Default: true},
+	{Name: "fixendofline", Short: "fixeol", Type: OptionBool, Scope: ScopeBuffer, Default: true},
+	{Name: "trimtrailingwhitespace", Short: "ttw", Type: OptionBool, Scope: ScopeBuffer, Default: false},
+	{Name: "formatonsave", Short: "fos", Type: OptionBool, Scope: ScopeBuffer, Default: true},
+	{Name: "readonly", Short: "ro", Type: OptionBool, Scope: ScopeBuffer, Default: false},
+	{Name: "modifiable", Short: "ma", Type: OptionBool, Scope: ScopeBuffer, Default: true},
+}
+
+// defaultShell returns the user's shell from $SHELL, or sh.
+func defaultShell() string {
+	if shell := os.Getenv("SHELL"); shell != "" {
+		return shell
+	}
+	return "sh"
+}
+
+// LookupOption finds an option by its full or abbreviated name.
+func LookupOption(name string) (*OptionDef, bool) {
+	for i := range optionDefs {
+		if optionDefs[i].Name == name || optionDefs[i].Short != "" && optionDefs[i].Short == name {
+			return &optionDefs[i], true
+		}
+	}
+	return nil, false
+}
+
+// OptionDefs returns the definitions of all options, in the order they were
+// defined.
+func OptionDefs() []OptionDef {
+	return slices.Clone(optionDefs)
+}
+
+// RegisterOption adds an option, replacing any option with the same name.
+func RegisterOption(def OptionDef) {
+	for i := range optionDefs {
+		if optionDefs[i].Name == def.Name {
+			optionDefs[i] = def
+			return
+		}
+	}
+	optionDefs = append(optionDefs, def)
+}
+
+// ParseOptionValue converts the text of a :set argument to a value of the
+// option's type.
+func ParseOptionValue(def *OptionDef, s string) (any, error) {
+	switch def.Type {
+	case OptionBool:
+		return nil, fmt.Errorf("Invalid argument: %s=%s", def.Name, s)
+	case OptionInt:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return nil, fmt.Errorf("Number required after =: %s=%s", def.Name, s)
+		}
+		return n, nil
+	}
+	return s, nil
+}
+
+// checkOptionValue reports an error if value is not valid for def.
+func checkOptionValue(def *OptionDef, value any) error {
+	var ok bool
+	switch def.Type {
+	case OptionBool:
+		_, ok = value.(bool)
+	case OptionInt:
+		_, ok = value.(int)
+	case OptionString:
+		var s string
+		s, ok = value.(string)
+		ok = ok && (len(def.Allowed) == 0 || slices.Contains(def.Allowed, s))
+	case OptionList:
+		var s string
+		s, ok = value.(string)
+		for _, item := range splitList(s) {
+			ok = ok && (len(def.Allowed) == 0 || slices.Contains(def.Allowed, item))
+		}
+	}
+	if !ok {
+		return fmt.Errorf("Invalid argument: %s=%v", def.Name, value)
+	}
+	return nil
+}
+
+// lookupValidOption finds an option and checks that value suits it.
+func lookupValidOption(name string, value any) (*OptionDef, error) {
+	def, ok := LookupOption(name)
+	if !ok {
+		return nil, fmt.Errorf("Unknown option: %s", name)
+	}
+	return def, checkOptionValue(def, value)
+}
+
+// SetOption changes an option, as :set does: both its global value and the
+// value for the current buffer or window. value must be a bool, int or
+// string matching the option's type.
+func (e *Editor) SetOption(name string, value any) error {
+	def, err := lookupValidOption(name, value)
+	if err != nil {
+		return err
+	}
+	if e.Options == nil {
+		e.Options = make(map[string]any)
+	}
+	e.Options[def.Name] = value
+	delete(e.LocalOptions, def.Name)
+	return nil
+}
+
+// SetLocalOption changes an option for the current buffer or window only,
+// as :setlocal does. Global options are simply set.
+func (e *Editor) SetLocalOption(name string, value any) error {
+	def, err := lookupValidOption(name, value)
+	if err != nil {
+		return err
+	}
+	if def.Scope == ScopeGlobal {
+		return e.SetOption(name, value)
+	}
+	if e.LocalOptions == nil {
+		e.LocalOptions = make(map[string]any)
+	}
+	e.LocalOptions[def.Name] = value
+	return nil
+}
+
+// SetGlobalOption changes the global value of an option, as :setglobal does.
+// For a local option the current buffer or window keeps its value; the new
+// one applies to files loaded later.
+func (e *Editor) SetGlobalOption(name string, value any) error {
+	def, err := lookupValidOption(name, value)
+	if err != nil {
+		return err
+	}
+	if def.Scope != ScopeGlobal {
+		if _, ok := e.LocalOptions[def.Name]; !ok {
+			if e.LocalOptions == nil {
+				e.LocalOptions = make(map[string]any)
+			}
+			e.LocalOptions[def.Name] = e.OptionValue(def.Name)
+		}
+	}
+	if e.Options == nil {
+		e.Options = make(map[string]any)
+	}
+	e.Options[def.Name] = value
+	return nil
+}
+
+// resetBufferOptions drops buffer-local option values, so that a newly
+// loaded file starts with the global ones.
+func (e *Editor) resetBufferOptions() {
+	for name := range e.LocalOptions {
+		if def, ok := LookupOption(name); ok && def.Scope == ScopeBuffer {
+			delete(e.LocalOptions, name)
+		}
+	}
+}
+
+// OptionValue returns the value of an option in effect for the current
+// buffer and window, or nil if there is no such option.
+func (e *Editor) OptionValue(name string) any {
+	def, ok := LookupOption(name)
+	if !ok {
+		return nil
+	}
+	if v, ok := e.LocalOptions[def.Name]; ok {
+		return v
+	}
+	return e.GlobalOptionValue(def.Name)
+}
+
+// GlobalOptionValue returns the global value of an option, or nil if there
+// is no such option.
+func (e *Editor) GlobalOptionValue(name string) any {
+	def, ok := LookupOption(name)
+	if !ok {
+		return nil
+	}
+	if v, ok := e.Options[def.Name]; ok {
+		return v
+	}
+	return def.Default
+}
+
+// BoolOption returns the value of a boolean option.
+func (e *Editor) BoolOption(name string) bool {
+	v, _ := e.OptionValue(name).(bool)
+	return v
+}
+
+// ShiftWidth returns the width of one level of indent: shiftwidth, or
+// tabstop when shiftwidth is 0.
+func (e *Editor) ShiftWidth() int {
+	if sw := e.IntOption("shiftwidth"); sw > 0 {
+		return sw
+	}
+	return max(e.IntOption("tabstop"), 1)
+}
+
+// IntOption returns the value of a number option.
+func (e *Editor) IntOption(name string) int {
+	v, _ := e.OptionValue(name).(int)
+	return v
+}
+
+// StringOption returns the value of a string option.
+func (e *Editor) StringOption(name string) string {
+	v, _ := e.OptionValue(name).(string)
+	return v
+}
+
+// ListOption returns the items of a list option.
+func (e *Editor) ListOption(name string) []string {
+	return splitList(e.StringOption(name))
+}
+
+// splitList splits the value of a list option into its non-empty items.
+func splitList(s string) []string {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,105 @@
+package editor
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSetOption(t *testing.T) {
+	ed := NewEditor(80, 24)
+	if err := ed.SetOption("nu", true); err != nil || !ed.BoolOption("number") {
+		t.Errorf("Expected nu to set number, got err %v", err)
+	}
+	if err := ed.SetOption("bogus", true); err == nil {
+		t.Error("Expected error for unknown option")
+	}
+	if err := ed.SetOption("number", "yes"); err == nil {
+		t.Error("Expected error for string value of boolean option")
+	}
+	if err := ed.SetOption("statustimeout", "1"); err == nil {
+		t.Error("Expected error for string value of number option")
+	}
+	if err := ed.SetOption("signcolumn", "maybe"); err == nil {
+		t.Error("Expected error for disallowed value")
+	}
+	if v := ed.StringOption("signcolumn"); v != "auto" {
+		t.Errorf("Expected default signcolumn %q, got %q", "auto", v)
+	}
+	if v := ed.IntOption("statustimeout"); v != 5000 {
+		t.Errorf("Expected default statustimeout 5000, got %d", v)
+	}
+}
+
+func TestOptionScopes(t *testing.T) {
+	RegisterOption(OptionDef{Name: "testbuf", Type: OptionInt, Scope: ScopeBuffer, Default: 1})
+	RegisterOption(OptionDef{Name: "testlist", Type: OptionList, Scope: ScopeGlobal, Default: "a,,b", Allowed: []string{"a", "b", "c"}})
+	defer func() {
+		optionDefs = slices.DeleteFunc(optionDefs, func(d OptionDef) bool { return d.Name == "testbuf" || d.Name == "testlist" })
+	}()
+
+	t.Run("Local value overrides global", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		_ = ed.SetLocalOption("number", true)
+		if !ed.BoolOption("number") || ed.GlobalOptionValue("number") != false {
+			t.Errorf("Expected local number only, got local %v global %v", ed.OptionValue("number"), ed.GlobalOptionValue("number"))
+		}
+		_ = ed.SetOption("number", false)
+		if ed.BoolOption("number") {
+			t.Error("Expected :set to replace the local value")
+		}
+	})
+
+	t.Run("Global value keeps current local value", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		_ = ed.SetGlobalOption("testbuf", 4)
+		if v := ed.IntOption("testbuf"); v != 1 {
+			t.Errorf("Expected current buffer to keep 1, got %d", v)
+		}
+		ed.LoadFile([]byte("new file\n"))
+		if v := ed.IntOption("testbuf"); v != 4 {
+			t.Errorf("Expected loaded file to get global 4, got %d", v)
+		}
+	})
+
+	t.Run("Loading a file keeps window options", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		_ = ed.SetLocalOption("relativenumber", true)
+		_ = ed.SetLocalOption("testbuf", 7)
+		ed.LoadFile([]byte("x"))
+		if !ed.BoolOption("relativenumber") {
+			t.Error("Expected window-local relativenumber to survive LoadFile")
+		}
+		if v := ed.IntOption("testbuf"); v != 1 {
+			t.Errorf("Expected buffer-local value to be reset, got %d", v)
+		}
+	})
+
+	t.Run("List option", func(t *testing.T) {
+		ed := NewEditor(80, 24)
+		if items := ed.ListOption("testlist"); !slices.Equal(items, []string{"a", "b"}) {
+			t.Errorf("Expected items [a b], got %v", items)
+		}
+		if err := ed.SetOption("testlist", "a,d"); err == nil {
+			t.Error("Expected error for disallowed list item")
+		}
+	})
+}
+
+func TestParseOptionValue(t *testing.T) {
+	nu, _ := LookupOption("nu")
+	stm, _ := LookupOption("stm")
+	scl, _ := LookupOption("scl")
+
+	if _, err := ParseOptionValue(nu, "1"); err == nil {
+		t.Error("Expected error for value of boolean option")
+	}
+	if v, err := ParseOptionValue(stm, "250"); err != nil || v != 250 {
+		t.Errorf("Expected 250, got %v (%v)", v, err)
+	}
+	if _, err := ParseOptionValue(stm, "soon"); err == nil {
+		t.Error("Expected error for non-numeric value")
+	}
+	if v, err := ParseOptionValue(scl, "yes"); err != nil || v != "yes" {
+		t.Errorf("Expected \"yes\", got %v (%v)", v, err)
+	}
+}
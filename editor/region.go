@@ -0,0 +1,257 @@
+package editor
+
+import (
+	"slices"
+	"strings"
+)
+
+// Position identifies a location in the buffer (0-based line and column).
+type Position struct {
+	Line int
+	Col  int
+}
+
+// Region is a span of buffer text selected by a text object or in Visual mode.
+// End is inclusive, so a characterwise Region whose End precedes its Start is
+// empty. Linewise regions cover whole lines and ignore the columns.
+type Region struct {
+	Start    Position
+	End      Position
+	Linewise bool
+}
+
+// IsEmpty reports whether the region selects no characters.
+func (r Region) IsEmpty() bool {
+	if r.Linewise {
+		return r.End.Line < r.Start.Line
+	}
+	return r.End.Line < r.Start.Line || (r.End.Line == r.Start.Line && r.End.Col < r.Start.Col)
+}
+
+// bufferText returns the whole buffer as a single string with '\n' line breaks.
+func (e *Editor) bufferText() string {
+	return strings.Join(e.EditorContent, "\n")
+}
+
+// textChange returns the change made by replacing the text removed, which
+// started on line, with the text inserted.
+func textChange(line int, removed, inserted string) LineChange {
+	return LineChange{Line: line, Old: strings.Count(removed, "\n") + 1, New: strings.Count(inserted, "\n") + 1}
+}
+
+// setBufferText replaces the buffer with the lines of text.
+func (e *Editor) setBufferText(text string) {
+	e.EditorContent = strings.Split(text, "\n")
+}
+
+// offsetOf converts a buffer position to an offset into bufferText.
+func (e *Editor) offsetOf(p Position) int {
+	off := 0
+	for i := 0; i < p.Line && i < len(e.EditorContent); i++ {
+		off += len(e.EditorContent[i]) + 1
+	}
+	if p.Line < len(e.EditorContent) && p.Col > len(e.EditorContent[p.Line]) {
+		return off + len(e.EditorContent[p.Line])
+	}
+	return off + p.Col
+}
+
+// positionOf converts an offset into bufferText back to a buffer position.
+// An offset at a line break maps to the column just past the end of the line.
+func (e *Editor) positionOf(off int) Position {
+	line := 0
+	for line < len(e.EditorContent)-1 && off > len(e.EditorContent[line]) {
+		off -= len(e.EditorContent[line]) + 1
+		line++
+	}
+	return Position{Line: line, Col: off}
+}
+
+// RegionText returns the text covered by r. Linewise text ends with a newline.
+func (e *Editor) RegionText(r Region) string {
+	if r.IsEmpty() {
+		return ""
+	}
+	if r.Linewise {
+		return strings.Join(e.EditorContent[r.Start.Line:r.End.Line+1], "\n") + "\n"
+	}
+	text := e.bufferText()
+	start, end := e.offsetOf(r.Start), e.offsetOf(r.End)+1
+	if end > len(text) {
+		end = len(text)
+	}
+	return text[start:end]
+}
+
+// DeleteRegion removes the text covered by r and leaves the cursor at its start.
+func (e *Editor) DeleteRegion(r Region) {
+	if e.beginChange() != nil {
+		return
+	}
+	if r.Linewise {
+		if r.IsEmpty() {
+			return
+		}
+		change := LineChange{Line: r.Start.Line, Old: r.End.Line - r.Start.Line + 1}
+		e.EditorContent = append(e.EditorContent[:r.Start.Line], e.EditorContent[r.End.Line+1:]...)
+		e.adjustMarks(r.Start.Line, -change.Old)
+		if len(e.EditorContent) == 0 {
+			e.EditorContent = []string{""}
+			change.New = 1
+		}
+		e.CursorY = min(r.Start.Line, len(e.EditorContent)-1)
+		e.CursorX = FirstNonBlank(e.EditorContent[e.CursorY])
+		e.markChanged(change)
+		return
+	}
+
+	e.CursorY, e.CursorX = r.Start.Line, r.Start.Col
+	if r.IsEmpty() {
+		return
+	}
+	text := e.bufferText()
+	start, end := e.offsetOf(r.Start), e.offsetOf(r.End)+1
+	if end > len(text) {
+		end = len(text)
+	}
+	lineCount := len(e.EditorContent)
+	e.setBufferText(text[:start] + text[end:])
+	e.adjustMarks(r.Start.Line+1, len(e.EditorContent)-lineCount)
+	e.markChanged(textChange(r.Start.Line, text[start:end], ""))
+}
+
+// InsertText inserts text, which may span several lines, at p and returns the
+// position just after the inserted text. The cursor is not moved.
+func (e *Editor) InsertText(p Position, text string) Position {
+	if e.beginChange() != nil {
+		return p
+	}
+	e.ensureLineExists(p.Line)
+	buf := e.bufferText()
+	off := e.offsetOf(p)
+	lineCount := len(e.EditorContent)
+	e.setBufferText(buf[:off] + text + buf[off:])
+	e.adjustMarks(p.Line+1, len(e.EditorContent)-lineCount)
+	if text != "" {
+		e.markChanged(textChange(p.Line, "", text))
+	}
+	return e.positionOf(off + len(text))
+}
+
+// ReplaceText replaces the text from start up to, but not including, end with
+// text, which may span several lines. The cursor is not moved.
+func (e *Editor) ReplaceText(start, end Position, text string) {
+	if e.beginChange() != nil {
+		return
+	}
+	buf := e.bufferText()
+	from := min(e.offsetOf(start), len(buf))
+	to := max(min(e.offsetOf(end), len(buf)), from)
+	if buf[from:to] == text {
+		return
+	}
+	lineCount := len(e.EditorContent)
+	e.setBufferText(buf[:from] + text + buf[to:])
+	e.adjustMarks(start.Line+1, len(e.EditorContent)-lineCount)
+	e.markChanged(textChange(start.Line, buf[from:to], text))
+}
+
+// ReplaceLines replaces lines start to end (inclusive) with lines, which may
+// be fewer or more, and puts the cursor on the first of them.
+func (e *Editor) ReplaceLines(start, end int, lines []string) {
+	if e.beginChange() != nil {
+		return
+	}
+	content := make([]string, 0, len(e.EditorContent)-(end-start+1)+len(lines))
+	content = append(content, e.EditorContent[:start]...)
+	content = append(content, lines...)
+	content = append(content, e.EditorContent[end+1:]...)
+	change := LineChange{Line: start, Old: end - start + 1, New: len(lines)}
+	if len(content) == 0 {
+		content = []string{""}
+		change.New = 1
+	}
+	e.EditorContent = content
+	if delta := len(lines) - (end - start + 1); delta > 0 {
+		e.adjustMarks(end+1, delta)
+	} else {
+		e.adjustMarks(start+len(lines), delta)
+	}
+	e.CursorY = min(start, len(content)-1)
+	e.CursorX = FirstNonBlank(content[e.CursorY])
+	e.markChanged(change)
+}
+
+// ReplaceBuffer replaces the whole buffer with lines, as a single change to
+// the lines between those that are the same at the start and end. The
+// cursor stays on the same text where it can: on an unchanged line it keeps
+// its place, and on a changed line it keeps its column relative to the
+// indent. It returns false if lines are the same as the buffer.
+func (e *Editor) ReplaceBuffer(lines []string) bool {
+	if len(lines) == 0 {
+		lines = []string{""}
+	}
+	old := e.EditorContent
+	start := 0
+	for start < len(old) && start < len(lines) && old[start] == lines[start] {
+		start++
+	}
+	if start == len(old) && start == len(lines) {
+		return false
+	}
+	oldEnd, newEnd := len(old), len(lines)
+	for oldEnd > start && newEnd > start && old[oldEnd-1] == lines[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+
+	y, x := e.CursorY, e.CursorX
+	switch {
+	case y < start:
+	case y >= oldEnd:
+		y += newEnd - oldEnd
+	default: // On a changed line; keep the offset into the changed lines
+		y = min(y, max(newEnd-1, start))
+	}
+	y = max(min(y, len(lines)-1), 0)
+	if e.CursorY < len(old) && old[e.CursorY] != lines[y] {
+		x = FirstNonBlank(lines[y]) + x - FirstNonBlank(old[e.CursorY])
+	}
+
+	middle := slices.Clone(lines[start:newEnd])
+	if oldEnd > start {
+		e.ReplaceLines(start, oldEnd-1, middle)
+	} else {
+		e.InsertLines(start, middle)
+	}
+	e.CursorY = y
+	e.CursorX = max(min(x, len(e.EditorContent[y])), 0)
+	return true
+}
+
+// InsertLines inserts lines before line index at.
+func (e *Editor) InsertLines(at int, lines []string) {
+	if e.beginChange() != nil {
+		return
+	}
+	lineCount := len(e.EditorContent)
+	first := min(at, lineCount) // Lines added to reach at are part of the change
+	e.ensureLineExists(at - 1)
+	if at > len(e.EditorContent) {
+		at = len(e.EditorContent)
+	}
+	newContent := make([]string, 0, len(e.EditorContent)+len(lines))
+	newContent = append(newContent, e.EditorContent[:at]...)
+	newContent = append(newContent, lines...)
+	newContent = append(newContent, e.EditorContent[at:]...)
+	e.EditorContent = newContent
+	e.adjustMarks(at, len(lines))
+	if len(lines) > 0 {
+		e.markChanged(LineChange{Line: first, New: len(e.EditorContent) - lineCount})
+	}
+}
+
+// FirstNonBlank returns the column of the first non-whitespace character of line.
+func FirstNonBlank(line string) int {
+	return len(line) - len(strings.TrimLeft(line, " \t"))
+}
@@ -0,0 +1,103 @@
+package editor
+
+import "strings"
+
+// Register holds yanked or deleted text. Linewise text ends with a newline.
+type Register struct {
+	Text     string
+	Linewise bool
+}
+
+// UnnamedRegister is the register used when no register name is given.
+const UnnamedRegister byte = '"'
+
+// SetRegister stores reg under name. Unless name is the unnamed register, the
+// unnamed register is updated too so that a plain put uses the latest text.
+func (e *Editor) SetRegister(name byte, reg Register) {
+	if e.Registers == nil {
+		e.Registers = make(map[byte]Register)
+	}
+	e.Registers[name] = reg
+	if name != UnnamedRegister {
+		e.Registers[UnnamedRegister] = reg
+	}
+}
+
+// GetRegister returns the contents of register name.
+func (e *Editor) GetRegister(name byte) (Register, bool) {
+	reg, ok := e.Registers[name]
+	return reg, ok
+}
+
+// YankRegion copies the text covered by r into the unnamed register.
+func (e *Editor) YankRegion(r Region) {
+	e.SetRegister(UnnamedRegister, Register{Text: e.RegionText(r), Linewise: r.Linewise})
+}
+
+// Put inserts the contents of register name after the cursor (like Vim's p)
+// or before it (P). Linewise text is put below or above the cursor line.
+// It returns false if the register is empty.
+func (e *Editor) Put(name byte, after bool) bool {
+	reg, ok := e.GetRegister(name)
+	if !ok || reg.Text == "" {
+		return false
+	}
+	e.ensureLineExists(e.CursorY)
+
+	if reg.Linewise {
+		lines := strings.Split(strings.TrimSuffix(reg.Text, "\n"), "\n")
+		at := e.CursorY
+		if after {
+			at++
+		}
+		e.InsertLines(at, lines)
+		e.SetMark(MarkChangeStart, Position{Line: at})
+		e.SetMark(MarkChangeEnd, Position{Line: at + len(lines) - 1})
+		e.CursorY = at
+		e.CursorX = FirstNonBlank(e.EditorContent[at])
+		return true
+	}
+
+	p := Position{Line: e.CursorY, Col: e.CursorX}
+	if after && len(e.EditorContent[e.CursorY]) > 0 {
+		p.Col = min(p.Col+1, len(e.EditorContent[e.CursorY]))
+	}
+	end := e.InsertText(p, reg.Text)
+	e.CursorY = end.Line
+	e.CursorX = max(end.Col-1, 0)
+	e.SetMark(MarkChangeStart, p)
+	e.SetMark(MarkChangeEnd, Position{Line: e.CursorY, Col: e.CursorX})
+	return true
+}
+
+// StartRecording begins recording typed keys into register name. An
+// uppercase name appends to the matching lowercase register.
+func (e *Editor) StartRecording(name byte) {
+	e.RecordingRegister = name
+	e.MacroKeys = nil
+}
+
+// StopRecording ends macro recording and stores the recorded keys, except
+// for the final key that stopped the recording. Unlike yanks, recording
+// leaves the unnamed register alone.
+func (e *Editor) StopRecording() {
+	name := e.RecordingRegister
+	if name == 0 {
+		return
+	}
+	keys := e.MacroKeys
+	if len(keys) > 0 {
+		keys = keys[:len(keys)-1]
+	}
+
+	if e.Registers == nil {
+		e.Registers = make(map[byte]Register)
+	}
+	if name >= 'A' && name <= 'Z' {
+		name += 'a' - 'A'
+		keys = append([]byte(e.Registers[name].Text), keys...)
+	}
+	e.Registers[name] = Register{Text: string(keys)}
+	e.RecordingRegister = 0
+	e.MacroKeys = nil
+}
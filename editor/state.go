@@ -0,0 +1,282 @@
+package editor
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+// maxStateFiles is the number of files whose last cursor position is kept.
+const maxStateFiles = 100
+
+// State is what the editor remembers between runs: the histories, the
+// registers, the file marks and where the cursor was left in each file. It
+// is kept in a JSON file, which editors running at once share.
+type State struct {
+	History   map[string][]HistoryEntry // Keyed by history kind, such as ":" or "/"
+	Registers map[string]Register
+	FileMarks map[string]FileMark
+	Files     map[string]FileState // Keyed by absolute path
+}
+
+// FileState is what is remembered about a file that was edited.
+type FileState struct {
+	Cursor Position
+	Time   time.Time // When the file was last left
+}
+
+// registerJSON is how a register is kept in the state file. A JSON string
+// only holds UTF-8, so text that is not, such as a macro recorded with the
+// arrow keys, is kept as Bytes instead, which encode as base64.
+type registerJSON struct {
+	Text     string `json:",omitempty"`
+	Bytes    []byte `json:",omitempty"`
+	Linewise bool
+}
+
+// MarshalJSON encodes the register as a registerJSON.
+func (r Register) MarshalJSON() ([]byte, error) {
+	if utf8.ValidString(r.Text) {
+		return json.Marshal(registerJSON{Text: r.Text, Linewise: r.Linewise})
+	}
+	return json.Marshal(registerJSON{Bytes: []byte(r.Text), Linewise: r.Linewise})
+}
+
+// UnmarshalJSON decodes a register encoded by MarshalJSON.
+func (r *Register) UnmarshalJSON(data []byte) error {
+	var rj registerJSON
+	if err := json.Unmarshal(data, &rj); err != nil {
+		return err
+	}
+	*r = Register{Text: rj.Text + string(rj.Bytes), Linewise: rj.Linewise}
+	return nil
+}
+
+// StatePath returns the path of the state file:
+// $XDG_STATE_HOME/goedit/state.json, or ~/.local/state/goedit/state.json
+// when XDG_STATE_HOME is not set. It returns "" if neither can be determined.
+func StatePath() string {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".local", "state")
+	}
+	return filepath.Join(dir, "goedit", "state.json")
+}
+
+// ReadState reads a state file. A missing file gives an empty state.
+func ReadState(path string) (*State, error) {
+	s := &State{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return &State{}, fmt.Errorf("Error reading state file %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// persistentRegister reports whether register name is kept between runs:
+// the unnamed, named and numbered registers.
+func persistentRegister(name byte) bool {
+	return name == UnnamedRegister || name >= 'a' && name <= 'z' || name >= '0' && name <= '9'
+}
+
+// RestoreState puts the histories, registers and file marks of s into the
+// editor.
+func (e *Editor) RestoreState(s *State) {
+	if e.History == nil {
+		e.History = make(map[byte][]HistoryEntry)
+	}
+	for kind, lines := range s.History {
+		if len(kind) == 1 {
+			e.History[kind[0]] = e.trimHistory(slices.Clone(lines))
+		}
+	}
+	for name, reg := range s.Registers {
+		if len(name) == 1 && persistentRegister(name[0]) {
+			e.Registers[name[0]] = reg
+		}
+	}
+	for name, fm := range s.FileMarks {
+		if len(name) == 1 && name[0] >= 'A' && name[0] <= 'Z' {
+			e.FileMarks[name[0]] = fm
+		}
+	}
+	if e.FilePositions == nil {
+		e.FilePositions = make(map[string]FileState)
+	}
+	for path, f := range s.Files {
+		e.FilePositions[path] = f
+	}
+}
+
+// RememberCursor records where the cursor is in the file being edited, to
+// put it back there when the file is edited again.
+func (e *Editor) RememberCursor() {
+	if e.Filename == "" {
+		return
+	}
+	path, err := filepath.Abs(e.Filename)
+	if err != nil {
+		return
+	}
+	if e.FilePositions == nil {
+		e.FilePositions = make(map[string]FileState)
+	}
+	e.FilePositions[path] = FileState{Cursor: Position{Line: e.CursorY, Col: e.CursorX}, Time: time.Now()}
+}
+
+// RestoreCursor moves the cursor to where it was left in the file being
+// edited, in this run or an earlier one.
+func (e *Editor) RestoreCursor() {
+	path, err := filepath.Abs(e.Filename)
+	if err != nil {
+		return
+	}
+	if f, ok := e.FilePositions[path]; ok {
+		p := e.clampPosition(f.Cursor)
+		e.CursorY, e.CursorX = p.Line, p.Col
+	}
+}
+
+// WriteState saves the editor's state to the state file at path. Another
+// editor may have saved its own since this one read the file, as loaded, so
+// the file is read again and the two merged: the newest history lines and
+// file positions are kept, and registers and file marks are taken from this
+// editor only if they changed since it was loaded.
+func (e *Editor) WriteState(path string, loaded *State) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	unlock, err := lockFile(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	s, err := ReadState(path)
+	if err != nil {
+		s = &State{} // Replace a damaged file
+	}
+	e.mergeState(s, loaded)
+
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(filepath.Dir(path), "state-*.json")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(append(data, '\n'))
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+// mergeState merges the editor's state into s, which was read from the state
+// file, as WriteState describes.
+func (e *Editor) mergeState(s, loaded *State) {
+	if s.History == nil {
+		s.History = make(map[string][]HistoryEntry)
+	}
+	for kind, lines := range e.History {
+		merged := make(map[string]HistoryEntry)
+		for _, h := range append(slices.Clone(s.History[string(kind)]), lines...) {
+			if old, ok := merged[h.Text]; !ok || h.Time.After(old.Time) {
+				merged[h.Text] = h
+			}
+		}
+		all := make([]HistoryEntry, 0, len(merged))
+		for _, h := range merged {
+			all = append(all, h)
+		}
+		slices.SortStableFunc(all, func(a, b HistoryEntry) int { return a.Time.Compare(b.Time) })
+		s.History[string(kind)] = e.trimHistory(all)
+	}
+
+	if s.Registers == nil {
+		s.Registers = make(map[string]Register)
+	}
+	for name, reg := range e.Registers {
+		if old, ok := loaded.Registers[string(name)]; persistentRegister(name) && (!ok || old != reg) {
+			s.Registers[string(name)] = reg
+		}
+	}
+	if s.FileMarks == nil {
+		s.FileMarks = make(map[string]FileMark)
+	}
+	for name, fm := range e.FileMarks {
+		if old, ok := loaded.FileMarks[string(name)]; !ok || old != fm {
+			if path, err := filepath.Abs(fm.Filename); err == nil && fm.Filename != "" {
+				fm.Filename = path
+			}
+			s.FileMarks[string(name)] = fm
+		}
+	}
+
+	if s.Files == nil {
+		s.Files = make(map[string]FileState)
+	}
+	e.RememberCursor()
+	for path, f := range e.FilePositions {
+		if old, ok := s.Files[path]; !ok || f.Time.After(old.Time) {
+			s.Files[path] = f
+		}
+	}
+	for len(s.Files) > maxStateFiles {
+		oldest := ""
+		for path, f := range s.Files {
+			if oldest == "" || f.Time.Before(s.Files[oldest].Time) {
+				oldest = path
+			}
+		}
+		delete(s.Files, oldest)
+	}
+}
+
+// lockFile creates the lock file at path, waiting while another editor
+// holds it, and returns a function that removes it. A lock left behind by an
+// editor that did not finish is taken over after a while.
+func lockFile(path string) (unlock func(), err error) {
+	const wait, stale = 2 * time.Second, 10 * time.Second
+	start := time.Now()
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, err := os.Stat(path); err == nil && time.Since(info.ModTime()) > stale {
+			os.Remove(path)
+			continue
+		}
+		if time.Since(start) > wait {
+			return nil, fmt.Errorf("State file is locked: %s", path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
@@ -0,0 +1,131 @@
+package editor
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStateRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "goedit", "state.json")
+	file := filepath.Join(t.TempDir(), "a.txt")
+
+	e := NewEditor(80, 24)
+	e.Filename = file
+	e.EditorContent = []string{"one", "two", "three"}
+	e.CursorY, e.CursorX = 2, 3
+	e.AddHistory(HistoryCommand, "set nu")
+	e.AddHistory(HistorySearch, "fo+")
+	e.SetRegister('a', Register{Text: "word"})
+	e.SetRegister('q', Register{Text: "j\xfbx\x1b"}) // A macro with an arrow key
+	e.SetRegister('+', Register{Text: "clipboard"})
+	e.SetMark('A', Position{Line: 1})
+	if err := e.WriteState(path, &State{}); err != nil {
+		t.Fatalf("WriteState: %v", err)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file removed, got %v", err)
+	}
+
+	s, err := ReadState(path)
+	if err != nil {
+		t.Fatalf("ReadState: %v", err)
+	}
+	e2 := NewEditor(80, 24)
+	e2.RestoreState(s)
+	if h := e2.History[HistoryCommand]; len(h) != 1 || h[0].Text != "set nu" {
+		t.Errorf("Expected history \"set nu\", got %v", h)
+	}
+	if h := e2.History[HistorySearch]; len(h) != 1 || h[0].Text != "fo+" {
+		t.Errorf("Expected search history \"fo+\", got %v", h)
+	}
+	if reg, _ := e2.GetRegister('a'); reg.Text != "word" {
+		t.Errorf("Expected register a \"word\", got %q", reg.Text)
+	}
+	if reg, _ := e2.GetRegister('q'); reg.Text != "j\xfbx\x1b" {
+		t.Errorf("Expected register q \"j\\xfbx\\x1b\", got %q", reg.Text)
+	}
+	if _, ok := e2.GetRegister('+'); ok {
+		t.Errorf("Expected register + not to be kept")
+	}
+
+	e2.Filename = file
+	e2.EditorContent = []string{"one", "two", "three"}
+	e2.RestoreCursor()
+	if e2.CursorY != 2 || e2.CursorX != 3 {
+		t.Errorf("Expected cursor 2,3, got %d,%d", e2.CursorY, e2.CursorX)
+	}
+	if p, err := e2.MarkPosition('A'); err != nil || p.Line != 1 {
+		t.Errorf("Expected mark A on line 1, got %v, %v", p, err)
+	}
+
+	// The cursor is kept within a file that got shorter.
+	e2.EditorContent = []string{"x"}
+	e2.RestoreCursor()
+	if e2.CursorY != 0 || e2.CursorX != 1 {
+		t.Errorf("Expected cursor 0,1, got %d,%d", e2.CursorY, e2.CursorX)
+	}
+}
+
+func TestStateMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	// Two editors start from the same state.
+	first := NewEditor(80, 24)
+	first.AddHistory(HistoryCommand, "old")
+	first.SetRegister('a', Register{Text: "a0"})
+	first.SetRegister('b', Register{Text: "b0"})
+	if err := first.WriteState(path, &State{}); err != nil {
+		t.Fatalf("WriteState: %v", err)
+	}
+	loaded, _ := ReadState(path)
+	e1, e2 := NewEditor(80, 24), NewEditor(80, 24)
+	e1.RestoreState(loaded)
+	e2.RestoreState(loaded)
+
+	e1.AddHistory(HistoryCommand, "one")
+	e1.SetRegister('a', Register{Text: "a1"})
+	e2.AddHistory(HistoryCommand, "two")
+	e2.SetRegister('b', Register{Text: "b2"})
+	if err := e1.WriteState(path, loaded); err != nil {
+		t.Fatalf("WriteState: %v", err)
+	}
+	if err := e2.WriteState(path, loaded); err != nil {
+		t.Fatalf("WriteState: %v", err)
+	}
+
+	s, _ := ReadState(path)
+	var lines []string
+	for _, h := range s.History[":"] {
+		lines = append(lines, h.Text)
+	}
+	if len(lines) != 3 || lines[0] != "old" || lines[1] != "one" || lines[2] != "two" {
+		t.Errorf("Expected history old, one, two, got %q", lines)
+	}
+	if s.Registers["a"].Text != "a1" || s.Registers["b"].Text != "b2" {
+		t.Errorf("Expected registers a1 and b2, got %q and %q", s.Registers["a"].Text, s.Registers["b"].Text)
+	}
+}
+
+func TestLockFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json.lock")
+	unlock, err := lockFile(path)
+	if err != nil {
+		t.Fatalf("lockFile: %v", err)
+	}
+	done := make(chan struct{})
+	go func() {
+		unlock2, err := lockFile(path)
+		if err == nil {
+			unlock2()
+		}
+		close(done)
+	}()
+	select {
+	case <-done:
+		t.Fatalf("Expected the second lock to wait")
+	default:
+	}
+	unlock()
+	<-done
+}
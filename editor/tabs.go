@@ -0,0 +1,141 @@
+package editor
+
+import "strings"
+
+// TabStop returns the tabstop option, at least 1.
+func (e *Editor) TabStop() int {
+	return max(e.IntOption("tabstop"), 1)
+}
+
+// DisplayCol returns the screen column, counted from the start of the line,
+// at which byte col of line is drawn, with tabs expanded to tabstop.
+func (e *Editor) DisplayCol(line string, col int) int {
+	ts := e.TabStop()
+	width := 0
+	for i := 0; i < col && i < len(line); i++ {
+		if line[i] == '\t' {
+			width += ts - width%ts
+		} else {
+			width++
+		}
+	}
+	return width + max(col-len(line), 0)
+}
+
+// CursorDisplayCol returns the screen column of the cursor within its line.
+func (e *Editor) CursorDisplayCol() int {
+	if e.CursorY >= len(e.EditorContent) {
+		return e.CursorX
+	}
+	return e.DisplayCol(e.EditorContent[e.CursorY], e.CursorX)
+}
+
+// ExpandTabs returns line as it is drawn, with each tab replaced by the
+// spaces up to the next tab stop.
+func (e *Editor) ExpandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	ts := e.TabStop()
+	var b strings.Builder
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\t' {
+			b.WriteString(strings.Repeat(" ", ts-b.Len()%ts))
+		} else {
+			b.WriteByte(line[i])
+		}
+	}
+	return b.String()
+}
+
+// SoftTabStop returns the width of the soft tab stops used by Tab and
+// Backspace in Insert mode: softtabstop, or the indent width when it is
+// negative. It returns 0 when softtabstop is off.
+func (e *Editor) SoftTabStop() int {
+	sts := e.IntOption("softtabstop")
+	if sts < 0 {
+		return e.ShiftWidth()
+	}
+	return sts
+}
+
+// InsertTab inserts white space at the cursor up to the next tab stop, as
+// the Tab key does in Insert mode. With softtabstop the stops are that far
+// apart and are reached with a mix of tabs and spaces; with expandtab only
+// spaces are used. Otherwise a tab character is inserted.
+func (e *Editor) InsertTab() {
+	sts := e.SoftTabStop()
+	if sts == 0 && !e.BoolOption("expandtab") {
+		e.InsertChar('\t')
+		return
+	}
+	e.ensureLineExists(e.CursorY)
+	col := e.DisplayCol(e.EditorContent[e.CursorY], e.CursorX)
+	if sts == 0 { // expandtab: spaces up to the next tabstop
+		for n := e.TabStop() - col%e.TabStop(); n > 0; n-- {
+			e.InsertChar(' ')
+		}
+		return
+	}
+	e.replaceWhiteSpaceBefore(col + sts - col%sts)
+}
+
+// DeleteSoftTab deletes the white space before the cursor back to the
+// previous soft tab stop, as Backspace does with softtabstop set. It returns
+// false, changing nothing, if softtabstop is off or the cursor does not
+// follow white space.
+func (e *Editor) DeleteSoftTab() bool {
+	sts := e.SoftTabStop()
+	if sts == 0 || e.CursorY >= len(e.EditorContent) || e.CursorX == 0 {
+		return false
+	}
+	line := e.EditorContent[e.CursorY]
+	if e.CursorX > len(line) || (line[e.CursorX-1] != ' ' && line[e.CursorX-1] != '\t') {
+		return false
+	}
+	col := e.DisplayCol(line, e.CursorX)
+	e.replaceWhiteSpaceBefore((col - 1) / sts * sts)
+	return true
+}
+
+// replaceWhiteSpaceBefore rewrites the run of white space before the cursor
+// so that the cursor ends up at screen column target, or at the start of the
+// run if that is further right. Tabs are used where possible unless
+// expandtab is set.
+func (e *Editor) replaceWhiteSpaceBefore(target int) {
+	if e.beginChange() != nil {
+		return
+	}
+	line := e.EditorContent[e.CursorY]
+	start := e.CursorX
+	for start > 0 && (line[start-1] == ' ' || line[start-1] == '\t') {
+		start--
+	}
+	from := e.DisplayCol(line, start)
+	target = max(target, from)
+	white := strings.Repeat(" ", target-from)
+	if !e.BoolOption("expandtab") {
+		white = e.whiteSpaceBetween(from, target)
+	}
+	e.EditorContent[e.CursorY] = line[:start] + white + line[e.CursorX:]
+	e.CursorX = start + len(white)
+	e.markChanged(LineChange{Line: e.CursorY, Old: 1, New: 1})
+}
+
+// whiteSpaceBetween returns the tabs and spaces that fill the screen columns
+// from..to, using tabs as far as the last tab stop.
+func (e *Editor) whiteSpaceBetween(from, to int) string {
+	ts := e.TabStop()
+	var b strings.Builder
+	for col := from; col < to; {
+		next := col + ts - col%ts
+		if next <= to {
+			b.WriteByte('\t')
+			col = next
+		} else {
+			b.WriteString(strings.Repeat(" ", to-col))
+			col = to
+		}
+	}
+	return b.String()
+}
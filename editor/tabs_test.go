@@ -0,0 +1,108 @@
+package editor
+
+import "testing"
+
+func TestDisplayCol(t *testing.T) {
+	tests := []struct {
+		line     string
+		col      int
+		expected int
+	}{
+		{line: "abc", col: 2, expected: 2},
+		{line: "\tx", col: 1, expected: 4},
+		{line: "a\tx", col: 2, expected: 4},
+		{line: "abcd\tx", col: 5, expected: 8},
+		{line: "\t\tx", col: 2, expected: 8},
+		{line: "ab", col: 4, expected: 4},
+	}
+
+	ed := NewEditor(80, 24)
+	_ = ed.SetOption("tabstop", 4)
+	for _, tt := range tests {
+		if got := ed.DisplayCol(tt.line, tt.col); got != tt.expected {
+			t.Errorf("Expected column %d of %q to display at %d, got %d", tt.col, tt.line, tt.expected, got)
+		}
+	}
+	if got := ed.ExpandTabs("a\tbcde\tf"); got != "a   bcde    f" {
+		t.Errorf("Expected expanded line %q, got %q", "a   bcde    f", got)
+	}
+}
+
+func TestInsertTab(t *testing.T) {
+	tests := []struct {
+		name            string
+		expandtab       bool
+		softtabstop     int
+		line            string
+		cursorX         int
+		expectedLine    string
+		expectedCursorX int
+	}{
+		{name: "Tab character", line: "ab", cursorX: 1, expectedLine: "a\tb", expectedCursorX: 2},
+		{name: "Spaces to next tabstop", expandtab: true, line: "ab", cursorX: 1, expectedLine: "a       b", expectedCursorX: 8},
+		{name: "Spaces after a tab", expandtab: true, line: "\tab", cursorX: 1, expectedLine: "\t        ab", expectedCursorX: 9},
+		{name: "Soft tab of spaces", expandtab: true, softtabstop: 4, line: "ab", cursorX: 2, expectedLine: "ab  ", expectedCursorX: 4},
+		{name: "Soft tab below tabstop", softtabstop: 4, line: "x", cursorX: 0, expectedLine: "    x", expectedCursorX: 4},
+		{name: "Soft tabs become a tab", softtabstop: 4, line: "    x", cursorX: 4, expectedLine: "\tx", expectedCursorX: 1},
+		{name: "Soft tab uses shiftwidth", softtabstop: -1, line: "", cursorX: 0, expectedLine: "   ", expectedCursorX: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			_ = ed.SetOption("shiftwidth", 3)
+			_ = ed.SetOption("expandtab", tt.expandtab)
+			_ = ed.SetOption("softtabstop", tt.softtabstop)
+			ed.EditorContent = []string{tt.line}
+			ed.CursorX = tt.cursorX
+
+			ed.InsertTab()
+			if ed.EditorContent[0] != tt.expectedLine {
+				t.Errorf("Expected line %q, got %q", tt.expectedLine, ed.EditorContent[0])
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+		})
+	}
+}
+
+func TestDeleteSoftTab(t *testing.T) {
+	tests := []struct {
+		name            string
+		expandtab       bool
+		softtabstop     int
+		line            string
+		cursorX         int
+		expectedDeleted bool
+		expectedLine    string
+		expectedCursorX int
+	}{
+		{name: "Off", line: "    x", cursorX: 4, expectedLine: "    x", expectedCursorX: 4},
+		{name: "After text", softtabstop: 4, line: "abc", cursorX: 3, expectedLine: "abc", expectedCursorX: 3},
+		{name: "Whole soft tab", expandtab: true, softtabstop: 4, line: "        x", cursorX: 8, expectedDeleted: true, expectedLine: "    x", expectedCursorX: 4},
+		{name: "Partial soft tab", expandtab: true, softtabstop: 4, line: "ab   x", cursorX: 5, expectedDeleted: true, expectedLine: "ab  x", expectedCursorX: 4},
+		{name: "Stops at text", expandtab: true, softtabstop: 4, line: "abc x", cursorX: 4, expectedDeleted: true, expectedLine: "abcx", expectedCursorX: 3},
+		{name: "Tab split into spaces", softtabstop: 4, line: "\tx", cursorX: 1, expectedDeleted: true, expectedLine: "    x", expectedCursorX: 4},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			_ = ed.SetOption("expandtab", tt.expandtab)
+			_ = ed.SetOption("softtabstop", tt.softtabstop)
+			ed.EditorContent = []string{tt.line}
+			ed.CursorX = tt.cursorX
+
+			if deleted := ed.DeleteSoftTab(); deleted != tt.expectedDeleted {
+				t.Errorf("Expected deleted %t, got %t", tt.expectedDeleted, deleted)
+			}
+			if ed.EditorContent[0] != tt.expectedLine {
+				t.Errorf("Expected line %q, got %q", tt.expectedLine, ed.EditorContent[0])
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+		})
+	}
+}
@@ -0,0 +1,407 @@
+package editor
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// tagPattern matches an XML/HTML open, close or self-closing tag.
+var tagPattern = regexp.MustCompile(`<(/?)([A-Za-z][A-Za-z0-9:._-]*)[^<>]*?(/?)>`)
+
+// SelectTextObject returns the region of the text object obj around the
+// cursor, using the "i" (inner) or "a" variant. obj is the key that names the
+// object: w, W, s, p, a quote (' " `), a bracket ((, ), b, [, ], {, }, B, <,
+// >) or t for tags. For words, sentences and paragraphs count selects that
+// many objects; for quotes, brackets and tags it selects the count-th
+// enclosing pair. The second result is false if there is no such object.
+func (e *Editor) SelectTextObject(obj byte, inner bool, count int) (Region, bool) {
+	if e.CursorY >= len(e.EditorContent) {
+		return Region{}, false
+	}
+	if count < 1 {
+		count = 1
+	}
+	cursor := Position{Line: e.CursorY, Col: e.CursorX}
+
+	switch obj {
+	case 'w':
+		return e.wordObject(cursor, inner, count, false), true
+	case 'W':
+		return e.wordObject(cursor, inner, count, true), true
+	case 's':
+		return e.sentenceObject(cursor, inner, count)
+	case 'p':
+		return e.paragraphObject(cursor, inner, count), true
+	case '"', '\'', '`':
+		return e.quoteObject(cursor, obj, inner)
+	case '(', ')', 'b':
+		return e.bracketObject(cursor, '(', ')', inner, count)
+	case '[', ']':
+		return e.bracketObject(cursor, '[', ']', inner, count)
+	case '{', '}', 'B':
+		return e.bracketObject(cursor, '{', '}', inner, count)
+	case '<', '>':
+		return e.bracketObject(cursor, '<', '>', inner, count)
+	case 't':
+		return e.tagObject(cursor, inner, count)
+	}
+	return Region{}, false
+}
+
+// IsPairObject reports whether obj names a delimited text object (quotes,
+// brackets or tags) rather than a run of words, sentences or paragraphs.
+func IsPairObject(obj byte) bool {
+	return strings.IndexByte("\"'`()b[]{}B<>t", obj) >= 0
+}
+
+// charClass groups characters for word motions: 0 for blanks, 1 for
+// punctuation and 2 for word characters. For WORDs every non-blank is 2.
+func charClass(c byte, bigWord bool) int {
+	switch {
+	case c == ' ' || c == '\t':
+		return 0
+	case bigWord:
+		return 2
+	case c == '_' || c >= 0x80 ||
+		(c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9'):
+		return 2
+	}
+	return 1
+}
+
+// runObject selects count runs of items in [0, n) sharing a class around
+// index i. Class 0 is treated as white space: the "a" variant adds the white
+// space following the selection, or preceding it if there is none, as Vim does
+// for aw and ap.
+func runObject(n, i int, class func(int) int, inner bool, count int) (int, int) {
+	runEnd := func(j int) int {
+		c := class(j)
+		for j+1 < n && class(j+1) == c {
+			j++
+		}
+		return j
+	}
+
+	start := i
+	for start > 0 && class(start-1) == class(i) {
+		start--
+	}
+	end := start - 1
+	if inner {
+		for k := 0; k < count && end+1 < n; k++ {
+			end = runEnd(end + 1)
+		}
+		return start, end
+	}
+
+	startedOnSpace := class(i) == 0
+	trailing := false
+	for k := 0; k < count && end+1 < n; k++ {
+		end = runEnd(end + 1)
+		if startedOnSpace {
+			if end+1 < n {
+				end = runEnd(end + 1)
+			}
+		} else if end+1 < n && class(end+1) == 0 {
+			end = runEnd(end + 1)
+			trailing = true
+		} else {
+			trailing = false
+		}
+	}
+	if !startedOnSpace && !trailing {
+		for start > 0 && class(start-1) == 0 {
+			start--
+		}
+	}
+	return start, end
+}
+
+// wordObject selects words (iw, aw) or WORDs (iW, aW) on the cursor line.
+func (e *Editor) wordObject(p Position, inner bool, count int, bigWord bool) Region {
+	line := e.EditorContent[p.Line]
+	if len(line) == 0 {
+		return Region{Start: Position{p.Line, 0}, End: Position{p.Line, -1}}
+	}
+	col := min(p.Col, len(line)-1)
+	start, end := runObject(len(line), col, func(i int) int { return charClass(line[i], bigWord) }, inner, count)
+	return Region{Start: Position{p.Line, start}, End: Position{p.Line, end}}
+}
+
+// paragraphObject selects paragraphs (ip, ap): runs of non-blank lines
+// separated by blank ones. The result is linewise.
+func (e *Editor) paragraphObject(p Position, inner bool, count int) Region {
+	class := func(i int) int {
+		if strings.TrimSpace(e.EditorContent[i]) == "" {
+			return 0
+		}
+		return 1
+	}
+	start, end := runObject(len(e.EditorContent), p.Line, class, inner, count)
+	return Region{Start: Position{start, 0}, End: Position{end, 0}, Linewise: true}
+}
+
+// sentenceObject selects sentences (is, as). A sentence ends at '.', '!' or
+// '?', optionally followed by closing brackets or quotes, and then white
+// space. Sentences never extend past the paragraph around the cursor.
+func (e *Editor) sentenceObject(p Position, inner bool, count int) (Region, bool) {
+	blank := func(i int) bool { return strings.TrimSpace(e.EditorContent[i]) == "" }
+	if blank(p.Line) {
+		return Region{}, false
+	}
+	first, last := p.Line, p.Line
+	for first > 0 && !blank(first-1) {
+		first--
+	}
+	for last+1 < len(e.EditorContent) && !blank(last+1) {
+		last++
+	}
+
+	text := strings.Join(e.EditorContent[first:last+1], "\n")
+	base := e.offsetOf(Position{first, 0})
+	cursor := e.offsetOf(p) - base
+	isSpace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\n' }
+
+	// Split the paragraph into sentences as inclusive [start, end] spans
+	// that exclude the white space between them.
+	var spans [][2]int
+	i := 0
+	for i < len(text) && isSpace(text[i]) {
+		i++
+	}
+	for i < len(text) {
+		start, j := i, i
+		for j < len(text) {
+			if strings.IndexByte(".!?", text[j]) >= 0 {
+				k := j + 1
+				for k < len(text) && strings.IndexByte(")]\"'", text[k]) >= 0 {
+					k++
+				}
+				if k == len(text) || isSpace(text[k]) {
+					j = k
+					break
+				}
+			}
+			j++
+		}
+		end := j - 1
+		for end > start && isSpace(text[end]) {
+			end--
+		}
+		spans = append(spans, [2]int{start, end})
+		i = j
+		for i < len(text) && isSpace(text[i]) {
+			i++
+		}
+	}
+	if len(spans) == 0 {
+		return Region{}, false
+	}
+
+	idx := 0
+	for idx+1 < len(spans) && spans[idx+1][0] <= cursor {
+		idx++
+	}
+	toRegion := func(start, end int) Region {
+		return Region{Start: e.positionOf(base + start), End: e.positionOf(base + end)}
+	}
+
+	// The cursor is in the white space after a sentence.
+	if cursor > spans[idx][1] && idx+1 < len(spans) {
+		if inner {
+			return toRegion(spans[idx][1]+1, spans[idx+1][0]-1), true
+		}
+		lastIdx := min(idx+count, len(spans)-1)
+		return toRegion(spans[idx][1]+1, spans[lastIdx][1]), true
+	}
+
+	lastIdx := min(idx+count-1, len(spans)-1)
+	if inner {
+		return toRegion(spans[idx][0], spans[lastIdx][1]), true
+	}
+	if lastIdx+1 < len(spans) {
+		return toRegion(spans[idx][0], spans[lastIdx+1][0]-1), true
+	}
+	start := spans[idx][0]
+	if idx > 0 {
+		start = spans[idx-1][1] + 1
+	}
+	return toRegion(start, spans[lastIdx][1]), true
+}
+
+// quoteObject selects a quoted string on the cursor line (i", a" and so on).
+// Quotes pair up from the start of the line and escaped quotes are skipped.
+// If the cursor is not inside a pair, the first pair after it is used.
+func (e *Editor) quoteObject(p Position, quote byte, inner bool) (Region, bool) {
+	line := e.EditorContent[p.Line]
+	var quotes []int
+	for i := 0; i < len(line); i++ {
+		if line[i] == '\\' {
+			i++
+			continue
+		}
+		if line[i] == quote {
+			quotes = append(quotes, i)
+		}
+	}
+
+	for k := 0; k+1 < len(quotes); k += 2 {
+		open, closing := quotes[k], quotes[k+1]
+		if p.Col > closing {
+			continue
+		}
+		if inner {
+			return Region{Start: Position{p.Line, open + 1}, End: Position{p.Line, closing - 1}}, true
+		}
+		// Include trailing white space, or leading white space if there is none.
+		start, end := open, closing
+		for end+1 < len(line) && (line[end+1] == ' ' || line[end+1] == '\t') {
+			end++
+		}
+		if end == closing {
+			for start > 0 && (line[start-1] == ' ' || line[start-1] == '\t') {
+				start--
+			}
+		}
+		return Region{Start: Position{p.Line, start}, End: Position{p.Line, end}}, true
+	}
+	return Region{}, false
+}
+
+// enclosingOpen scans backwards from offset from for an unmatched open
+// bracket and returns its offset, or -1 if there is none.
+func enclosingOpen(text string, from int, open, closing byte) int {
+	depth := 0
+	for i := from; i >= 0; i-- {
+		switch text[i] {
+		case closing:
+			depth++
+		case open:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// matchingClose returns the offset of the bracket closing the one at offset
+// open, or -1 if it is unbalanced.
+func matchingClose(text string, open int, openChar, closing byte) int {
+	depth := 0
+	for i := open + 1; i < len(text); i++ {
+		switch text[i] {
+		case openChar:
+			depth++
+		case closing:
+			if depth == 0 {
+				return i
+			}
+			depth--
+		}
+	}
+	return -1
+}
+
+// bracketObject selects the count-th bracket pair enclosing the cursor, which
+// may span several lines. When the brackets sit on their own lines the inner
+// variant selects the lines between them linewise, as Vim does for i{.
+func (e *Editor) bracketObject(p Position, open, closing byte, inner bool, count int) (Region, bool) {
+	text := e.bufferText()
+	cursor := e.offsetOf(p)
+	if cursor >= len(text) {
+		cursor = len(text) - 1
+	}
+	if cursor < 0 {
+		return Region{}, false
+	}
+
+	var o int
+	switch text[cursor] {
+	case open:
+		o = cursor
+	case closing:
+		o = enclosingOpen(text, cursor-1, open, closing)
+	default:
+		o = enclosingOpen(text, cursor, open, closing)
+	}
+	for n := 1; n < count && o >= 0; n++ {
+		o = enclosingOpen(text, o-1, open, closing)
+	}
+	if o < 0 {
+		return Region{}, false
+	}
+	c := matchingClose(text, o, open, closing)
+	if c < 0 {
+		return Region{}, false
+	}
+
+	if !inner {
+		return Region{Start: e.positionOf(o), End: e.positionOf(c)}, true
+	}
+	openPos, closePos := e.positionOf(o), e.positionOf(c)
+	if closePos.Line > openPos.Line+1 && o+1 < len(text) && text[o+1] == '\n' &&
+		FirstNonBlank(e.EditorContent[closePos.Line]) == closePos.Col {
+		return Region{
+			Start:    Position{openPos.Line + 1, 0},
+			End:      Position{closePos.Line - 1, 0},
+			Linewise: true,
+		}, true
+	}
+	start, end := o+1, c-1
+	if start <= end && text[start] == '\n' {
+		start++
+	}
+	return Region{Start: e.positionOf(start), End: e.positionOf(end)}, true
+}
+
+// tagObject selects the count-th XML/HTML element enclosing the cursor (it,
+// at). Self-closing tags are ignored and unclosed open tags are skipped.
+func (e *Editor) tagObject(p Position, inner bool, count int) (Region, bool) {
+	text := e.bufferText()
+	cursor := e.offsetOf(p)
+
+	type openTag struct {
+		name       string
+		start, end int
+	}
+	type element struct {
+		openStart, openEnd, closeStart, closeEnd int
+	}
+	var stack []openTag
+	var enclosing []element
+	for _, m := range tagPattern.FindAllStringSubmatchIndex(text, -1) {
+		closingTag, selfClosing := m[3] > m[2], m[7] > m[6]
+		name := text[m[4]:m[5]]
+		switch {
+		case selfClosing:
+		case !closingTag:
+			stack = append(stack, openTag{name, m[0], m[1] - 1})
+		default:
+			for k := len(stack) - 1; k >= 0; k-- {
+				if !strings.EqualFold(stack[k].name, name) {
+					continue
+				}
+				el := element{stack[k].start, stack[k].end, m[0], m[1] - 1}
+				if el.openStart <= cursor && cursor <= el.closeEnd {
+					enclosing = append(enclosing, el)
+				}
+				stack = stack[:k]
+				break
+			}
+		}
+	}
+	if count > len(enclosing) {
+		return Region{}, false
+	}
+
+	// Innermost elements start last.
+	sort.Slice(enclosing, func(i, j int) bool { return enclosing[i].openStart > enclosing[j].openStart })
+	el := enclosing[count-1]
+	if inner {
+		return Region{Start: e.positionOf(el.openEnd + 1), End: e.positionOf(el.closeStart - 1)}, true
+	}
+	return Region{Start: e.positionOf(el.openStart), End: e.positionOf(el.closeEnd)}, true
+}
@@ -0,0 +1,363 @@
+package editor
+
+import (
+	"testing"
+)
+
+func TestSelectTextObject(t *testing.T) {
+	tests := []struct {
+		name           string
+		content        []string
+		cursorX        int
+		cursorY        int
+		obj            byte
+		inner          bool
+		count          int
+		expectedOK     bool
+		expectedText   string
+		expectLinewise bool
+	}{
+		{
+			name:         "iw selects word",
+			content:      []string{"foo bar baz"},
+			cursorX:      5,
+			obj:          'w',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "bar",
+		},
+		{
+			name:         "aw includes trailing space",
+			content:      []string{"foo bar baz"},
+			cursorX:      5,
+			obj:          'w',
+			expectedOK:   true,
+			expectedText: "bar ",
+		},
+		{
+			name:         "aw at end of line includes leading space",
+			content:      []string{"foo bar"},
+			cursorX:      5,
+			obj:          'w',
+			expectedOK:   true,
+			expectedText: " bar",
+		},
+		{
+			name:         "2aw selects two words",
+			content:      []string{"foo bar baz"},
+			cursorX:      0,
+			obj:          'w',
+			count:        2,
+			expectedOK:   true,
+			expectedText: "foo bar ",
+		},
+		{
+			name:         "iw stops at punctuation",
+			content:      []string{"foo.bar"},
+			cursorX:      1,
+			obj:          'w',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "foo",
+		},
+		{
+			name:         "iW spans punctuation",
+			content:      []string{"x foo.bar y"},
+			cursorX:      3,
+			obj:          'W',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "foo.bar",
+		},
+		{
+			name:         "i\" selects inside quotes",
+			content:      []string{`say "hello world" now`},
+			cursorX:      8,
+			obj:          '"',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "hello world",
+		},
+		{
+			name:         "a\" includes quotes and trailing space",
+			content:      []string{`say "hello" now`},
+			cursorX:      6,
+			obj:          '"',
+			expectedOK:   true,
+			expectedText: `"hello" `,
+		},
+		{
+			name:         "i' searches forward from before the quotes",
+			content:      []string{`x = 'abc'`},
+			cursorX:      0,
+			obj:          '\'',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "abc",
+		},
+		{
+			name:         "i\" skips escaped quotes",
+			content:      []string{`"a\"b"`},
+			cursorX:      2,
+			obj:          '"',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: `a\"b`,
+		},
+		{
+			name:       "i\" without quotes fails",
+			content:    []string{"no quotes"},
+			obj:        '"',
+			inner:      true,
+			expectedOK: false,
+		},
+		{
+			name:         "i( selects inside parens",
+			content:      []string{"f(a, b)"},
+			cursorX:      3,
+			obj:          '(',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "a, b",
+		},
+		{
+			name:         "a( on the closing paren",
+			content:      []string{"f(a, b)"},
+			cursorX:      6,
+			obj:          ')',
+			expectedOK:   true,
+			expectedText: "(a, b)",
+		},
+		{
+			name:         "i( handles nesting",
+			content:      []string{"f(g(x), y)"},
+			cursorX:      8,
+			obj:          'b',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "g(x), y",
+		},
+		{
+			name:         "2i( selects the enclosing pair",
+			content:      []string{"f(g(x), y)"},
+			cursorX:      4,
+			obj:          '(',
+			inner:        true,
+			count:        2,
+			expectedOK:   true,
+			expectedText: "g(x), y",
+		},
+		{
+			name:         "a[ spans lines",
+			content:      []string{"x = [1,", "  2]"},
+			cursorX:      1,
+			cursorY:      1,
+			obj:          '[',
+			expectedOK:   true,
+			expectedText: "[1,\n  2]",
+		},
+		{
+			name:           "i{ on a block selects inner lines",
+			content:        []string{"if x {", "\ta()", "\tb()", "}"},
+			cursorX:        1,
+			cursorY:        1,
+			obj:            '{',
+			inner:          true,
+			expectedOK:     true,
+			expectedText:   "\ta()\n\tb()\n",
+			expectLinewise: true,
+		},
+		{
+			name:         "i< selects inside angle brackets",
+			content:      []string{"List<String>"},
+			cursorX:      6,
+			obj:          '<',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "String",
+		},
+		{
+			name:       "i( outside parens fails",
+			content:    []string{"f(a) x"},
+			cursorX:    5,
+			obj:        '(',
+			inner:      true,
+			expectedOK: false,
+		},
+		{
+			name:         "it selects tag contents",
+			content:      []string{"<div><b>bold</b> text</div>"},
+			cursorX:      9,
+			obj:          't',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "bold",
+		},
+		{
+			name:         "2at selects enclosing element",
+			content:      []string{"<div><b>bold</b> text</div>"},
+			cursorX:      9,
+			obj:          't',
+			count:        2,
+			expectedOK:   true,
+			expectedText: "<div><b>bold</b> text</div>",
+		},
+		{
+			name:         "it spans lines and skips self-closing tags",
+			content:      []string{"<p>", "a<br/>b", "</p>"},
+			cursorX:      0,
+			cursorY:      1,
+			obj:          't',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "\na<br/>b\n",
+		},
+		{
+			name:         "is selects sentence",
+			content:      []string{"One two. Three four! Five."},
+			cursorX:      10,
+			obj:          's',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "Three four!",
+		},
+		{
+			name:         "as includes trailing space",
+			content:      []string{"One two. Three four! Five."},
+			cursorX:      1,
+			obj:          's',
+			expectedOK:   true,
+			expectedText: "One two. ",
+		},
+		{
+			name:         "is spans lines within a paragraph",
+			content:      []string{"First line", "continues. Next."},
+			cursorX:      0,
+			cursorY:      1,
+			obj:          's',
+			inner:        true,
+			expectedOK:   true,
+			expectedText: "First line\ncontinues.",
+		},
+		{
+			name:           "ip selects paragraph lines",
+			content:        []string{"a", "b", "", "c"},
+			cursorY:        1,
+			obj:            'p',
+			inner:          true,
+			expectedOK:     true,
+			expectedText:   "a\nb\n",
+			expectLinewise: true,
+		},
+		{
+			name:           "ap includes following blank lines",
+			content:        []string{"a", "b", "", "", "c"},
+			cursorY:        0,
+			obj:            'p',
+			expectedOK:     true,
+			expectedText:   "a\nb\n\n\n",
+			expectLinewise: true,
+		},
+		{
+			name:       "unknown object fails",
+			content:    []string{"abc"},
+			obj:        'z',
+			inner:      true,
+			expectedOK: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = tt.content
+			ed.CursorX = tt.cursorX
+			ed.CursorY = tt.cursorY
+
+			r, ok := ed.SelectTextObject(tt.obj, tt.inner, tt.count)
+
+			if ok != tt.expectedOK {
+				t.Fatalf("Expected ok %t, got %t", tt.expectedOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if r.Linewise != tt.expectLinewise {
+				t.Errorf("Expected Linewise %t, got %t", tt.expectLinewise, r.Linewise)
+			}
+			if text := ed.RegionText(r); text != tt.expectedText {
+				t.Errorf("Expected text %q, got %q", tt.expectedText, text)
+			}
+		})
+	}
+}
+
+func TestDeleteRegion(t *testing.T) {
+	tests := []struct {
+		name            string
+		content         []string
+		region          Region
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{
+			name:            "Delete within a line",
+			content:         []string{"foo bar baz"},
+			region:          Region{Start: Position{0, 4}, End: Position{0, 7}},
+			expectedContent: []string{"foo baz"},
+			expectedCursorX: 4,
+		},
+		{
+			name:            "Delete across lines",
+			content:         []string{"ab", "cd", "ef"},
+			region:          Region{Start: Position{0, 1}, End: Position{1, 0}},
+			expectedContent: []string{"ad", "ef"},
+			expectedCursorX: 1,
+		},
+		{
+			name:            "Delete lines",
+			content:         []string{"a", "  b", "c"},
+			region:          Region{Start: Position{0, 0}, End: Position{0, 0}, Linewise: true},
+			expectedContent: []string{"  b", "c"},
+			expectedCursorX: 2,
+		},
+		{
+			name:            "Delete all lines leaves one empty line",
+			content:         []string{"a", "b"},
+			region:          Region{Start: Position{0, 0}, End: Position{1, 0}, Linewise: true},
+			expectedContent: []string{""},
+		},
+		{
+			name:            "Empty region changes nothing",
+			content:         []string{"()"},
+			region:          Region{Start: Position{0, 1}, End: Position{0, 0}},
+			expectedContent: []string{"()"},
+			expectedCursorX: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := NewEditor(80, 24)
+			ed.EditorContent = tt.content
+
+			ed.DeleteRegion(tt.region)
+
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d", len(tt.expectedContent), len(ed.EditorContent))
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
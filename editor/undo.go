@@ -0,0 +1,139 @@
+package editor
+
+import (
+	"errors"
+	"slices"
+)
+
+// UndoState is a snapshot of the buffer that undo or redo returns to.
+type UndoState struct {
+	Content []string
+	Cursor  Position
+}
+
+var (
+	errOldestChange = errors.New("Already at oldest change")
+	errNewestChange = errors.New("Already at newest change")
+)
+
+// snapshot returns the current buffer and cursor.
+func (e *Editor) snapshot() UndoState {
+	return UndoState{
+		Content: slices.Clone(e.EditorContent),
+		Cursor:  Position{Line: e.CursorY, Col: e.CursorX},
+	}
+}
+
+// BeginUndoStep starts an undo step for a command, so that all the changes
+// the command makes can be undone together. The buffer is only saved by the
+// first change, as most commands change nothing.
+func (e *Editor) BeginUndoStep() {
+	e.UndoBase = UndoState{Cursor: Position{Line: e.CursorY, Col: e.CursorX}}
+	e.UndoTick = e.ChangeTick
+	e.UndoStepOpen = true
+}
+
+// beginChange is called before every change to the buffer. It returns an
+// error if the buffer may not be changed, and otherwise saves the buffer
+// for undo if this is the first change of the undo step.
+func (e *Editor) beginChange() error {
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	if e.UndoStepOpen && e.UndoBase.Content == nil {
+		e.UndoBase.Content = slices.Clone(e.EditorContent)
+	}
+	return nil
+}
+
+// EndUndoStep ends the undo step begun by BeginUndoStep. If the buffer was
+// changed, its earlier state goes on the undo stack, oldest states beyond
+// the undolevels option are dropped, and the redo stack is cleared.
+func (e *Editor) EndUndoStep() {
+	if !e.UndoStepOpen {
+		return
+	}
+	if e.ChangeTick != e.UndoTick && e.UndoBase.Content != nil {
+		e.UndoStack = append(e.UndoStack, e.UndoBase)
+		if levels := e.IntOption("undolevels"); len(e.UndoStack) > levels {
+			e.UndoStack = slices.Delete(e.UndoStack, 0, len(e.UndoStack)-max(levels, 0))
+		}
+		e.RedoStack = nil
+	}
+	e.UndoBase, e.UndoStepOpen = UndoState{}, false
+}
+
+// ClearUndo forgets all undo and redo history, as when a new file is loaded.
+func (e *Editor) ClearUndo() {
+	e.UndoStack, e.RedoStack = nil, nil
+	e.UndoBase, e.UndoStepOpen = UndoState{}, false
+}
+
+// Undo reverts the last count undo steps.
+func (e *Editor) Undo(count int) error {
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	if len(e.UndoStack) == 0 {
+		return errOldestChange
+	}
+	for n := 0; n < max(count, 1) && len(e.UndoStack) > 0; n++ {
+		state := e.UndoStack[len(e.UndoStack)-1]
+		e.UndoStack = e.UndoStack[:len(e.UndoStack)-1]
+		e.RedoStack = append(e.RedoStack, e.snapshot())
+		e.restoreUndoState(state)
+	}
+	return nil
+}
+
+// Redo reapplies the last count undo steps that were undone.
+func (e *Editor) Redo(count int) error {
+	if err := e.CheckModifiable(); err != nil {
+		return err
+	}
+	if len(e.RedoStack) == 0 {
+		return errNewestChange
+	}
+	for n := 0; n < max(count, 1) && len(e.RedoStack) > 0; n++ {
+		state := e.RedoStack[len(e.RedoStack)-1]
+		e.RedoStack = e.RedoStack[:len(e.RedoStack)-1]
+		e.UndoStack = append(e.UndoStack, e.snapshot())
+		e.restoreUndoState(state)
+	}
+	return nil
+}
+
+// restoreUndoState replaces the buffer with state and puts the cursor on the
+// first line that differs, at the remembered column if the remembered cursor
+// was on that line. Marks below the lines that differ move with their text.
+// The step in progress, if any, is abandoned so that the undo is not itself
+// recorded as a change.
+func (e *Editor) restoreUndoState(state UndoState) {
+	old := e.EditorContent
+	first := 0
+	for first < len(state.Content) && first < len(old) && state.Content[first] == old[first] {
+		first++
+	}
+	oldEnd, newEnd := len(old), len(state.Content)
+	for oldEnd > first && newEnd > first && old[oldEnd-1] == state.Content[newEnd-1] {
+		oldEnd--
+		newEnd--
+	}
+	change := LineChange{Line: first, Old: oldEnd - first, New: newEnd - first}
+	first = min(first, len(state.Content)-1)
+
+	e.EditorContent = slices.Clone(state.Content)
+	if delta := newEnd - oldEnd; delta > 0 {
+		e.adjustMarks(oldEnd, delta)
+	} else {
+		e.adjustMarks(newEnd, delta)
+	}
+	e.CursorY = first
+	if state.Cursor.Line == first {
+		e.CursorX = min(state.Cursor.Col, len(e.EditorContent[first]))
+	} else {
+		e.CursorX = FirstNonBlank(e.EditorContent[first])
+	}
+	e.markChanged(change)
+	e.UndoBase, e.UndoStepOpen = UndoState{}, false
+}
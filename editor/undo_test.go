@@ -0,0 +1,115 @@
+package editor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUndoRedo(t *testing.T) {
+	e := NewEditor(80, 24)
+	e.EditorContent = []string{"one", "two"}
+
+	// A step with no change records nothing.
+	e.BeginUndoStep()
+	e.CursorY = 1
+	if e.UndoBase.Content != nil {
+		t.Errorf("Expected the buffer saved only when it changes")
+	}
+	e.EndUndoStep()
+	if len(e.UndoStack) != 0 {
+		t.Fatalf("Expected no undo step for a motion, got %d", len(e.UndoStack))
+	}
+
+	// Several changes in one step are undone together.
+	e.CursorX = 3
+	e.BeginUndoStep()
+	e.InsertChar('!')
+	e.InsertNewline()
+	e.InsertChar('x')
+	e.EndUndoStep()
+	e.BeginUndoStep()
+	e.ReplaceLines(0, 0, []string{"ONE"})
+	e.EndUndoStep()
+
+	steps := []struct {
+		name    string
+		fn      func(count int) error
+		count   int
+		want    string
+		cursorY int
+		cursorX int
+	}{
+		{"undo", e.Undo, 1, "one|two!|x", 0, 0},
+		{"undo again", e.Undo, 1, "one|two", 1, 3},
+		{"redo", e.Redo, 1, "one|two!|x", 1, 0},
+		{"redo twice", e.Redo, 2, "ONE|two!|x", 0, 0},
+		{"undo with count", e.Undo, 5, "one|two", 1, 3},
+	}
+	for _, s := range steps {
+		if err := s.fn(s.count); err != nil {
+			t.Fatalf("%s: unexpected error %v", s.name, err)
+		}
+		if got := strings.Join(e.EditorContent, "|"); got != s.want {
+			t.Errorf("%s: expected %q, got %q", s.name, s.want, got)
+		}
+		if e.CursorY != s.cursorY || e.CursorX != s.cursorX {
+			t.Errorf("%s: expected cursor %d,%d, got %d,%d", s.name, s.cursorY, s.cursorX, e.CursorY, e.CursorX)
+		}
+	}
+	if err := e.Undo(1); err == nil {
+		t.Error("Expected error when at oldest change")
+	}
+
+	// A new change clears the redo stack.
+	e.BeginUndoStep()
+	e.InsertChar('y')
+	e.EndUndoStep()
+	if err := e.Redo(1); err == nil {
+		t.Error("Expected error when at newest change")
+	}
+}
+
+func TestUndoLevels(t *testing.T) {
+	e := NewEditor(80, 24)
+	if err := e.SetOption("undolevels", 2); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		e.BeginUndoStep()
+		e.InsertChar('a')
+		e.EndUndoStep()
+	}
+	if len(e.UndoStack) != 2 {
+		t.Errorf("Expected 2 undo steps, got %d", len(e.UndoStack))
+	}
+	e.LoadFile([]byte("new\n"))
+	if len(e.UndoStack) != 0 {
+		t.Errorf("Expected loading a file to clear undo, got %d steps", len(e.UndoStack))
+	}
+}
+
+func TestUndoMarks(t *testing.T) {
+	e := NewEditor(80, 24)
+	e.EditorContent = []string{"one", "two", "three"}
+	e.SetMark('a', Position{Line: 2, Col: 1})
+	e.PushJump()
+	e.CursorY = 2
+	e.PushJump()
+
+	e.BeginUndoStep()
+	e.InsertLines(1, []string{"x", "y"})
+	e.EndUndoStep()
+	if p, _ := e.MarkPosition('a'); p.Line != 4 {
+		t.Fatalf("Expected mark a on line 4 after the change, got %d", p.Line)
+	}
+
+	// The marks go back with the text when the change is undone and redone.
+	_ = e.Undo(1)
+	if p, _ := e.MarkPosition('a'); p.Line != 2 || p.Col != 1 || e.JumpList[1].Line != 2 {
+		t.Errorf("Expected mark a and the jump back on line 2, got %v and %v", p, e.JumpList)
+	}
+	_ = e.Redo(1)
+	if p, _ := e.MarkPosition('a'); p.Line != 4 || e.JumpList[1].Line != 4 {
+		t.Errorf("Expected mark a and the jump on line 4 again, got %v and %v", p, e.JumpList)
+	}
+}
@@ -3,6 +3,7 @@ module goedit
 go 1.24.2
 
 require (
+	github.com/yuin/gopher-lua v1.1.2
 	golang.org/x/term v0.31.0
 	golang.org/x/sys v0.32.0
 )
@@ -0,0 +1,94 @@
+package input
+
+import "goedit/editor"
+
+// charAt returns the character at column x of the cursor line, or 0.
+func charAt(e *editor.Editor, x int) byte {
+	line := currentLine(e)
+	if x < 0 || x >= len(line) {
+		return 0
+	}
+	return line[x]
+}
+
+// isWordChar reports whether c is part of a keyword.
+func isWordChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// pairAround returns the auto-pair whose opening character is just before
+// the cursor and whose closing character is at the cursor.
+func pairAround(e *editor.Editor) ([2]byte, bool) {
+	before, after := charAt(e, e.CursorX-1), charAt(e, e.CursorX)
+	for _, p := range e.AutoPairs() {
+		if p[0] == before && p[1] == after {
+			return p, true
+		}
+	}
+	return [2]byte{}, false
+}
+
+// insertPaired handles a typed character that is part of an auto-pair: an
+// opening character also inserts its closing one, and a closing character
+// typed before the same character moves over it. It returns false if key
+// should be inserted normally, as it is when the buffer may not be changed.
+func insertPaired(e *editor.Editor, key byte) bool {
+	if e.CheckModifiable() != nil || e.InStringOrComment(editor.Position{Line: e.CursorY, Col: e.CursorX}) {
+		return false
+	}
+	next := charAt(e, e.CursorX)
+	for _, p := range e.AutoPairs() {
+		switch {
+		case key == p[1] && next == p[1]:
+			e.CursorX++ // Type over the closing character
+			return true
+		case key == p[0]:
+			if isWordChar(next) || (p[0] == p[1] && isWordChar(charAt(e, e.CursorX-1))) {
+				return false // Before a word, or a quote ending one such as "don't"
+			}
+			e.InsertChar(p[0])
+			e.InsertChar(p[1])
+			e.CursorX--
+			return true
+		}
+	}
+	return false
+}
+
+// deletePair deletes both characters of an empty auto-pair around the
+// cursor, for Backspace. It returns false if there is no such pair or the
+// buffer may not be changed.
+func deletePair(e *editor.Editor) bool {
+	if e.CheckModifiable() != nil {
+		return false
+	}
+	if _, ok := pairAround(e); !ok {
+		return false
+	}
+	e.CursorX++
+	e.DeleteChar()
+	e.DeleteChar()
+	return true
+}
+
+// openPairBlock splits an empty bracket pair around the cursor over three
+// lines for Enter, leaving the cursor on an indented middle line. It returns
+// false if the cursor is not between such a pair or the buffer may not be
+// changed.
+func openPairBlock(e *editor.Editor) bool {
+	if e.CheckModifiable() != nil {
+		return false
+	}
+	p, ok := pairAround(e)
+	if !ok || p[0] == p[1] {
+		return false
+	}
+	indent := e.IndentWidth(currentLine(e))
+	e.InsertNewline()
+	e.SetIndent(e.CursorY, indent)
+	e.CursorY--
+	e.CursorX = len(currentLine(e))
+	e.InsertNewline()
+	e.SetIndent(e.CursorY, indent+e.ShiftWidth())
+	return true
+}
@@ -0,0 +1,92 @@
+package input
+
+import (
+	"goedit/editor"
+	"goedit/terminal"
+)
+
+// Control keys used for Insert mode completion.
+const (
+	keyCtrlE byte = 5
+	keyCtrlF byte = 6
+	keyCtrlL byte = 12
+	keyCtrlN byte = 14
+	keyCtrlO byte = 15
+	keyCtrlP byte = 16
+	keyCtrlX byte = 24
+)
+
+// processCompletionKey handles the Insert mode keys that start, navigate or
+// end a completion. It returns false if key should be handled as usual.
+func processCompletionKey(e *editor.Editor, key byte) bool {
+	if e.CtrlXPending {
+		e.CtrlXPending = false
+		switch key {
+		case keyCtrlF:
+			startCompletion(e, editor.CompleteFile, true)
+			return true
+		case keyCtrlL:
+			startCompletion(e, editor.CompleteLine, true)
+			return true
+		case keyCtrlO:
+			startCompletion(e, editor.CompleteOmni, true)
+			return true
+		case keyCtrlN, keyCtrlP:
+			startCompletion(e, editor.CompleteWord, key == keyCtrlN)
+			return true
+		}
+		e.SetStatusMessage("") // Another key leaves Ctrl-X mode and is handled as usual
+	}
+
+	if c := e.Completion; c != nil {
+		switch key {
+		case keyCtrlN, 9, terminal.KeyArrowDown:
+			e.SelectCompletion(c.Selected + 1)
+			return true
+		case keyCtrlP, terminal.KeyArrowUp:
+			e.SelectCompletion(c.Selected - 1)
+			return true
+		case keyCtrlF, keyCtrlL, keyCtrlO: // Repeating the Ctrl-X key moves on too
+			if (key == keyCtrlF && c.Kind == editor.CompleteFile) || (key == keyCtrlL && c.Kind == editor.CompleteLine) || (key == keyCtrlO && c.Kind == editor.CompleteOmni) {
+				e.SelectCompletion(c.Selected + 1)
+				return true
+			}
+		case 13: // Accept the selected item
+			e.Completion = nil
+			e.SetStatusMessage("")
+			return true
+		case keyCtrlE: // Cancel, restoring the original text
+			e.SelectCompletion(-1)
+			e.Completion = nil
+			e.SetStatusMessage("")
+			return true
+		}
+		e.Completion = nil // Any other key accepts the item and is handled as usual
+		e.SetStatusMessage("")
+	}
+
+	switch key {
+	case keyCtrlN, keyCtrlP:
+		startCompletion(e, editor.CompleteWord, key == keyCtrlN)
+	case keyCtrlX:
+		e.CtrlXPending = true
+		e.SetStatusMessage("-- ^X mode (^F^L^O^N^P)")
+	default:
+		return false
+	}
+	return true
+}
+
+// startCompletion begins a completion and selects its first item, or its
+// last if forward is false.
+func startCompletion(e *editor.Editor, kind editor.CompletionKind, forward bool) {
+	if !e.StartCompletion(kind) {
+		e.KeyError = true
+		return
+	}
+	if forward {
+		e.SelectCompletion(0)
+	} else {
+		e.SelectCompletion(len(e.Completion.Items) - 1)
+	}
+}
@@ -0,0 +1,239 @@
+package input
+
+import (
+	"time"
+
+	"goedit/editor"
+	"goedit/terminal"
+)
+
+// currentLine returns the text of the cursor line, or "" below the content.
+func currentLine(e *editor.Editor) string {
+	if e.CursorY >= len(e.EditorContent) {
+		return ""
+	}
+	return e.EditorContent[e.CursorY]
+}
+
+// startInsert enters Insert mode for i, a, A, I, o or O. With a count the
+// text typed before Esc is repeated that many times.
+func startInsert(e *editor.Editor, key byte, count int) {
+	line := currentLine(e)
+	switch key {
+	case 'a':
+		if e.CursorX < len(line) {
+			e.CursorX++
+		}
+	case 'A':
+		e.CursorX = len(line)
+	case 'I':
+		e.CursorX = editor.FirstNonBlank(line)
+	case 'o':
+		e.InsertLines(e.CursorY+1, []string{""})
+		e.CursorY++
+		e.CursorX = 0
+		autoIndent(e, e.CursorY-1)
+	case 'O':
+		e.InsertLines(e.CursorY, []string{""})
+		e.CursorX = 0
+		autoIndent(e, e.CursorY+1)
+	}
+	e.CurrentMode = editor.ModeInsert
+	e.InsertCount = count
+	e.InsertStart = editor.Position{Line: e.CursorY, Col: e.CursorX}
+	e.InsertOpensLine = key == 'o' || key == 'O'
+}
+
+// finishInsert is called when Insert mode is left. If it was entered with a
+// count, the text typed since then is inserted again count-1 times, on new
+// lines if it was entered with o or O.
+func finishInsert(e *editor.Editor) {
+	count := e.InsertCount
+	e.InsertCount = 0
+	defer func() {
+		e.SetMark(editor.MarkChangeStart, e.InsertStart)
+		e.SetMark(editor.MarkChangeEnd, editor.Position{Line: e.CursorY, Col: max(e.CursorX-1, 0)})
+	}()
+	if count < 2 {
+		return
+	}
+
+	end := editor.Position{Line: e.CursorY, Col: e.CursorX}
+	typed := e.RegionText(editor.Region{Start: e.InsertStart, End: editor.Position{Line: end.Line, Col: end.Col - 1}})
+	if typed == "" {
+		return
+	}
+	if e.InsertOpensLine {
+		end.Col = len(e.EditorContent[end.Line])
+		typed = "\n" + e.EditorContent[e.InsertStart.Line][:e.InsertStart.Col] + typed // Keep any auto-indent
+	}
+	for n := 1; n < count; n++ {
+		end = e.InsertText(end, typed)
+	}
+	e.CursorY, e.CursorX = end.Line, end.Col
+}
+
+// autoIndent indents a line just opened in Insert mode at the cursor. With
+// autoindent it gets the indent of line from, the line it was opened next
+// to; with smartindent the indent follows the indent rules.
+func autoIndent(e *editor.Editor, from int) {
+	y := e.CursorY
+	switch {
+	case e.BoolOption("smartindent"):
+		e.SetIndent(y, e.ComputeIndent(y))
+	case e.BoolOption("autoindent") && from >= 0 && from < len(e.EditorContent):
+		e.SetIndent(y, e.IndentWidth(e.EditorContent[from]))
+	}
+}
+
+// smartDedent re-indents the cursor line when a closing bracket is typed as
+// its first non-blank character, with smartindent set.
+func smartDedent(e *editor.Editor, key byte) {
+	if !e.BoolOption("smartindent") || (key != '}' && key != ')' && key != ']') {
+		return
+	}
+	if editor.FirstNonBlank(currentLine(e)) == e.CursorX-1 {
+		e.SetIndent(e.CursorY, e.ComputeIndent(e.CursorY))
+	}
+}
+
+// runEditCommand runs a single-key Normal mode edit: x, X, s, D, C, S, J or ~.
+// Deleted text goes to the unnamed register.
+func runEditCommand(e *editor.Editor, key byte, count int) keyResult {
+	count = max(count, 1)
+	line := currentLine(e)
+	y, x := e.CursorY, e.CursorX
+
+	switch key {
+	case 'x', 's':
+		if x >= len(line) {
+			if key == 's' {
+				startInsert(e, 'i', 0)
+				return keysDone
+			}
+			return keysInvalid
+		}
+		op := byte('d')
+		if key == 's' {
+			op = 'c'
+		}
+		applyOperator(e, op, charRegion(y, x, min(x+count, len(line))-1))
+	case 'X':
+		if x == 0 {
+			return keysInvalid
+		}
+		applyOperator(e, 'd', charRegion(y, max(x-count, 0), min(x, len(line))-1))
+	case 'D', 'C':
+		last := min(y+count-1, len(e.EditorContent)-1)
+		r := editor.Region{
+			Start: editor.Position{Line: y, Col: x},
+			End:   editor.Position{Line: last, Col: len(e.EditorContent[last]) - 1},
+		}
+		switch {
+		case key == 'C':
+			applyOperator(e, 'c', r)
+		case !r.IsEmpty():
+			applyOperator(e, 'd', r)
+		}
+	case 'S':
+		applyOperator(e, 'c', lineRegion(e, count))
+	case 'J':
+		if !e.JoinLines(count) {
+			return keysInvalid
+		}
+	case '~':
+		if !e.ToggleCase(count) {
+			return keysInvalid
+		}
+	}
+	return keysDone
+}
+
+// charRegion returns the characterwise region of columns from through to on
+// line y.
+func charRegion(y, from, to int) editor.Region {
+	return editor.Region{Start: editor.Position{Line: y, Col: from}, End: editor.Position{Line: y, Col: to}}
+}
+
+// replaceChars implements r{char}: count characters are replaced with char,
+// or with a single line break if char is Enter.
+func replaceChars(e *editor.Editor, char byte, count int) keyResult {
+	switch {
+	case char == terminal.KeyEsc:
+		return keysDone
+	case char == 13:
+		count = max(count, 1)
+		if e.CursorX+count > len(currentLine(e)) {
+			return keysInvalid
+		}
+		e.DeleteRegion(charRegion(e.CursorY, e.CursorX, e.CursorX+count-1))
+		e.InsertNewline()
+		return keysDone
+	case char < 32 || char > 126:
+		return keysInvalid
+	}
+	if !e.ReplaceChars(char, count) {
+		return keysInvalid
+	}
+	return keysDone
+}
+
+// startReplace enters Replace mode for R. With a count the text typed
+// before Esc is repeated that many times, as with startInsert.
+func startReplace(e *editor.Editor, count int) {
+	e.CurrentMode = editor.ModeReplace
+	e.ReplacedChars = nil
+	e.InsertCount = count
+	e.InsertStart = editor.Position{Line: e.CursorY, Col: e.CursorX}
+}
+
+// finishReplace is called when Replace mode is left. If it was entered with
+// a count, the text typed since then replaces the text after it count-1
+// more times.
+func finishReplace(e *editor.Editor) {
+	count := e.InsertCount
+	e.InsertCount = 0
+	if count < 2 {
+		return
+	}
+	typed := e.RegionText(editor.Region{Start: e.InsertStart, End: editor.Position{Line: e.CursorY, Col: e.CursorX - 1}})
+	for n := 1; n < count; n++ {
+		for i := 0; i < len(typed); i++ {
+			if typed[i] == '\n' {
+				e.InsertNewline()
+			} else {
+				e.OverwriteChar(typed[i])
+			}
+		}
+	}
+}
+
+// processReplaceModeInput handles input when in Replace mode, where typed
+// characters overwrite the text under the cursor. Backspace puts back what
+// was overwritten.
+func processReplaceModeInput(e *editor.Editor, key byte) {
+	switch key {
+	case terminal.KeyEsc:
+		finishReplace(e)
+		e.CurrentMode = editor.ModeNormal
+		e.StatusMessageTime = time.Time{}
+		e.ReplacedChars = nil
+	case 13:
+		e.InsertNewline()
+		e.ReplacedChars = nil
+	case 127, 8:
+		if n := len(e.ReplacedChars); n > 0 {
+			e.RestoreChar(e.ReplacedChars[n-1])
+			e.ReplacedChars = e.ReplacedChars[:n-1]
+		} else if e.CursorX > 0 {
+			e.CursorX--
+		}
+	case terminal.KeyArrowUp, terminal.KeyArrowDown, terminal.KeyArrowLeft, terminal.KeyArrowRight:
+		processInsertModeInput(e, key) // Moves the cursor the same way
+		e.ReplacedChars = nil
+	default:
+		if key >= 32 && key < 127 {
+			e.ReplacedChars = append(e.ReplacedChars, e.OverwriteChar(key))
+		}
+	}
+}
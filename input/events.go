@@ -0,0 +1,61 @@
+package input
+
+import (
+	"time"
+
+	"goedit/editor"
+)
+
+// isInsertMode reports whether text is typed into the buffer in mode.
+func isInsertMode(mode editor.Mode) bool {
+	return mode == editor.ModeInsert || mode == editor.ModeReplace
+}
+
+// fireModeChanged fires the events for a change from mode old to the
+// current mode.
+func fireModeChanged(e *editor.Editor, old editor.Mode) {
+	switch {
+	case isInsertMode(e.CurrentMode) && !isInsertMode(old):
+		e.Fire(editor.EventInsertEnter)
+	case isInsertMode(old) && !isInsertMode(e.CurrentMode):
+		e.Fire(editor.EventInsertLeave)
+	}
+	e.FireEvent(editor.Event{
+		Name:  editor.EventModeChanged,
+		File:  e.Filename,
+		Match: modeLetters[old] + ":" + modeLetters[e.CurrentMode],
+	})
+}
+
+// checkCursorHold fires CursorHold, or CursorHoldI in Insert mode, once no
+// key has been typed for updatetime milliseconds. It fires once until the
+// next key, and not while a command or mapping is incomplete. Its changes
+// are undone together.
+func checkCursorHold(e *editor.Editor) {
+	if e.LastKeyTime.IsZero() {
+		e.LastKeyTime = time.Now()
+	}
+	if e.CursorHeld || len(e.MappedKeys) > 0 || len(e.PendingKeys) > 0 {
+		return
+	}
+	if time.Since(e.LastKeyTime) < time.Duration(e.IntOption("updatetime"))*time.Millisecond {
+		return
+	}
+	event := editor.EventCursorHold
+	switch e.CurrentMode {
+	case editor.ModeNormal:
+	case editor.ModeInsert:
+		event = editor.EventCursorHoldI
+	default:
+		return
+	}
+	e.CursorHeld = true
+	top := betweenCommands(e)
+	if top {
+		e.BeginUndoStep()
+	}
+	e.Fire(event)
+	if top && betweenCommands(e) {
+		e.EndUndoStep()
+	}
+}
@@ -8,8 +8,44 @@ import (
 	"goedit/terminal"
 )
 
+// keyResult reports what became of a pending Normal or Visual mode key sequence.
+type keyResult int
+
+const (
+	keysPending keyResult = iota // More keys are needed to complete the command
+	keysDone                     // The command ran
+	keysInvalid                  // The keys do not form a command
+)
+
 // ProcessInput routes the key press to the appropriate mode handler.
 func ProcessInput(e *editor.Editor, key byte) {
+	if key == terminal.KeyNull {
+		checkMappingTimeout(e)
+		checkCursorHold(e)
+		return // Read timed out without a key press
+	}
+	if e.MacroDepth == 0 {
+		e.LastKeyTime = time.Now()
+		e.CursorHeld = false
+	}
+	if e.Output != nil {
+		e.Output = nil
+		if key == 13 || key == ' ' || key == terminal.KeyEsc {
+			return // The key only dismissed the output
+		}
+	}
+	recordMacroKey(e, key)
+	processMappedKey(e, key)
+}
+
+// dispatchKey routes a key, after any mapping, to the handler of the current
+// mode.
+func dispatchKey(e *editor.Editor, key byte) {
+	recordChangeKey(e, key)
+	if betweenCommands(e) {
+		e.BeginUndoStep()
+	}
+	mode := e.CurrentMode
 	switch e.CurrentMode {
 	case editor.ModeNormal:
 		processNormalModeInput(e, key)
@@ -19,59 +55,267 @@ func ProcessInput(e *editor.Editor, key byte) {
 		cmd.HandleCommandKey(e, key)
 	case editor.ModeFileNamePrompt:
 		processFileNamePrompt(e, key)
+	case editor.ModeVisual:
+		processVisualModeInput(e, key)
+	case editor.ModeReplace:
+		processReplaceModeInput(e, key)
+	}
+	finishChange(e)
+	if e.CurrentMode != mode {
+		fireModeChanged(e, mode)
+	}
+	if betweenCommands(e) {
+		e.EndUndoStep()
 	}
 }
 
-// processNormalModeInput handles input when in Normal mode.
+// processNormalModeInput handles input when in Normal mode. Keys are collected
+// in e.PendingKeys until they form a complete command.
 func processNormalModeInput(e *editor.Editor, key byte) {
-	switch key {
-	case 'q': // Do nothing (require :q)
-	case 'i':
-		e.CurrentMode = editor.ModeInsert
-	case 'h', terminal.KeyArrowLeft:
-		if e.CursorX > 0 {
-			e.CursorX--
+	e.PendingKeys = append(e.PendingKeys, key)
+	switch runNormalCommand(e, e.PendingKeys) {
+	case keysPending:
+		return
+	case keysInvalid:
+		e.KeyError = true
+	}
+	e.PendingKeys = nil
+}
+
+// runNormalCommand executes the Normal mode command spelled by keys, an
+// optional count followed by a command.
+func runNormalCommand(e *editor.Editor, keys []byte) keyResult {
+	count, rest := parseCount(keys)
+	if len(rest) == 0 {
+		return keysPending
+	}
+
+	switch rest[0] {
+	case terminal.KeyEsc: // Cancel any pending command
+	case 'q': // Macro recording (quitting requires :q)
+		if e.RecordingRegister != 0 {
+			e.StopRecording()
+			break
 		}
-	case 'j', terminal.KeyArrowDown:
-		if e.CursorY < len(e.EditorContent)-1 {
-			e.CursorY++
-			if e.CursorY >= e.RowOffset+e.TermHeight-1 {
-				e.RowOffset++
-			}
-			e.EnsureCursorBounds()
+		if len(rest) < 2 {
+			return keysPending
 		}
-	case 'k', terminal.KeyArrowUp:
-		if e.CursorY > 0 {
-			e.CursorY--
-			if e.CursorY < e.RowOffset {
-				e.RowOffset--
-			}
-			e.EnsureCursorBounds()
+		if !isMacroRegister(rest[1]) {
+			return keysInvalid
 		}
-	case 'l', terminal.KeyArrowRight:
-		if e.CursorY < len(e.EditorContent) {
-			lineLen := len(e.EditorContent[e.CursorY])
-			if e.CursorX < lineLen {
-				e.CursorX++
-			}
+		e.StartRecording(rest[1])
+	case '@':
+		if len(rest) < 2 {
+			return keysPending
 		}
+		return runMacro(e, rest[1], count)
+	case 'i', 'a', 'A', 'I', 'o', 'O':
+		startInsert(e, rest[0], count)
+	case 'R':
+		startReplace(e, count)
+	case 'x', 'X', 's', 'D', 'C', 'S', 'J', '~':
+		return runEditCommand(e, rest[0], count)
+	case 'r':
+		if len(rest) < 2 {
+			return keysPending
+		}
+		return replaceChars(e, rest[1], count)
+	case 'h', 'j', 'k', 'l', terminal.KeyArrowLeft, terminal.KeyArrowDown, terminal.KeyArrowUp, terminal.KeyArrowRight:
+		if !moveCursor(e, rest[0], count) {
+			return keysInvalid
+		}
+	case '0': // Not a count, which parseCount has already taken
+		e.CursorX = 0
 	case ':':
-		e.CurrentMode = editor.ModeCommand
-		e.CommandBuffer = ""
+		e.StartCommandLine(editor.ModeCommand, "")
 		e.SetStatusMessage("")
+	case '/', '?':
+		e.StartSearch(rest[0])
+		e.SetStatusMessage("")
+	case 'n', 'N':
+		if err := cmd.SearchNext(e, rest[0] == 'N', count); err != nil {
+			e.SetStatusMessage(err.Error())
+			return keysInvalid
+		}
+	case 'v', 'V':
+		startVisual(e, rest[0] == 'V')
+	case 'd', 'c', 'y', '>', '<', '=', '!':
+		return runOperator(e, rest[0], count, rest[1:])
+	case '.':
+		repeatLastChange(e, count)
+	case 'u':
+		return runUndo(e, e.Undo, count)
+	case 18: // Ctrl-R
+		return runUndo(e, e.Redo, count)
+	case 'm':
+		if len(rest) < 2 {
+			return keysPending
+		}
+		if !editor.IsSettableMark(rest[1]) {
+			return keysInvalid
+		}
+		e.SetMark(rest[1], editor.Position{Line: e.CursorY, Col: e.CursorX})
+	case '\'', '`':
+		if len(rest) < 2 {
+			return keysPending
+		}
+		return jumpToMark(e, rest[1], rest[0] == '\'')
+	case 15: // Ctrl-O
+		if !e.JumpOlder(count) {
+			return keysInvalid
+		}
+	case 9: // Ctrl-I (Tab)
+		if !e.JumpNewer(count) {
+			return keysInvalid
+		}
+	case 'G':
+		return jumpToLine(e, rest, count)
+	case 'g':
+		if len(rest) < 2 {
+			return keysPending
+		}
+		if rest[1] == 'g' {
+			return jumpToLine(e, rest, count)
+		}
+		if rest[1] != 'd' {
+			return keysInvalid
+		}
+		return runExCommand(e, "definition")
+	case 'K':
+		return runExCommand(e, "hover")
+	case 'p', 'P':
+		for n := 0; n < max(count, 1); n++ {
+			if !e.Put(editor.UnnamedRegister, rest[0] == 'p') {
+				break
+			}
+		}
+	default:
+		return keysInvalid
+	}
+	return keysDone
+}
+
+// jumpToMark moves the cursor to a mark, to the first non-blank of its line if
+// linewise (as ' does) or to its exact position (as ` does). The jump is
+// recorded in the jump list.
+func jumpToMark(e *editor.Editor, name byte, linewise bool) keyResult {
+	p, err := e.MarkPosition(name)
+	if err != nil {
+		e.SetStatusMessage(err.Error())
+		return keysInvalid
+	}
+	e.PushJump()
+	e.CursorY, e.CursorX = p.Line, p.Col
+	if linewise {
+		e.CursorX = editor.FirstNonBlank(e.EditorContent[p.Line])
+	}
+	return keysDone
+}
+
+// jumpToLine moves the cursor to the first non-blank of the line that G or
+// gg at the start of keys goes to, recording the jump in the jump list.
+func jumpToLine(e *editor.Editor, keys []byte, count int) keyResult {
+	line, result := lineMotionTarget(e, keys, count)
+	if result != keysDone {
+		return result
+	}
+	e.PushJump()
+	e.CursorY = line
+	e.CursorX = editor.FirstNonBlank(e.EditorContent[line])
+	return keysDone
+}
+
+// runExCommand runs an Ex command for a Normal mode key, showing any error.
+func runExCommand(e *editor.Editor, command string) keyResult {
+	if err := cmd.ExecuteCommand(e, command); err != nil {
+		e.SetStatusMessage(err.Error())
+		return keysInvalid
+	}
+	return keysDone
+}
+
+// runUndo undoes or redoes count steps with fn. Neither is a change for '.'
+// to repeat.
+func runUndo(e *editor.Editor, fn func(count int) error, count int) keyResult {
+	e.Dot.Keys = nil
+	if err := fn(count); err != nil {
+		e.SetStatusMessage(err.Error())
+		return keysInvalid
+	}
+	return keysDone
+}
+
+// parseCount splits a leading count off keys, returning 0 if none was typed.
+func parseCount(keys []byte) (int, []byte) {
+	n, i := 0, 0
+	for i < len(keys) && keys[i] >= '0' && keys[i] <= '9' && (i > 0 || keys[i] != '0') {
+		n = n*10 + int(keys[i]-'0')
+		i++
 	}
+	return n, keys[i:]
+}
+
+// moveCursor moves the cursor count times in the direction of an h, j, k, l
+// or arrow key, scrolling the view to keep it visible. It returns false if
+// the cursor could not move at all.
+func moveCursor(e *editor.Editor, key byte, count int) bool {
+	startX, startY := e.CursorX, e.CursorY
+	for n := 0; n < max(count, 1); n++ {
+		switch key {
+		case 'h', terminal.KeyArrowLeft:
+			if e.CursorX > 0 {
+				e.CursorX--
+			}
+		case 'j', terminal.KeyArrowDown:
+			if e.CursorY < len(e.EditorContent)-1 {
+				e.CursorY++
+				if e.CursorY >= e.RowOffset+e.TermHeight-1 {
+					e.RowOffset++
+				}
+				e.EnsureCursorBounds()
+			}
+		case 'k', terminal.KeyArrowUp:
+			if e.CursorY > 0 {
+				e.CursorY--
+				if e.CursorY < e.RowOffset {
+					e.RowOffset--
+				}
+				e.EnsureCursorBounds()
+			}
+		case 'l', terminal.KeyArrowRight:
+			if e.CursorY < len(e.EditorContent) {
+				lineLen := len(e.EditorContent[e.CursorY])
+				if e.CursorX < lineLen {
+					e.CursorX++
+				}
+			}
+		}
+	}
+	return e.CursorX != startX || e.CursorY != startY
 }
 
 // processInsertModeInput handles input when in Insert mode.
 func processInsertModeInput(e *editor.Editor, key byte) {
+	if processCompletionKey(e, key) {
+		return
+	}
 	switch key {
 	case terminal.KeyEsc:
 		e.CurrentMode = editor.ModeNormal
 		e.StatusMessageTime = time.Time{}
+		finishInsert(e)
+		e.SetMark(editor.MarkInsertExit, editor.Position{Line: e.CursorY, Col: e.CursorX})
 	case 13:
-		e.InsertNewline()
+		if !openPairBlock(e) {
+			e.InsertNewline()
+			autoIndent(e, e.CursorY-1)
+		}
 	case 127, 8:
-		e.DeleteChar()
+		if !deletePair(e) && !e.DeleteSoftTab() {
+			e.DeleteChar()
+		}
+	case 9: // Tab
+		e.InsertTab()
 	case terminal.KeyArrowUp:
 		if e.CursorY > 0 {
 			e.CursorY--
@@ -104,14 +348,19 @@ func processInsertModeInput(e *editor.Editor, key byte) {
 			}
 		}
 	default:
-		if key >= 32 && key < 127 {
+		if key >= 32 && key < 127 && !insertPaired(e, key) {
 			e.InsertChar(key)
+			smartDedent(e, key)
 		}
 	}
 }
 
 // processFileNamePrompt handles input when prompting for a filename to save.
 func processFileNamePrompt(e *editor.Editor, key byte) {
+	if cmd.EditCommandLine(e, key, editor.HistoryInput) {
+		e.SetStatusMessage("Save file as: " + e.CommandBuffer)
+		return
+	}
 	switch key {
 	case terminal.KeyEsc:
 		// Cancel prompt
@@ -124,6 +373,7 @@ func processFileNamePrompt(e *editor.Editor, key byte) {
 			e.SetStatusMessage("Save aborted.")
 			e.CurrentMode = editor.ModeNormal
 		} else {
+			e.AddHistory(editor.HistoryInput, filename)
 			e.Filename = filename
 			e.SetStatusMessage("")
 			// Note: SaveFile might set its own status message ("saved" or "error")
@@ -147,17 +397,5 @@ func processFileNamePrompt(e *editor.Editor, key byte) {
 			e.PromptOriginCommand = "" // Clear origin after handling
 		}
 		e.CommandBuffer = ""
-	case 127, 8:
-		if len(e.CommandBuffer) > 0 {
-			e.CommandBuffer = e.CommandBuffer[:len(e.CommandBuffer)-1]
-			// Update prompt dynamically
-			e.SetStatusMessage("Save file as: " + e.CommandBuffer)
-		}
-	default:
-		if key >= 32 && key <= 126 {
-			e.CommandBuffer += string(key)
-			newPrompt := "Save file as: " + e.CommandBuffer
-			e.SetStatusMessage(newPrompt)
-		}
 	}
 }
@@ -2,9 +2,12 @@ package input
 
 import (
 	"os"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 
+	"goedit/cmd"
 	"goedit/editor"
 	"goedit/terminal"
 )
@@ -521,3 +524,1171 @@ func TestProcessFileNamePrompt(t *testing.T) {
 		})
 	}
 }
+
+// feedKeys sends each byte of keys to ProcessInput.
+func feedKeys(e *editor.Editor, keys string) {
+	for i := 0; i < len(keys); i++ {
+		ProcessInput(e, keys[i])
+	}
+}
+
+func TestOperatorTextObjects(t *testing.T) {
+	tests := []struct {
+		name             string
+		initialContent   []string
+		initialCursorX   int
+		initialCursorY   int
+		keys             string
+		expectedMode     editor.Mode
+		expectedContent  []string
+		expectedCursorX  int
+		expectedCursorY  int
+		expectedRegister string
+	}{
+		{
+			name:             "diw deletes word",
+			initialContent:   []string{"foo bar baz"},
+			initialCursorX:   5,
+			keys:             "diw",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"foo  baz"},
+			expectedCursorX:  4,
+			expectedRegister: "bar",
+		},
+		{
+			name:             "ci\" changes inside quotes",
+			initialContent:   []string{`x := "old"`},
+			initialCursorX:   7,
+			keys:             "ci\"new\x1b",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{`x := "new"`},
+			expectedCursorX:  9,
+			expectedRegister: "old",
+		},
+		{
+			name:             "d2aw with operator count",
+			initialContent:   []string{"one two three"},
+			keys:             "d2aw",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"three"},
+			expectedRegister: "one two ",
+		},
+		{
+			name:             "ya( yanks without changing text",
+			initialContent:   []string{"f(x)"},
+			initialCursorX:   2,
+			keys:             "ya(",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"f(x)"},
+			expectedCursorX:  1,
+			expectedRegister: "(x)",
+		},
+		{
+			name:             "dip deletes paragraph lines",
+			initialContent:   []string{"a", "b", "", "c"},
+			keys:             "dip",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"", "c"},
+			expectedRegister: "a\nb\n",
+		},
+		{
+			name:            "Invalid text object is ignored",
+			initialContent:  []string{"abc"},
+			keys:            "diz",
+			expectedMode:    editor.ModeNormal,
+			expectedContent: []string{"abc"},
+		},
+		{
+			name:            "Esc cancels pending operator",
+			initialContent:  []string{"abc"},
+			keys:            "d\x1b",
+			expectedMode:    editor.ModeNormal,
+			expectedContent: []string{"abc"},
+		},
+		{
+			name:             "viwd deletes selected word",
+			initialContent:   []string{"foo bar"},
+			initialCursorX:   1,
+			keys:             "viwd",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{" bar"},
+			expectedRegister: "foo",
+		},
+		{
+			name:             "va(a( selects the enclosing pair",
+			initialContent:   []string{"f(g(x))"},
+			initialCursorX:   4,
+			keys:             "va(a(y",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"f(g(x))"},
+			expectedCursorX:  1,
+			expectedRegister: "(g(x))",
+		},
+		{
+			name:             "vlly yanks selection",
+			initialContent:   []string{"abcdef"},
+			initialCursorX:   1,
+			keys:             "vlly",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"abcdef"},
+			expectedCursorX:  1,
+			expectedRegister: "bcd",
+		},
+		{
+			name:             "Vjd deletes lines",
+			initialContent:   []string{"a", "b", "c"},
+			keys:             "Vjd",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"c"},
+			expectedRegister: "a\nb\n",
+		},
+		{
+			name:             "yiwP puts before cursor",
+			initialContent:   []string{"ab cd"},
+			initialCursorX:   3,
+			keys:             "yiwP",
+			expectedMode:     editor.ModeNormal,
+			expectedContent:  []string{"ab cdcd"},
+			expectedCursorX:  4,
+			expectedRegister: "cd",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, tt.initialCursorX, tt.initialCursorY)
+
+			feedKeys(ed, tt.keys)
+
+			if ed.CurrentMode != tt.expectedMode {
+				t.Errorf("Expected Mode %v, got %v", tt.expectedMode, ed.CurrentMode)
+			}
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+			if tt.expectedRegister != "" {
+				reg, _ := ed.GetRegister(editor.UnnamedRegister)
+				if reg.Text != tt.expectedRegister {
+					t.Errorf("Expected register %q, got %q", tt.expectedRegister, reg.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestDotRepeat(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		initialCursorX  int
+		initialCursorY  int
+		keys            string
+		expectedContent []string
+	}{
+		{
+			name:            "Repeat change with inserted text",
+			initialContent:  []string{"one two three"},
+			keys:            "ciwfoo\x1blll.",
+			expectedContent: []string{"foo foo three"},
+		},
+		{
+			name:            "Repeat delete",
+			initialContent:  []string{"a b c d"},
+			keys:            "daw..",
+			expectedContent: []string{"d"},
+		},
+		{
+			name:            "Count replaces original count",
+			initialContent:  []string{"a b c d e"},
+			keys:            "daw3.",
+			expectedContent: []string{"e"},
+		},
+		{
+			name:            "Movement is not a change",
+			initialContent:  []string{"a b", "c d"},
+			keys:            "dawj.",
+			expectedContent: []string{"b", "d"},
+		},
+		{
+			name:            "Yank is not a change",
+			initialContent:  []string{"a b c"},
+			keys:            "dawyiw.",
+			expectedContent: []string{"c"},
+		},
+		{
+			name:            "Repeat Visual mode delete",
+			initialContent:  []string{"abcdef"},
+			keys:            "vld.",
+			expectedContent: []string{"ef"},
+		},
+		{
+			name:            "Insert is repeated",
+			initialContent:  []string{""},
+			keys:            "iab\x1b.",
+			expectedContent: []string{"abab"},
+		},
+		{
+			name:            "Nothing to repeat",
+			initialContent:  []string{"abc"},
+			keys:            ".",
+			expectedContent: []string{"abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, tt.initialCursorX, tt.initialCursorY)
+
+			feedKeys(ed, tt.keys)
+
+			if ed.CurrentMode != editor.ModeNormal {
+				t.Errorf("Expected Mode %v, got %v", editor.ModeNormal, ed.CurrentMode)
+			}
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+		})
+	}
+}
+
+func TestMacros(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		keys            string
+		expectedContent []string
+		expectedMacro   string // Contents of register a, if checked
+	}{
+		{
+			name:            "Record and replay",
+			initialContent:  []string{"a b", "c d", "e f"},
+			keys:            "qadawjq@a",
+			expectedContent: []string{"b", "d", "e f"},
+			expectedMacro:   "dawj",
+		},
+		{
+			name:            "Replay with count",
+			initialContent:  []string{"a b", "c d", "e f", "g h"},
+			keys:            "qadawjq" + "2@a",
+			expectedContent: []string{"b", "d", "f", "g h"},
+		},
+		{
+			name:            "@@ repeats the last macro",
+			initialContent:  []string{"a b c d"},
+			keys:            "qbdawq@b@@",
+			expectedContent: []string{"d"},
+		},
+		{
+			name:            "Uppercase register appends",
+			initialContent:  []string{"a b c d"},
+			keys:            "qadawqqAdawq",
+			expectedContent: []string{"c d"},
+			expectedMacro:   "dawdaw",
+		},
+		{
+			name:            "Failing motion stops a recursive macro",
+			initialContent:  []string{"x1", "x2", "x3"},
+			keys:            "qaq" + "qayiwPj@aq" + "@a",
+			expectedContent: []string{"x1x1", "x2x2", "x3x3"},
+		},
+		{
+			name:            "Macro that never fails stops at the depth limit",
+			initialContent:  []string{""},
+			keys:            "qaq" + "qa@aq" + "@a",
+			expectedContent: []string{""},
+		},
+		{
+			name:            "Empty register is an error",
+			initialContent:  []string{"abc"},
+			keys:            "@z",
+			expectedContent: []string{"abc"},
+		},
+		{
+			name:            "Unnamed register",
+			initialContent:  []string{"a b c"},
+			keys:            "q\"dawq@\"",
+			expectedContent: []string{"c"},
+		},
+		{
+			name:            "Numbered register is not replayed",
+			initialContent:  []string{"abc"},
+			keys:            "yiw@0",
+			expectedContent: []string{"abc"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, 0)
+
+			feedKeys(ed, tt.keys)
+
+			if ed.RecordingRegister != 0 {
+				t.Errorf("Expected recording to have stopped, still recording into %q", ed.RecordingRegister)
+			}
+			if ed.MacroDepth != 0 {
+				t.Errorf("Expected MacroDepth 0, got %d", ed.MacroDepth)
+			}
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if tt.expectedMacro != "" {
+				reg, _ := ed.GetRegister('a')
+				if reg.Text != tt.expectedMacro {
+					t.Errorf("Expected register a %q, got %q", tt.expectedMacro, reg.Text)
+				}
+			}
+		})
+	}
+}
+
+func TestNormalCommand(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		initialCursorY  int
+		command         string
+		expectedContent []string
+		expectedMode    editor.Mode
+	}{
+		{
+			name:            "Runs on the cursor line",
+			initialContent:  []string{"a b", "c d"},
+			initialCursorY:  1,
+			command:         "normal daw",
+			expectedContent: []string{"a b", "d"},
+		},
+		{
+			name:            "Runs on each line of a range",
+			initialContent:  []string{"a b", "c d", "e f"},
+			command:         "1,2norm daw",
+			expectedContent: []string{"b", "d", "e f"},
+		},
+		{
+			name:            "Incomplete insert is ended",
+			initialContent:  []string{"x", "y"},
+			command:         "%normal iz",
+			expectedContent: []string{"zx", "zy"},
+		},
+		{
+			name:            "Runs macros",
+			initialContent:  []string{"a b", "c d"},
+			command:         "%normal @q",
+			expectedContent: []string{"b", "d"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, tt.initialCursorY)
+			ed.SetRegister('q', editor.Register{Text: "daw"})
+
+			feedKeys(ed, ":"+tt.command+"\r")
+
+			if ed.CurrentMode != editor.ModeNormal {
+				t.Errorf("Expected Mode %v, got %v", editor.ModeNormal, ed.CurrentMode)
+			}
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+		})
+	}
+}
+
+func TestEditCommands(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		initialCursorX  int
+		initialCursorY  int
+		keys            string
+		expectedMode    editor.Mode
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{name: "a appends after cursor", initialContent: []string{"ac"}, keys: "ab", expectedMode: editor.ModeInsert, expectedContent: []string{"abc"}, expectedCursorX: 2},
+		{name: "A appends at end of line", initialContent: []string{"ab"}, keys: "Ac\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"abc"}, expectedCursorX: 3},
+		{name: "I inserts before first non-blank", initialContent: []string{"  b"}, initialCursorX: 2, keys: "Ia", expectedMode: editor.ModeInsert, expectedContent: []string{"  ab"}, expectedCursorX: 3},
+		{name: "o opens line below", initialContent: []string{"a", "c"}, keys: "ob", expectedMode: editor.ModeInsert, expectedContent: []string{"a", "b", "c"}, expectedCursorX: 1, expectedCursorY: 1},
+		{name: "O opens line above", initialContent: []string{"b"}, keys: "Oa", expectedMode: editor.ModeInsert, expectedContent: []string{"a", "b"}, expectedCursorX: 1},
+		{name: "Count repeats inserted text", initialContent: []string{""}, keys: "3iab\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"ababab"}, expectedCursorX: 6},
+		{name: "Count with o opens several lines", initialContent: []string{"x"}, keys: "2oy\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"x", "y", "y"}, expectedCursorX: 1, expectedCursorY: 2},
+		{name: "x deletes char", initialContent: []string{"abc"}, initialCursorX: 1, keys: "x", expectedMode: editor.ModeNormal, expectedContent: []string{"ac"}, expectedCursorX: 1},
+		{name: "3x deletes three chars", initialContent: []string{"abcde"}, keys: "3x", expectedMode: editor.ModeNormal, expectedContent: []string{"de"}},
+		{name: "x count stops at end of line", initialContent: []string{"abc"}, initialCursorX: 1, keys: "9x", expectedMode: editor.ModeNormal, expectedContent: []string{"a"}, expectedCursorX: 1},
+		{name: "X deletes before cursor", initialContent: []string{"abcd"}, initialCursorX: 3, keys: "2X", expectedMode: editor.ModeNormal, expectedContent: []string{"ad"}, expectedCursorX: 1},
+		{name: "s substitutes chars", initialContent: []string{"abc"}, keys: "2sx\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"xc"}, expectedCursorX: 1},
+		{name: "S substitutes line", initialContent: []string{"abc", "def"}, keys: "Sx\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"x", "def"}, expectedCursorX: 1},
+		{name: "cc changes lines", initialContent: []string{"a", "b", "c"}, keys: "2ccx\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"x", "c"}, expectedCursorX: 1},
+		{name: "cc on only line", initialContent: []string{"abc"}, keys: "cc", expectedMode: editor.ModeInsert, expectedContent: []string{""}},
+		{name: "C changes to end of line", initialContent: []string{"abcd"}, initialCursorX: 2, keys: "Cx\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"abx"}, expectedCursorX: 3},
+		{name: "D deletes to end of line", initialContent: []string{"abcd"}, initialCursorX: 1, keys: "D", expectedMode: editor.ModeNormal, expectedContent: []string{"a"}, expectedCursorX: 1},
+		{name: "2D deletes into next line", initialContent: []string{"ab", "cd", "ef"}, initialCursorX: 1, keys: "2D", expectedMode: editor.ModeNormal, expectedContent: []string{"a", "ef"}, expectedCursorX: 1},
+		{name: "dd deletes line", initialContent: []string{"a", "b", "c"}, initialCursorY: 1, keys: "dd", expectedMode: editor.ModeNormal, expectedContent: []string{"a", "c"}, expectedCursorY: 1},
+		{name: "3dd deletes lines", initialContent: []string{"a", "b", "c", "d"}, keys: "3dd", expectedMode: editor.ModeNormal, expectedContent: []string{"d"}},
+		{name: "d2d deletes lines", initialContent: []string{"a", "b", "c"}, keys: "d2d", expectedMode: editor.ModeNormal, expectedContent: []string{"c"}},
+		{name: "dd on last line", initialContent: []string{"a", "b"}, initialCursorY: 1, keys: "dd", expectedMode: editor.ModeNormal, expectedContent: []string{"a"}},
+		{name: "yyp duplicates line", initialContent: []string{"a", "b"}, keys: "yyp", expectedMode: editor.ModeNormal, expectedContent: []string{"a", "a", "b"}, expectedCursorY: 1},
+		{name: "r replaces char", initialContent: []string{"abc"}, initialCursorX: 1, keys: "rx", expectedMode: editor.ModeNormal, expectedContent: []string{"axc"}, expectedCursorX: 1},
+		{name: "3r replaces chars", initialContent: []string{"abcd"}, keys: "3rx", expectedMode: editor.ModeNormal, expectedContent: []string{"xxxd"}, expectedCursorX: 2},
+		{name: "r fails past end of line", initialContent: []string{"ab"}, initialCursorX: 1, keys: "3rx", expectedMode: editor.ModeNormal, expectedContent: []string{"ab"}, expectedCursorX: 1},
+		{name: "r Enter splits line", initialContent: []string{"a b"}, initialCursorX: 1, keys: "r\r", expectedMode: editor.ModeNormal, expectedContent: []string{"a", "b"}, expectedCursorY: 1},
+		{name: "J joins lines", initialContent: []string{"a", "  b", "c"}, keys: "J", expectedMode: editor.ModeNormal, expectedContent: []string{"a b", "c"}, expectedCursorX: 1},
+		{name: "3J joins three lines", initialContent: []string{"a", "b", "c"}, keys: "3J", expectedMode: editor.ModeNormal, expectedContent: []string{"a b c"}, expectedCursorX: 3},
+		{name: "~ toggles case", initialContent: []string{"aBc"}, keys: "2~", expectedMode: editor.ModeNormal, expectedContent: []string{"Abc"}, expectedCursorX: 2},
+		{name: "R overwrites", initialContent: []string{"abcd"}, initialCursorX: 1, keys: "Rxyz\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"axyz"}, expectedCursorX: 4},
+		{name: "R extends line", initialContent: []string{"ab"}, initialCursorX: 1, keys: "Rxyz", expectedMode: editor.ModeReplace, expectedContent: []string{"axyz"}, expectedCursorX: 4},
+		{name: "Backspace in Replace mode restores", initialContent: []string{"ab"}, keys: "Rxyz\x7f\x7f", expectedMode: editor.ModeReplace, expectedContent: []string{"xb"}, expectedCursorX: 1},
+		{name: "3R repeats on Esc", initialContent: []string{"xxxxxxxxx"}, keys: "3Rab\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"abababxxx"}, expectedCursorX: 6},
+		{name: "2R repeats past end of line", initialContent: []string{"ab"}, keys: "2Rxyz\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"xyzxyz"}, expectedCursorX: 6},
+		{name: "3R with everything erased", initialContent: []string{"cdef"}, keys: "3Rab\x7f\x7f\x1b", expectedMode: editor.ModeNormal, expectedContent: []string{"cdef"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, tt.initialCursorX, tt.initialCursorY)
+
+			feedKeys(ed, tt.keys)
+
+			if ed.CurrentMode != tt.expectedMode {
+				t.Errorf("Expected Mode %v, got %v", tt.expectedMode, ed.CurrentMode)
+			}
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestMarkCommands(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		keys            string
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{
+			name:            "' jumps to first non-blank of mark line",
+			initialContent:  []string{"  abc", "def"},
+			keys:            "llllmajj'a",
+			expectedCursorX: 2,
+		},
+		{
+			name:            "` jumps to exact mark position",
+			initialContent:  []string{"  abc", "def"},
+			keys:            "lllmaj`a",
+			expectedCursorX: 3,
+		},
+		{
+			name:            "'' returns to position before jump",
+			initialContent:  []string{"a", "b", "c"},
+			keys:            "majj'a''",
+			expectedCursorY: 2,
+		},
+		{
+			name:            "Ctrl-O and Ctrl-I walk the jump list",
+			initialContent:  []string{"a", "b", "c"},
+			keys:            "majjmb'a'b\x0f\x0f\x0f\x09",
+			expectedCursorY: 2,
+		},
+		{
+			name:            "Mark follows inserted lines",
+			initialContent:  []string{"a", "b"},
+			keys:            "jmakOx\x1bj'a",
+			expectedCursorY: 2,
+		},
+		{
+			name:            "'. jumps to last change",
+			initialContent:  []string{"a", "b", "c"},
+			keys:            "jxk'.",
+			expectedContent: []string{"a", "", "c"},
+			expectedCursorY: 1,
+		},
+		{
+			name:            "'< and '> remember Visual selection",
+			initialContent:  []string{"a", "b", "c"},
+			keys:            "jvj\x1bkk'>",
+			expectedCursorY: 2,
+		},
+		{
+			name:            "'^ remembers where Insert mode was left",
+			initialContent:  []string{"a", "b"},
+			keys:            "jAx\x1bk`^",
+			expectedContent: []string{"a", "bx"},
+			expectedCursorX: 2,
+			expectedCursorY: 1,
+		},
+		{
+			name:            "Unset mark fails",
+			initialContent:  []string{"a", "b"},
+			keys:            "j'z",
+			expectedCursorY: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, 0)
+
+			feedKeys(ed, tt.keys)
+
+			if tt.expectedContent != nil {
+				for i := range tt.expectedContent {
+					if ed.EditorContent[i] != tt.expectedContent[i] {
+						t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+					}
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestTabCommands(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		initialCursorY  int
+		keys            string
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{name: ">> shifts right", initialContent: []string{"a", "b"}, keys: ">>", expectedContent: []string{"  a", "b"}, expectedCursorX: 2},
+		{name: "3>> shifts three lines", initialContent: []string{"a", "b", "c", "d"}, keys: "3>>", expectedContent: []string{"  a", "  b", "  c", "d"}, expectedCursorX: 2},
+		{name: "<< shifts left", initialContent: []string{"    a"}, keys: "<<", expectedContent: []string{"  a"}, expectedCursorX: 2},
+		{name: "<< stops at column 0", initialContent: []string{" a"}, keys: "<<", expectedContent: []string{"a"}},
+		{name: ">ip shifts paragraph", initialContent: []string{"a", "b", "", "c"}, keys: ">ip", expectedContent: []string{"  a", "  b", "", "c"}, expectedCursorX: 2},
+		{name: "Dot repeats shift", initialContent: []string{"a"}, keys: ">>..", expectedContent: []string{"      a"}, expectedCursorX: 6},
+		{name: "Visual shift with count", initialContent: []string{"a", "b", "c"}, keys: "Vj2>", expectedContent: []string{"    a", "    b", "c"}, expectedCursorX: 4},
+		{name: "Tab inserts soft tab", initialContent: []string{"x"}, keys: "i\t\t", expectedContent: []string{"    x"}, expectedCursorX: 4},
+		{name: "Backspace deletes soft tab", initialContent: []string{"x"}, keys: "i\t\t\x7f", expectedContent: []string{"  x"}, expectedCursorX: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, tt.initialCursorY)
+			_ = ed.SetOption("shiftwidth", 2)
+			_ = ed.SetOption("softtabstop", -1)
+			_ = ed.SetOption("expandtab", true)
+
+			feedKeys(ed, tt.keys)
+
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestIndentCommands(t *testing.T) {
+	tests := []struct {
+		name            string
+		options         map[string]any
+		initialContent  []string
+		initialCursorY  int
+		keys            string
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{name: "No autoindent", initialContent: []string{"  a"}, keys: "A\rb", expectedContent: []string{"  a", "b"}, expectedCursorX: 1, expectedCursorY: 1},
+		{name: "Autoindent on Enter", options: map[string]any{"autoindent": true}, initialContent: []string{"  a"}, keys: "A\rb", expectedContent: []string{"  a", "  b"}, expectedCursorX: 3, expectedCursorY: 1},
+		{name: "Autoindent with o", options: map[string]any{"autoindent": true, "expandtab": false}, initialContent: []string{"\ta"}, keys: "ob", expectedContent: []string{"\ta", "\tb"}, expectedCursorX: 2, expectedCursorY: 1},
+		{name: "Autoindent with O", options: map[string]any{"autoindent": true}, initialContent: []string{"x", "    a"}, initialCursorY: 1, keys: "Ob", expectedContent: []string{"x", "    b", "    a"}, expectedCursorX: 5, expectedCursorY: 1},
+		{name: "Count with o repeats indent", options: map[string]any{"autoindent": true}, initialContent: []string{"  a"}, keys: "2ob\x1b", expectedContent: []string{"  a", "  b", "  b"}, expectedCursorX: 3, expectedCursorY: 2},
+		{name: "Smartindent after brace", options: map[string]any{"smartindent": true}, initialContent: []string{"if x {"}, keys: "A\ry", expectedContent: []string{"if x {", "  y"}, expectedCursorX: 3, expectedCursorY: 1},
+		{name: "Smartindent dedents brace", options: map[string]any{"smartindent": true}, initialContent: []string{"if x {"}, keys: "A\ry\r}", expectedContent: []string{"if x {", "  y", "}"}, expectedCursorX: 1, expectedCursorY: 2},
+		{name: "Smartindent splits braces", options: map[string]any{"smartindent": true}, initialContent: []string{"f() {}"}, keys: "A\x1bhi\r", expectedContent: []string{"f() {", "}"}, expectedCursorY: 1},
+		{name: "Smartindent Python colon", options: map[string]any{"smartindent": true, "filetype": "python"}, initialContent: []string{"def f():"}, keys: "A\rx", expectedContent: []string{"def f():", "  x"}, expectedCursorX: 3, expectedCursorY: 1},
+		{name: "== re-indents line", initialContent: []string{"{", "x", "}"}, initialCursorY: 1, keys: "==", expectedContent: []string{"{", "  x", "}"}, expectedCursorX: 2, expectedCursorY: 1},
+		{name: "=ip re-indents paragraph", initialContent: []string{"{", "   x", "    }"}, keys: "=ip", expectedContent: []string{"{", "  x", "}"}},
+		{name: "Visual =", initialContent: []string{"{", "x", "y", "}"}, initialCursorY: 1, keys: "Vj=", expectedContent: []string{"{", "  x", "  y", "}"}, expectedCursorX: 2, expectedCursorY: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, tt.initialCursorY)
+			_ = ed.SetOption("shiftwidth", 2)
+			_ = ed.SetOption("expandtab", true)
+			for name, value := range tt.options {
+				_ = ed.SetOption(name, value)
+			}
+
+			feedKeys(ed, tt.keys)
+
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestAutoPairs(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		keys            string
+		inString        bool
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{name: "Closes bracket", initialContent: []string{""}, keys: "i(", expectedContent: []string{"()"}, expectedCursorX: 1},
+		{name: "Types over closer", initialContent: []string{""}, keys: "i(x)", expectedContent: []string{"(x)"}, expectedCursorX: 3},
+		{name: "Nested pairs", initialContent: []string{""}, keys: "i([", expectedContent: []string{"([])"}, expectedCursorX: 2},
+		{name: "Closes quote", initialContent: []string{""}, keys: "i\"a\"", expectedContent: []string{"\"a\""}, expectedCursorX: 3},
+		{name: "Apostrophe after word", initialContent: []string{""}, keys: "idon't", expectedContent: []string{"don't"}, expectedCursorX: 5},
+		{name: "No pair before word", initialContent: []string{"x"}, keys: "i(", expectedContent: []string{"(x"}, expectedCursorX: 1},
+		{name: "Backspace deletes empty pair", initialContent: []string{"a"}, keys: "A{\x7f", expectedContent: []string{"a"}, expectedCursorX: 1},
+		{name: "Backspace empties pair after text", initialContent: []string{""}, keys: "i(x\x7f\x7f", expectedContent: []string{""}},
+		{name: "Enter opens block", initialContent: []string{"  f()"}, keys: "A {\rx", expectedContent: []string{"  f() {", "    x", "  }"}, expectedCursorX: 5, expectedCursorY: 1},
+		{name: "Enter between quotes", initialContent: []string{""}, keys: "i\"\r", expectedContent: []string{"\"", "\""}, expectedCursorY: 1},
+		{name: "Not in strings", initialContent: []string{""}, keys: "i(", inString: true, expectedContent: []string{"("}, expectedCursorX: 1},
+		{name: "Dot repeats paired insert", initialContent: []string{""}, keys: "a(x\x1b.", expectedContent: []string{"(x)(x)"}, expectedCursorX: 5},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, 0)
+			_ = ed.SetOption("autopair", true)
+			_ = ed.SetOption("shiftwidth", 2)
+			_ = ed.SetOption("expandtab", true)
+			if tt.inString {
+				ed.SyntaxAt = func(p editor.Position) string { return "String" }
+			}
+
+			feedKeys(ed, tt.keys)
+
+			if len(ed.EditorContent) != len(tt.expectedContent) {
+				t.Fatalf("Expected %d lines, got %d: %q", len(tt.expectedContent), len(ed.EditorContent), ed.EditorContent)
+			}
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestInsertCompletion(t *testing.T) {
+	tests := []struct {
+		name              string
+		initialContent    []string
+		keys              string
+		expectedContent   []string
+		expectedCursorX   int
+		expectedMode      editor.Mode
+		expectedCompleted bool
+	}{
+		{name: "Ctrl-N completes first match", initialContent: []string{"alpha", "al"}, keys: "jA\x0e", expectedContent: []string{"alpha", "alpha"}, expectedCursorX: 5, expectedMode: editor.ModeInsert, expectedCompleted: true},
+		{name: "Ctrl-N twice moves on", initialContent: []string{"alpha beta", "al"}, keys: "jA\x0e\x0e", expectedContent: []string{"alpha beta", "al"}, expectedCursorX: 2, expectedMode: editor.ModeInsert, expectedCompleted: true},
+		{name: "Ctrl-P completes last match", initialContent: []string{"alpha also", "al"}, keys: "jA\x10", expectedContent: []string{"alpha also", "also"}, expectedCursorX: 4, expectedMode: editor.ModeInsert, expectedCompleted: true},
+		{name: "Arrow keys navigate", initialContent: []string{"alpha also", "al"}, keys: "jA\x0e\xfb\xfb\xfa", expectedContent: []string{"alpha also", "also"}, expectedCursorX: 4, expectedMode: editor.ModeInsert, expectedCompleted: true},
+		{name: "Tab moves on", initialContent: []string{"alpha also", "al"}, keys: "jA\x0e\t", expectedContent: []string{"alpha also", "also"}, expectedCursorX: 4, expectedMode: editor.ModeInsert, expectedCompleted: true},
+		{name: "Enter accepts", initialContent: []string{"alpha", "al"}, keys: "jA\x0e\r", expectedContent: []string{"alpha", "alpha"}, expectedCursorX: 5, expectedMode: editor.ModeInsert},
+		{name: "Typing accepts", initialContent: []string{"alpha", "al"}, keys: "jA\x0e!", expectedContent: []string{"alpha", "alpha!"}, expectedCursorX: 6, expectedMode: editor.ModeInsert},
+		{name: "Esc accepts and leaves Insert mode", initialContent: []string{"alpha", "al"}, keys: "jA\x0e\x1b", expectedContent: []string{"alpha", "alpha"}, expectedCursorX: 5, expectedMode: editor.ModeNormal},
+		{name: "Ctrl-E cancels", initialContent: []string{"alpha", "al"}, keys: "jA\x0e\x05", expectedContent: []string{"alpha", "al"}, expectedCursorX: 2, expectedMode: editor.ModeInsert},
+		{name: "Ctrl-X Ctrl-L completes line", initialContent: []string{"  return nil, err", "  re"}, keys: "jA\x18\x0c", expectedContent: []string{"  return nil, err", "  return nil, err"}, expectedCursorX: 17, expectedMode: editor.ModeInsert, expectedCompleted: true},
+		{name: "Ctrl-X then other key", initialContent: []string{""}, keys: "i\x18x", expectedContent: []string{"x"}, expectedCursorX: 1, expectedMode: editor.ModeInsert},
+		{name: "No match", initialContent: []string{"zz"}, keys: "A\x0e", expectedContent: []string{"zz"}, expectedCursorX: 2, expectedMode: editor.ModeInsert},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, 0)
+
+			feedKeys(ed, tt.keys)
+
+			for i := range tt.expectedContent {
+				if ed.EditorContent[i] != tt.expectedContent[i] {
+					t.Errorf("Line %d: Expected %q, got %q", i, tt.expectedContent[i], ed.EditorContent[i])
+				}
+			}
+			if ed.CursorX != tt.expectedCursorX {
+				t.Errorf("Expected CursorX %d, got %d", tt.expectedCursorX, ed.CursorX)
+			}
+			if ed.CurrentMode != tt.expectedMode {
+				t.Errorf("Expected Mode %v, got %v", tt.expectedMode, ed.CurrentMode)
+			}
+			if (ed.Completion != nil) != tt.expectedCompleted {
+				t.Errorf("Expected completion in progress %t, got %t", tt.expectedCompleted, ed.Completion != nil)
+			}
+		})
+	}
+}
+
+func TestLanguageServerKeys(t *testing.T) {
+	cmd.RegisterCommand("hover", func(e *editor.Editor, c cmd.ExCommand) error {
+		e.ShowOutput([]string{"docs"})
+		return nil
+	})
+	cmd.RegisterCommand("definition", func(e *editor.Editor, c cmd.ExCommand) error {
+		e.CursorY, e.CursorX = 0, 4
+		return nil
+	})
+
+	ed := newTestEditor([]string{"func f()", "f()"}, 0, 1)
+	feedKeys(ed, "gd")
+	if ed.CursorY != 0 || ed.CursorX != 4 {
+		t.Errorf("Expected gd to move to 0,4, got %d,%d", ed.CursorY, ed.CursorX)
+	}
+
+	feedKeys(ed, "K")
+	if len(ed.Output) != 1 || ed.Output[0] != "docs" {
+		t.Errorf("Expected K to show docs, got %q", ed.Output)
+	}
+	feedKeys(ed, "\r")
+	if ed.Output != nil || ed.CursorY != 0 {
+		t.Errorf("Expected Enter only to dismiss the output")
+	}
+	feedKeys(ed, "Kj")
+	if ed.Output != nil || ed.CursorY != 1 {
+		t.Errorf("Expected j to dismiss the output and move down")
+	}
+
+	ed.OmniFunc = func() (int, []string) { return 0, []string{"fmt", "func"} }
+	feedKeys(ed, "S\x18\x0f")
+	if ed.Completion == nil || ed.EditorContent[1] != "fmt" {
+		t.Errorf("Expected Ctrl-X Ctrl-O to complete fmt, got %q", ed.EditorContent[1])
+	}
+}
+
+func TestUndoCommands(t *testing.T) {
+	tests := []struct {
+		name            string
+		initialContent  []string
+		keys            string
+		expectedContent []string
+		expectedCursorY int
+		expectedCommand string
+	}{
+		{name: "u undoes one x", initialContent: []string{"abc"}, keys: "xxu", expectedContent: []string{"bc"}},
+		{name: "u undoes a counted command", initialContent: []string{"abcd"}, keys: "3xu", expectedContent: []string{"abcd"}},
+		{name: "u undoes a whole insert", initialContent: []string{"x"}, keys: "oab\rcd\x1bu", expectedContent: []string{"x"}},
+		{name: "u with count", initialContent: []string{"abc"}, keys: "xxx2u", expectedContent: []string{"bc"}},
+		{name: "Ctrl-R redoes", initialContent: []string{"abc"}, keys: "xxuu\x12", expectedContent: []string{"bc"}},
+		{name: "u undoes a replayed macro at once", initialContent: []string{"abcdef"}, keys: "qaxxqu@au", expectedContent: []string{"bcdef"}},
+		{name: "dot after undo repeats the change", initialContent: []string{"abc"}, keys: "xu.", expectedContent: []string{"bc"}},
+		{name: "u undoes a filter", initialContent: []string{"b", "a"}, keys: ":%!sort\ru", expectedContent: []string{"b", "a"}},
+		{name: ":undo and :redo", initialContent: []string{"abc"}, keys: "x:undo\r:redo\r", expectedContent: []string{"bc"}},
+		{name: "u with nothing to undo", initialContent: []string{"abc"}, keys: "u", expectedContent: []string{"abc"}},
+		{name: "!! starts a filter of the line", initialContent: []string{"a", "b"}, keys: "!!", expectedContent: []string{"a", "b"}, expectedCommand: ".!"},
+		{name: "!{count}! filters lines", initialContent: []string{"a", "b", "c"}, keys: "3!!", expectedContent: []string{"a", "b", "c"}, expectedCommand: ".,.+2!"},
+		{name: "!ip filters a paragraph", initialContent: []string{"a", "b", "", "c"}, keys: "j!ip", expectedContent: []string{"a", "b", "", "c"}, expectedCursorY: 1, expectedCommand: "1,2!"},
+		{name: "!j filters two lines", initialContent: []string{"a", "b", "c"}, keys: "!j", expectedContent: []string{"a", "b", "c"}, expectedCommand: ".,.+1!"},
+		{name: "!G filters to the end", initialContent: []string{"a", "b", "c"}, keys: "j!G", expectedContent: []string{"a", "b", "c"}, expectedCursorY: 1, expectedCommand: ".,.+1!"},
+		{name: "!k filters from the line above", initialContent: []string{"a", "b", "c"}, keys: "G!k", expectedContent: []string{"a", "b", "c"}, expectedCursorY: 1, expectedCommand: ".,.+1!"},
+		{name: "!gg then a command filters", initialContent: []string{"c", "b", "a"}, keys: "G!ggsort\r", expectedContent: []string{"a", "b", "c"}},
+		{name: "Visual ! filters the selection", initialContent: []string{"a", "b"}, keys: "Vj!", expectedContent: []string{"a", "b"}, expectedCursorY: 1, expectedCommand: "'<,'>!"},
+		{name: "!! then a command filters", initialContent: []string{"b", "c", "a"}, keys: "2!!sort\r", expectedContent: []string{"b", "c", "a"}},
+		{name: "Visual ! then a command filters", initialContent: []string{"c", "b", "a"}, keys: "Vj!sort\r", expectedContent: []string{"b", "c", "a"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor(tt.initialContent, 0, 0)
+
+			feedKeys(ed, tt.keys)
+
+			if strings.Join(ed.EditorContent, "|") != strings.Join(tt.expectedContent, "|") {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, ed.EditorContent)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+			if ed.CommandBuffer != tt.expectedCommand {
+				t.Errorf("Expected command %q, got %q", tt.expectedCommand, ed.CommandBuffer)
+			}
+		})
+	}
+}
+
+func TestKeyMappings(t *testing.T) {
+	tests := []struct {
+		name            string
+		mappings        []string
+		keys            string
+		expectedContent []string
+		expectedCursorX int
+		expectedCursorY int
+	}{
+		{name: "nnoremap runs its keys", mappings: []string{"nnoremap Q dd"}, keys: "Q", expectedContent: []string{"two", "three"}},
+		{name: "special keys", mappings: []string{"nnoremap <Space>o o<Esc>"}, keys: " o", expectedContent: []string{"one", "", "two", "three"}, expectedCursorY: 1},
+		{name: "leader", mappings: []string{"set mapleader=,", "nnoremap <leader>d dd"}, keys: ",d", expectedContent: []string{"two", "three"}},
+		{name: "default leader", mappings: []string{"nnoremap <Leader>x x"}, keys: "\\x", expectedContent: []string{"ne", "two", "three"}},
+		{name: "unmapped prefix is typed", mappings: []string{"nnoremap ab dd"}, keys: "ax\x1b", expectedContent: []string{"oxne", "two", "three"}, expectedCursorX: 2},
+		{name: "longest mapping runs", mappings: []string{"nnoremap , j", "nnoremap ,,, dd"}, keys: ",,x", expectedContent: []string{"one", "two", "hree"}, expectedCursorY: 2},
+		{name: "recursive mapping is remapped", mappings: []string{"nmap Q W", "nnoremap W dd"}, keys: "Q", expectedContent: []string{"two", "three"}},
+		{name: "noremap is not remapped", mappings: []string{"nnoremap Q W", "nnoremap W dd"}, keys: "Q", expectedContent: []string{"one", "two", "three"}, expectedCursorX: 0},
+		{name: "rhs starting with lhs", mappings: []string{"nmap x xj"}, keys: "x", expectedContent: []string{"ne", "two", "three"}, expectedCursorY: 1},
+		{name: "mapping loop stops", mappings: []string{"nmap Q W", "nmap W Q"}, keys: "Qx", expectedContent: []string{"ne", "two", "three"}},
+		{name: "imap", mappings: []string{"inoremap jk <Esc>"}, keys: "Ahijk", expectedContent: []string{"onehi", "two", "three"}, expectedCursorX: 5},
+		{name: "imap keys that do not match are inserted", mappings: []string{"inoremap jk <Esc>"}, keys: "Ajx", expectedContent: []string{"onejx", "two", "three"}, expectedCursorX: 5},
+		{name: "map! is for Insert mode", mappings: []string{"noremap! ;; <BS><BS>"}, keys: "A;;", expectedContent: []string{"o", "two", "three"}, expectedCursorX: 1},
+		{name: "vnoremap", mappings: []string{"vnoremap D d"}, keys: "vjD", expectedContent: []string{"wo", "three"}},
+		{name: "map is for Normal and Visual mode", mappings: []string{"noremap Q d"}, keys: "vjQQd", expectedContent: []string{"three"}},
+		{name: "mapping in a pending command is not used", mappings: []string{"nnoremap w dd"}, keys: "diw", expectedContent: []string{"", "two", "three"}},
+		{name: "mapping is one undo step", mappings: []string{"nnoremap Q xjxjx"}, keys: "Qu", expectedContent: []string{"one", "two", "three"}},
+		{name: "macro keys are mapped", mappings: []string{"nnoremap Q dd"}, keys: "qaQqu@a", expectedContent: []string{"two", "three"}},
+		{name: "normal keys are mapped", mappings: []string{"nnoremap Q dd"}, keys: ":normal Q\r", expectedContent: []string{"two", "three"}},
+		{name: "normal! keys are not mapped", mappings: []string{"nnoremap x dd"}, keys: ":normal! x\r", expectedContent: []string{"ne", "two", "three"}},
+		{name: "dot repeats the mapped keys", mappings: []string{"nnoremap Q dd"}, keys: "Q.", expectedContent: []string{"three"}},
+		{name: "unmap", mappings: []string{"nnoremap x dd", "nunmap x"}, keys: "x", expectedContent: []string{"ne", "two", "three"}},
+		{name: "Nop", mappings: []string{"nnoremap x <Nop>"}, keys: "x", expectedContent: []string{"one", "two", "three"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor([]string{"one", "two", "three"}, 0, 0)
+			for _, m := range tt.mappings {
+				if err := cmd.ExecuteCommand(ed, m); err != nil {
+					t.Fatalf("%s: %v", m, err)
+				}
+			}
+
+			feedKeys(ed, tt.keys)
+
+			if strings.Join(ed.EditorContent, "|") != strings.Join(tt.expectedContent, "|") {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, ed.EditorContent)
+			}
+			if ed.CursorX != tt.expectedCursorX || ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected cursor at %d,%d, got %d,%d", tt.expectedCursorX, tt.expectedCursorY, ed.CursorX, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestMappingTimeout(t *testing.T) {
+	ed := newTestEditor([]string{"one", "two"}, 0, 0)
+	for _, m := range []string{"nnoremap , dd", "nnoremap ,x j", "set timeoutlen=50"} {
+		if err := cmd.ExecuteCommand(ed, m); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+
+	feedKeys(ed, ",")
+	ProcessInput(ed, terminal.KeyNull)
+	if len(ed.EditorContent) != 2 || string(ed.MappedKeys) != "," {
+		t.Fatalf("Expected , to wait for more keys, got %q and held keys %q", ed.EditorContent, ed.MappedKeys)
+	}
+	ed.MappedKeysTime = time.Now().Add(-time.Second)
+	ProcessInput(ed, terminal.KeyNull)
+	if strings.Join(ed.EditorContent, "|") != "two" || len(ed.MappedKeys) != 0 {
+		t.Errorf("Expected , to run after the timeout, got %q and held keys %q", ed.EditorContent, ed.MappedKeys)
+	}
+}
+
+func TestMapCommands(t *testing.T) {
+	ed := newTestEditor([]string{""}, 0, 0)
+	for _, m := range []string{"nnoremap <leader>w :w<CR>", "nmap gb <C-w>", "inoremap jk <Esc>", "map Q <lt>b"} {
+		if err := cmd.ExecuteCommand(ed, m); err != nil {
+			t.Fatalf("%s: %v", m, err)
+		}
+	}
+
+	tests := []struct {
+		command  string
+		expected []string
+	}{
+		{"nmap", []string{"n  Q              <lt>b", "n  \\w           * :w<CR>", "n  gb             <C-w>"}},
+		{"map", []string{"n  Q              <lt>b", "n  \\w           * :w<CR>", "n  gb             <C-w>", "v  Q              <lt>b"}},
+		{"imap", []string{"i  jk           * <Esc>"}},
+		{"nmap g", []string{"n  gb             <C-w>"}},
+	}
+	for _, tt := range tests {
+		ed.Output = nil
+		if err := cmd.ExecuteCommand(ed, tt.command); err != nil {
+			t.Fatalf("%s: %v", tt.command, err)
+		}
+		if strings.Join(ed.Output, "|") != strings.Join(tt.expected, "|") {
+			t.Errorf("%s: expected %q, got %q", tt.command, tt.expected, ed.Output)
+		}
+	}
+
+	errorTests := []struct {
+		command  string
+		expected string
+	}{
+		{"vmap g", "No mapping found"},
+		{"iunmap Q", "No such mapping"},
+		{"unmap", "Argument required"},
+		{"nmap x <C-1>", "Invalid key: <C-1>"},
+	}
+	for _, tt := range errorTests {
+		if err := cmd.ExecuteCommand(ed, tt.command); err == nil || err.Error() != tt.expected {
+			t.Errorf("%s: expected error %q, got %v", tt.command, tt.expected, err)
+		}
+	}
+}
+
+func TestAutocmdEvents(t *testing.T) {
+	ed := newTestEditor([]string{"one"}, 0, 0)
+	ed.Filename = "notes.txt"
+	for _, c := range []string{
+		"autocmd InsertEnter * set number",
+		"autocmd InsertLeave *.txt normal A!",
+		"autocmd ModeChanged i:n set relativenumber",
+		"autocmd CursorHold * normal x",
+		"autocmd CursorHoldI * set expandtab",
+		"set updatetime=50",
+	} {
+		if err := cmd.ExecuteCommand(ed, c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+
+	feedKeys(ed, "ia")
+	if !ed.BoolOption("number") || ed.BoolOption("relativenumber") {
+		t.Errorf("Expected InsertEnter to set number only")
+	}
+	feedKeys(ed, "\x1b")
+	if ed.EditorContent[0] != "aone!" || !ed.BoolOption("relativenumber") {
+		t.Errorf("Expected InsertLeave and ModeChanged to run, got %q", ed.EditorContent[0])
+	}
+	feedKeys(ed, "u")
+	if ed.EditorContent[0] != "one" {
+		t.Errorf("Expected u to undo the insert and InsertLeave together, got %q", ed.EditorContent[0])
+	}
+
+	ProcessInput(ed, terminal.KeyNull)
+	if ed.EditorContent[0] != "one" {
+		t.Errorf("Expected no CursorHold before updatetime, got %q", ed.EditorContent[0])
+	}
+	ed.LastKeyTime = time.Now().Add(-time.Second)
+	ProcessInput(ed, terminal.KeyNull)
+	ProcessInput(ed, terminal.KeyNull)
+	if ed.EditorContent[0] != "ne" {
+		t.Errorf("Expected CursorHold to run once, got %q", ed.EditorContent[0])
+	}
+
+	feedKeys(ed, "i")
+	ed.LastKeyTime = time.Now().Add(-time.Second)
+	ProcessInput(ed, terminal.KeyNull)
+	if !ed.BoolOption("expandtab") {
+		t.Errorf("Expected CursorHoldI to run in Insert mode")
+	}
+}
+
+func TestSessionCursor(t *testing.T) {
+	dir := t.TempDir()
+	file := dir + "/a.txt"
+	_ = os.WriteFile(file, []byte("one\n    second line\n"), 0644)
+	ed := newTestEditor([]string{""}, 0, 0)
+	if err := cmd.EditFile(ed, file); err != nil {
+		t.Fatalf("EditFile: %v", err)
+	}
+	ed.CursorY, ed.CursorX = 1, 11 // Past the indent, which :1 skips
+	if err := cmd.ExecuteCommand(ed, "mksession "+dir+"/s.vim"); err != nil {
+		t.Fatalf("mksession: %v", err)
+	}
+
+	restored := newTestEditor([]string{""}, 0, 0)
+	if err := cmd.LoadSession(restored, dir+"/s.vim"); err != nil {
+		t.Fatalf("LoadSession: %v", err)
+	}
+	if restored.CursorY != 1 || restored.CursorX != 11 {
+		t.Errorf("Expected cursor 1,11, got %d,%d", restored.CursorY, restored.CursorX)
+	}
+}
+
+func TestGlobalNormal(t *testing.T) {
+	content := []string{"a b", "x", "c d", "x y"}
+	ed := newTestEditor(slices.Clone(content), 0, 0)
+
+	feedKeys(ed, ":g/^x/normal Az\r")
+	expected := []string{"a b", "xz", "c d", "x yz"}
+	if !slices.Equal(ed.EditorContent, expected) || ed.CurrentMode != editor.ModeNormal {
+		t.Errorf("Expected %q in Normal mode, got %q in mode %v", expected, ed.EditorContent, ed.CurrentMode)
+	}
+	feedKeys(ed, ":v/x/normal daw\r")
+	expected = []string{"b", "xz", "d", "x yz"}
+	if !slices.Equal(ed.EditorContent, expected) {
+		t.Errorf("Expected %q, got %q", expected, ed.EditorContent)
+	}
+
+	// Each :global is undone as a whole.
+	feedKeys(ed, "u")
+	if !slices.Equal(ed.EditorContent, []string{"a b", "xz", "c d", "x yz"}) {
+		t.Errorf("Expected the :v undone, got %q", ed.EditorContent)
+	}
+	feedKeys(ed, "u")
+	if !slices.Equal(ed.EditorContent, content) {
+		t.Errorf("Expected the :g undone, got %q", ed.EditorContent)
+	}
+}
+
+func TestNotModifiableSoftTab(t *testing.T) {
+	ed := newTestEditor([]string{"a  b"}, 3, 0)
+	_ = ed.SetOption("softtabstop", 4)
+	_ = ed.SetLocalOption("modifiable", false)
+
+	feedKeys(ed, "i\t\x7f\x1b")
+	if ed.EditorContent[0] != "a  b" || ed.IsDirty {
+		t.Errorf("Expected the buffer unchanged, got %q", ed.EditorContent[0])
+	}
+}
+
+func TestLineMotions(t *testing.T) {
+	tests := []struct {
+		name            string
+		keys            string
+		expectedContent []string
+		expectedCursorY int
+	}{
+		{name: "G goes to the last line", keys: "G", expectedContent: []string{"a", "  b", "c", "d"}, expectedCursorY: 3},
+		{name: "count G goes to a line", keys: "2G", expectedContent: []string{"a", "  b", "c", "d"}, expectedCursorY: 1},
+		{name: "gg goes to the first line", keys: "Ggg", expectedContent: []string{"a", "  b", "c", "d"}},
+		{name: "dj deletes two lines", keys: "jdj", expectedContent: []string{"a", "d"}, expectedCursorY: 1},
+		{name: "d2k deletes three lines", keys: "Gd2k", expectedContent: []string{"a"}},
+		{name: "dG deletes to the end", keys: "jdG", expectedContent: []string{"a"}},
+		{name: "dgg deletes to the start", keys: "jjdgg", expectedContent: []string{"d"}},
+		{name: "dk on the first line does nothing", keys: "dk", expectedContent: []string{"a", "  b", "c", "d"}},
+		{name: ">j shifts two lines", keys: ">j", expectedContent: []string{"\ta", "\t  b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ed := newTestEditor([]string{"a", "  b", "c", "d"}, 0, 0)
+			feedKeys(ed, tt.keys)
+			if !slices.Equal(ed.EditorContent, tt.expectedContent) {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, ed.EditorContent)
+			}
+			if ed.CursorY != tt.expectedCursorY {
+				t.Errorf("Expected CursorY %d, got %d", tt.expectedCursorY, ed.CursorY)
+			}
+		})
+	}
+}
+
+func TestNotModifiableAutoPair(t *testing.T) {
+	for _, keys := range []string{"i\r\x1b", "i(\x1b", "i\x7f\x1b"} {
+		ed := newTestEditor([]string{"()"}, 1, 0)
+		_ = ed.SetOption("autopair", true)
+		_ = ed.SetLocalOption("modifiable", false)
+
+		feedKeys(ed, keys)
+		if !slices.Equal(ed.EditorContent, []string{"()"}) || ed.CursorY != 0 || ed.CursorX != 1 {
+			t.Errorf("%q: expected the buffer and cursor unchanged, got %q at %d,%d", keys, ed.EditorContent, ed.CursorY, ed.CursorX)
+		}
+	}
+}
+
+func TestSearch(t *testing.T) {
+	ed := newTestEditor([]string{"foo bar", "baz foo", "bar"}, 0, 0)
+	type pos struct{ x, y int }
+	tests := []struct {
+		keys string
+		want pos
+	}{
+		{"/foo\r", pos{4, 1}},
+		{"n", pos{0, 0}}, // Wraps around the end
+		{"N", pos{4, 1}},
+		{"?ba\r", pos{0, 1}},
+		{"n", pos{4, 0}},
+		{"2N", pos{0, 2}},
+		{"/\r", pos{4, 0}}, // The last pattern, forward
+		{"/b.r\r", pos{0, 2}},
+	}
+	for _, tt := range tests {
+		feedKeys(ed, tt.keys)
+		if got := (pos{ed.CursorX, ed.CursorY}); got != tt.want || ed.CurrentMode != editor.ModeNormal {
+			t.Errorf("After %q expected the cursor at %v in Normal mode, got %v in mode %v", tt.keys, tt.want, got, ed.CurrentMode)
+		}
+	}
+
+	want := []editor.HistoryEntry{{Text: "foo"}, {Text: "ba"}, {Text: "b.r"}}
+	got := ed.History[editor.HistorySearch]
+	if !slices.EqualFunc(got, want, func(a, b editor.HistoryEntry) bool { return a.Text == b.Text }) {
+		t.Errorf("Expected search history %v, got %v", want, got)
+	}
+	if len(ed.History[editor.HistoryCommand]) != 0 {
+		t.Errorf("Expected no command history, got %v", ed.History[editor.HistoryCommand])
+	}
+
+	// Up browses the search history, not the command history.
+	feedKeys(ed, ":s/x/y/e\r?"+string([]byte{terminal.KeyArrowUp}))
+	if ed.CommandBuffer != "b.r" || !ed.Searching() {
+		t.Errorf("Expected to browse to %q while searching, got %q", "b.r", ed.CommandBuffer)
+	}
+	feedKeys(ed, "\x1b")
+
+	feedKeys(ed, "/nothing\r")
+	if ed.CursorX != 0 || ed.CursorY != 2 || !strings.Contains(ed.StatusMessage, "Pattern not found") {
+		t.Errorf("Expected the cursor to stay and an error, got %d,%d and %q", ed.CursorX, ed.CursorY, ed.StatusMessage)
+	}
+}
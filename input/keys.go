@@ -0,0 +1,98 @@
+package input
+
+import (
+	"fmt"
+	"strings"
+
+	"goedit/terminal"
+)
+
+// keyNames are the names of special keys in <> notation, as used in key
+// mappings. The first name of a key is the one mappings are listed with.
+var keyNames = []struct {
+	name string
+	key  byte
+}{
+	{"CR", 13},
+	{"Enter", 13},
+	{"Return", 13},
+	{"Esc", terminal.KeyEsc},
+	{"Tab", 9},
+	{"BS", 127},
+	{"Space", ' '},
+	{"lt", '<'},
+	{"Bslash", '\\'},
+	{"Bar", '|'},
+	{"Up", terminal.KeyArrowUp},
+	{"Down", terminal.KeyArrowDown},
+	{"Left", terminal.KeyArrowLeft},
+	{"Right", terminal.KeyArrowRight},
+}
+
+// parseKeys turns a key sequence written in <> notation, such as
+// "<leader>w" or "<C-w>j", into the keys it stands for. <leader> stands for
+// leader and <Nop> for no keys. A "<" that does not start a known name is
+// taken literally.
+func parseKeys(notation, leader string) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(notation); i++ {
+		end := strings.IndexByte(notation[i:], '>')
+		if notation[i] != '<' || end < 0 {
+			b.WriteByte(notation[i])
+			continue
+		}
+		name := notation[i+1 : i+end]
+		switch key, ok := lookupKeyName(name); {
+		case ok:
+			b.WriteByte(key)
+		case strings.EqualFold(name, "leader"):
+			b.WriteString(leader)
+		case strings.EqualFold(name, "Nop"):
+		case len(name) == 3 && strings.EqualFold(name[:2], "C-"):
+			c := name[2] | 0x20 // Lower case
+			if c < 'a' || c > 'z' {
+				return "", fmt.Errorf("Invalid key: <%s>", name)
+			}
+			b.WriteByte(c - 'a' + 1)
+		default:
+			b.WriteByte(notation[i])
+			continue
+		}
+		i += end
+	}
+	return b.String(), nil
+}
+
+// lookupKeyName finds a special key by name, ignoring case.
+func lookupKeyName(name string) (byte, bool) {
+	for _, k := range keyNames {
+		if strings.EqualFold(k.name, name) {
+			return k.key, true
+		}
+	}
+	return 0, false
+}
+
+// keyNotation writes keys in <> notation, the reverse of parseKeys.
+func keyNotation(keys string) string {
+	var b strings.Builder
+	for i := 0; i < len(keys); i++ {
+		key := keys[i]
+		switch {
+		case key == '<':
+			b.WriteString("<lt>")
+		case key == 13 || key == 9 || key == 127 || key == terminal.KeyEsc || key >= terminal.KeyArrowUp && key <= terminal.KeyArrowRight:
+			for _, k := range keyNames {
+				if k.key == key {
+					fmt.Fprintf(&b, "<%s>", k.name)
+					break
+				}
+			}
+		case key < 32:
+			fmt.Fprintf(&b, "<C-%c>", key+'a'-1)
+		default:
+			b.WriteByte(key)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,116 @@
+package input
+
+import (
+	"errors"
+
+	"goedit/cmd"
+	"goedit/editor"
+	"goedit/terminal"
+)
+
+// maxMacroDepth limits how deeply macros and :normal may invoke each other,
+// so that a macro that calls itself without ever failing still stops.
+const maxMacroDepth = 100
+
+func init() {
+	cmd.RegisterCommand("normal", normalCommand)
+	cmd.RegisterCommand("norm", normalCommand)
+}
+
+// isMacroRegister reports whether name can hold a recorded macro: a named
+// register, or the unnamed one.
+func isMacroRegister(name byte) bool {
+	return name >= 'a' && name <= 'z' || name >= 'A' && name <= 'Z' || name == editor.UnnamedRegister
+}
+
+// recordMacroKey adds a typed key to the macro being recorded. Keys replayed
+// by a macro, '.' or :normal are not recorded again.
+func recordMacroKey(e *editor.Editor, key byte) {
+	if e.RecordingRegister != 0 && e.MacroDepth == 0 && !e.Dot.Replaying {
+		e.MacroKeys = append(e.MacroKeys, key)
+	}
+}
+
+// runMacro replays register name count times through ProcessInput. "@" names
+// the last macro run. Playback stops as soon as a command fails.
+func runMacro(e *editor.Editor, name byte, count int) keyResult {
+	if name == '@' {
+		name = e.LastMacro
+	}
+	if !isMacroRegister(name) {
+		return keysInvalid
+	}
+	if name >= 'A' && name <= 'Z' {
+		name += 'a' - 'A'
+	}
+	reg, ok := e.GetRegister(name)
+	if !ok || reg.Text == "" {
+		return keysInvalid
+	}
+	if e.MacroDepth >= maxMacroDepth {
+		e.SetStatusMessage("Macro recursion too deep")
+		return keysInvalid
+	}
+
+	if e.MacroDepth == 0 {
+		e.KeyError = false
+	}
+	e.LastMacro = name
+	e.PendingKeys = nil
+	e.MacroDepth++
+	defer func() { e.MacroDepth-- }()
+
+	for n := 0; n < max(count, 1); n++ {
+		for i := 0; i < len(reg.Text); i++ {
+			ProcessInput(e, reg.Text[i])
+			if e.KeyError {
+				e.MappedKeys = nil
+				return keysInvalid
+			}
+		}
+	}
+	flushMappedKeys(e)
+	return keysDone
+}
+
+// normalCommand implements :[range]norm[al] {keys}, which runs keys as Normal
+// mode input at the cursor, or at the start of each line of the range. A
+// command left incomplete is ended as if Esc had been typed.
+func normalCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if c.Args == "" {
+		return errors.New("Argument required")
+	}
+	if e.MacroDepth >= maxMacroDepth {
+		return errors.New("Macro recursion too deep")
+	}
+	e.MacroDepth++
+	defer func() { e.MacroDepth-- }()
+	if c.Bang {
+		e.NoRemapDepth++
+		defer func() { e.NoRemapDepth-- }()
+	}
+
+	run := func() {
+		e.CurrentMode = editor.ModeNormal
+		e.PendingKeys = nil
+		e.KeyError = false
+		for i := 0; i < len(c.Args) && !e.KeyError; i++ {
+			ProcessInput(e, c.Args[i])
+		}
+		flushMappedKeys(e)
+		if e.CurrentMode != editor.ModeNormal {
+			ProcessInput(e, terminal.KeyEsc)
+		}
+		e.PendingKeys = nil
+	}
+
+	if !c.HasRange {
+		run()
+		return nil
+	}
+	for line := c.Range.Start; line <= c.Range.End && line < len(e.EditorContent); line++ {
+		e.CursorY, e.CursorX = line, 0
+		run()
+	}
+	return nil
+}
@@ -0,0 +1,294 @@
+package input
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+// maxMapDepth limits how deeply mappings may run other mappings, so that
+// mappings that map to each other still stop.
+const maxMapDepth = 1000
+
+func init() {
+	for _, c := range mapCommands {
+		cmd.RegisterCommand(c.name, mapCommand(c.modes, c.noremap))
+	}
+	for _, c := range unmapCommands {
+		cmd.RegisterCommand(c.name, unmapCommand(c.modes))
+	}
+}
+
+// mapModes are the modes of :map; the other commands name a single mode.
+var mapModes = []editor.Mode{editor.ModeNormal, editor.ModeVisual}
+
+var (
+	normalMode = []editor.Mode{editor.ModeNormal}
+	visualMode = []editor.Mode{editor.ModeVisual}
+	insertMode = []editor.Mode{editor.ModeInsert}
+)
+
+// mapCommands are the commands that add and list mappings.
+var mapCommands = []struct {
+	name    string
+	modes   []editor.Mode
+	noremap bool
+}{
+	{"map", mapModes, false},
+	{"nmap", normalMode, false},
+	{"nm", normalMode, false},
+	{"vmap", visualMode, false},
+	{"vm", visualMode, false},
+	{"xmap", visualMode, false},
+	{"imap", insertMode, false},
+	{"im", insertMode, false},
+	{"noremap", mapModes, true},
+	{"no", mapModes, true},
+	{"nnoremap", normalMode, true},
+	{"nn", normalMode, true},
+	{"vnoremap", visualMode, true},
+	{"vn", visualMode, true},
+	{"xnoremap", visualMode, true},
+	{"inoremap", insertMode, true},
+	{"ino", insertMode, true},
+}
+
+// unmapCommands are the commands that remove mappings.
+var unmapCommands = []struct {
+	name  string
+	modes []editor.Mode
+}{
+	{"unmap", mapModes},
+	{"unm", mapModes},
+	{"nunmap", normalMode},
+	{"nun", normalMode},
+	{"vunmap", visualMode},
+	{"vu", visualMode},
+	{"xunmap", visualMode},
+	{"iunmap", insertMode},
+	{"iu", insertMode},
+}
+
+// modeLetters name modes in mapping listings and ModeChanged patterns.
+var modeLetters = map[editor.Mode]string{
+	editor.ModeNormal:         "n",
+	editor.ModeVisual:         "v",
+	editor.ModeInsert:         "i",
+	editor.ModeReplace:        "R",
+	editor.ModeCommand:        "c",
+	editor.ModeFileNamePrompt: "c",
+}
+
+// mapCommand returns the implementation of a command such as :nnoremap
+// {lhs} {rhs}, which maps lhs to rhs in modes. Both are written in <>
+// notation. With only {lhs} the mappings starting with it are listed, and
+// with no arguments all the mappings of the modes. A "!" after :map or
+// :noremap makes the mapping for Insert mode.
+func mapCommand(modes []editor.Mode, noremap bool) cmd.CommandFunc {
+	return func(e *editor.Editor, c cmd.ExCommand) error {
+		modes := modes
+		if c.Bang {
+			modes = insertMode
+		}
+		lhs, rhs, _ := strings.Cut(c.Args, " ")
+		leader := e.StringOption("mapleader")
+		keys, err := parseKeys(lhs, leader)
+		if err != nil {
+			return err
+		}
+		rhs = strings.TrimLeft(rhs, " ")
+		if rhs == "" {
+			return listMappings(e, modes, keys)
+		}
+		rhsKeys, err := parseKeys(rhs, leader)
+		if err != nil {
+			return err
+		}
+		for _, mode := range modes {
+			e.Map(mode, editor.KeyMapping{Keys: keys, RHS: rhsKeys, Noremap: noremap})
+		}
+		return nil
+	}
+}
+
+// unmapCommand returns the implementation of a command such as :nunmap
+// {lhs}, which removes the mapping of lhs in modes.
+func unmapCommand(modes []editor.Mode) cmd.CommandFunc {
+	return func(e *editor.Editor, c cmd.ExCommand) error {
+		modes := modes
+		if c.Bang {
+			modes = insertMode
+		}
+		if c.Args == "" {
+			return errors.New("Argument required")
+		}
+		keys, err := parseKeys(strings.TrimRight(c.Args, " "), e.StringOption("mapleader"))
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, mode := range modes {
+			found = e.UnmapKeys(mode, keys) || found
+		}
+		if !found {
+			return errors.New("No such mapping")
+		}
+		return nil
+	}
+}
+
+// listMappings shows the mappings in modes whose keys start with prefix,
+// one per line with its mode, keys, "*" if it is not remapped, and what it
+// maps to.
+func listMappings(e *editor.Editor, modes []editor.Mode, prefix string) error {
+	var lines []string
+	for _, mode := range modes {
+		for _, m := range e.Mappings(mode, prefix) {
+			rhs := keyNotation(m.RHS)
+			switch {
+			case m.Func != nil:
+				rhs = "<function>"
+			case m.RHS == "":
+				rhs = "<Nop>"
+			}
+			noremap := " "
+			if m.Noremap {
+				noremap = "*"
+			}
+			lines = append(lines, fmt.Sprintf("%-3s%-12s %s %s", modeLetters[mode], keyNotation(m.Keys), noremap, rhs))
+		}
+	}
+	if len(lines) == 0 {
+		return errors.New("No mapping found")
+	}
+	e.ShowOutput(lines)
+	return nil
+}
+
+// mappable reports whether typed keys are checked against the key mappings:
+// at the start of a Normal or Visual mode command and anywhere in Insert
+// mode, but not for keys replayed by '.', which were mapped when typed, nor
+// for keys that are not to be remapped.
+func mappable(e *editor.Editor) bool {
+	if e.Dot.Replaying || e.NoRemapDepth > 0 {
+		return false
+	}
+	switch e.CurrentMode {
+	case editor.ModeNormal, editor.ModeVisual:
+		return len(e.PendingKeys) == 0
+	case editor.ModeInsert:
+		return true
+	}
+	return false
+}
+
+// processMappedKey handles a key against the key mappings of the current
+// mode. While the keys typed so far start a longer mapping they are held
+// back, until more keys tell which mapping was meant or timeoutlen passes;
+// when they complete a mapping it runs. Keys that turn out not to be mapped
+// are handled as usual, except that the longest mapping they start with
+// still runs.
+func processMappedKey(e *editor.Editor, key byte) {
+	if len(e.MappedKeys) == 0 && !mappable(e) {
+		dispatchKey(e, key)
+		return
+	}
+	keys := string(append(e.MappedKeys, key))
+	m, found, longer := e.LookupMapping(e.CurrentMode, keys)
+	if longer {
+		e.MappedKeys = []byte(keys)
+		e.MappedKeysTime = time.Now()
+		return
+	}
+	e.MappedKeys = nil
+	if found {
+		runMapping(e, m)
+		return
+	}
+	resolveKeys(e, keys)
+}
+
+// resolveKeys handles keys that are not waiting for more: the longest
+// mapping they start with runs, or else the first key is taken as typed,
+// and the rest are looked at again.
+func resolveKeys(e *editor.Editor, keys string) {
+	n := 0
+	for i := len(keys); i > 0 && n == 0; i-- {
+		if m, found, _ := e.LookupMapping(e.CurrentMode, keys[:i]); found {
+			runMapping(e, m)
+			n = i
+		}
+	}
+	if n == 0 {
+		dispatchKey(e, keys[0])
+		n = 1
+	}
+	for i := n; i < len(keys); i++ {
+		processMappedKey(e, keys[i])
+	}
+}
+
+// flushMappedKeys stops waiting for more keys to complete a mapping and
+// handles the keys held back.
+func flushMappedKeys(e *editor.Editor) {
+	if len(e.MappedKeys) == 0 {
+		return
+	}
+	keys := string(e.MappedKeys)
+	e.MappedKeys = nil
+	resolveKeys(e, keys)
+}
+
+// checkMappingTimeout flushes the held back keys once they have waited
+// timeoutlen milliseconds for the rest of a mapping.
+func checkMappingTimeout(e *editor.Editor) {
+	timeout := time.Duration(e.IntOption("timeoutlen")) * time.Millisecond
+	if len(e.MappedKeys) > 0 && time.Since(e.MappedKeysTime) >= timeout {
+		flushMappedKeys(e)
+	}
+}
+
+// runMapping runs a mapping as a command of its own, which can be undone at
+// once: its function is called, or its keys are handled as if typed. The
+// keys are mapped again unless it is a noremap mapping, except that keys
+// that start with the mapping's own keys do not run it again at once.
+func runMapping(e *editor.Editor, m editor.KeyMapping) {
+	if e.MapDepth >= maxMapDepth {
+		e.SetStatusMessage("Recursive mapping")
+		e.KeyError = true
+		return
+	}
+	if e.MapDepth == 0 && e.MacroDepth == 0 {
+		e.KeyError = false
+	}
+	if betweenCommands(e) {
+		e.BeginUndoStep()
+	}
+	e.MapDepth++
+	switch {
+	case m.Func != nil:
+		m.Func()
+	case m.Noremap:
+		for i := 0; i < len(m.RHS) && !e.KeyError; i++ {
+			dispatchKey(e, m.RHS[i])
+		}
+	default:
+		rhs := m.RHS
+		if strings.HasPrefix(rhs, m.Keys) {
+			dispatchKey(e, rhs[0])
+			rhs = rhs[1:]
+		}
+		for i := 0; i < len(rhs) && !e.KeyError; i++ {
+			processMappedKey(e, rhs[i])
+		}
+		flushMappedKeys(e)
+	}
+	e.MapDepth--
+	if betweenCommands(e) {
+		e.EndUndoStep()
+	}
+}
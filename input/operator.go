@@ -0,0 +1,163 @@
+package input
+
+import (
+	"fmt"
+
+	"goedit/editor"
+	"goedit/terminal"
+)
+
+// runOperator completes an operator command (d, c, y, >, <, = or !) from the keys typed
+// after the operator: an optional count and then a text object such as "iw"
+// or a line motion such as "j" or "G".
+func runOperator(e *editor.Editor, op byte, count int, keys []byte) keyResult {
+	opCount, rest := parseCount(keys)
+	if len(rest) == 0 {
+		return keysPending
+	}
+	if rest[0] == terminal.KeyEsc {
+		return keysDone
+	}
+	if rest[0] == op { // dd, cc, yy, >>, <<, == and !! act on whole lines
+		applyOperator(e, op, lineRegion(e, max(count, 1)*max(opCount, 1)))
+		return keysDone
+	}
+	if rest[0] != 'i' && rest[0] != 'a' {
+		// A line motion makes the operator act on the lines from the
+		// cursor line to where the motion goes.
+		n := 0
+		if count > 0 || opCount > 0 {
+			n = max(count, 1) * max(opCount, 1)
+		}
+		line, result := lineMotionTarget(e, rest, n)
+		if result == keysDone {
+			r := editor.Region{
+				Start:    editor.Position{Line: min(line, e.CursorY)},
+				End:      editor.Position{Line: max(line, e.CursorY)},
+				Linewise: true,
+			}
+			e.CursorY = r.Start.Line
+			applyOperator(e, op, r)
+		}
+		return result
+	}
+	if len(rest) < 2 {
+		return keysPending
+	}
+
+	r, ok := e.SelectTextObject(rest[1], rest[0] == 'i', max(count, 1)*max(opCount, 1))
+	if !ok {
+		return keysInvalid
+	}
+	applyOperator(e, op, r)
+	return keysDone
+}
+
+// applyOperator yanks, deletes, changes, shifts or re-indents the text in r.
+// Deleted and changed text goes to the unnamed register, and c leaves the
+// editor in Insert mode. > and < shift the lines of r by one shiftwidth and
+// = re-indents them. ! starts a command line to filter the lines of r
+// through a shell command.
+func applyOperator(e *editor.Editor, op byte, r editor.Region) {
+	e.SetMark(editor.MarkChangeStart, r.Start)
+	e.SetMark(editor.MarkChangeEnd, r.End)
+	switch op {
+	case '!':
+		startFilter(e, r)
+		return
+	case '>', '<':
+		shiftLines(e, op, r, 1)
+		return
+	case '=':
+		e.ReindentLines(r.Start.Line, r.End.Line)
+		return
+	}
+	e.YankRegion(r)
+	switch op {
+	case 'y':
+		e.CursorY, e.CursorX = r.Start.Line, r.Start.Col
+	case 'd':
+		e.DeleteRegion(r)
+		e.SetMark(editor.MarkChangeEnd, r.Start)
+		e.EnsureCursorBounds()
+	case 'c':
+		if r.Linewise {
+			// Changing whole lines leaves one empty line to type into.
+			if r.End.Line > r.Start.Line {
+				e.DeleteRegion(editor.Region{Start: editor.Position{Line: r.Start.Line + 1}, End: r.End, Linewise: true})
+			}
+			e.DeleteRegion(charRegion(r.Start.Line, 0, len(e.EditorContent[r.Start.Line])-1))
+		} else {
+			e.DeleteRegion(r)
+		}
+		e.CurrentMode = editor.ModeInsert
+	}
+}
+
+// lineRegion returns the linewise region of count lines from the cursor line,
+// stopping at the end of the buffer.
+func lineRegion(e *editor.Editor, count int) editor.Region {
+	last := min(e.CursorY+max(count, 1)-1, len(e.EditorContent)-1)
+	return editor.Region{
+		Start:    editor.Position{Line: e.CursorY},
+		End:      editor.Position{Line: last},
+		Linewise: true,
+	}
+}
+
+// lineMotionTarget returns the line that the line motion at the start of
+// keys goes to from the cursor line: j or k (or the down and up arrows)
+// count lines down or up, and G or gg to line count, or without a count the
+// last or first line. The result is keysPending for an incomplete motion and
+// keysInvalid for keys that are not a line motion or that cannot move.
+func lineMotionTarget(e *editor.Editor, keys []byte, count int) (int, keyResult) {
+	last := len(e.EditorContent) - 1
+	switch keys[0] {
+	case 'j', terminal.KeyArrowDown:
+		if e.CursorY == last {
+			return 0, keysInvalid
+		}
+		return min(e.CursorY+max(count, 1), last), keysDone
+	case 'k', terminal.KeyArrowUp:
+		if e.CursorY == 0 {
+			return 0, keysInvalid
+		}
+		return max(e.CursorY-max(count, 1), 0), keysDone
+	case 'G':
+		if count == 0 {
+			return last, keysDone
+		}
+		return min(count-1, last), keysDone
+	case 'g':
+		if len(keys) < 2 {
+			return 0, keysPending
+		}
+		if keys[1] != 'g' {
+			return 0, keysInvalid
+		}
+		return min(max(count, 1)-1, last), keysDone
+	}
+	return 0, keysInvalid
+}
+
+// startFilter enters Command mode with the range of r's lines and "!" typed,
+// as Vim does for !{motion}: ":.!" or ":.,.+N!" from the cursor line.
+func startFilter(e *editor.Editor, r editor.Region) {
+	text := fmt.Sprintf("%d,%d!", r.Start.Line+1, r.End.Line+1)
+	switch {
+	case r.Start.Line == e.CursorY && r.End.Line == e.CursorY:
+		text = ".!"
+	case r.Start.Line == e.CursorY:
+		text = fmt.Sprintf(".,.+%d!", r.End.Line-r.Start.Line)
+	}
+	e.StartCommandLine(editor.ModeCommand, text)
+	e.SetStatusMessage("")
+}
+
+// shiftLines shifts the lines of r count levels right for > or left for <.
+func shiftLines(e *editor.Editor, op byte, r editor.Region, count int) {
+	if op == '<' {
+		count = -count
+	}
+	e.ShiftLines(r.Start.Line, r.End.Line, count)
+}
@@ -0,0 +1,82 @@
+package input
+
+import (
+	"strconv"
+
+	"goedit/editor"
+)
+
+// recordChangeKey adds key to the keys of the command being typed. A command
+// starts with the first key typed in Normal mode and lasts until the editor
+// is back in Normal mode with nothing pending, so it includes any Visual
+// selection and the text typed in Insert mode.
+func recordChangeKey(e *editor.Editor, key byte) {
+	if e.Dot.Replaying {
+		return
+	}
+	switch e.CurrentMode {
+	case editor.ModeNormal:
+		if len(e.PendingKeys) == 0 {
+			e.Dot.Keys = nil
+			e.Dot.StartTick = e.ChangeTick
+		}
+	case editor.ModeCommand, editor.ModeFileNamePrompt:
+		return
+	}
+	e.Dot.Keys = append(e.Dot.Keys, key)
+}
+
+// finishChange remembers the keys of a completed command as the last change
+// if the command modified the buffer. Commands that leave Normal mode for the
+// command line are never repeated.
+func finishChange(e *editor.Editor) {
+	if e.Dot.Replaying {
+		return
+	}
+	switch e.CurrentMode {
+	case editor.ModeCommand, editor.ModeFileNamePrompt:
+		e.Dot.Keys = nil
+		return
+	case editor.ModeNormal:
+		if len(e.PendingKeys) > 0 {
+			return
+		}
+	default:
+		return
+	}
+	if e.Dot.Keys != nil && e.ChangeTick != e.Dot.StartTick {
+		e.Dot.LastKeys = e.Dot.Keys
+	}
+	e.Dot.Keys = nil
+}
+
+// betweenCommands reports whether the editor is in Normal mode with no
+// command pending, outside any macro, mapping or '.' replay. Undo steps begin
+// and end here, so that everything a typed command does, including the text
+// typed in Insert mode and the changes made by a macro or mapping, is undone
+// at once.
+func betweenCommands(e *editor.Editor) bool {
+	return e.CurrentMode == editor.ModeNormal && len(e.PendingKeys) == 0 && e.MacroDepth == 0 && e.MapDepth == 0 && !e.Dot.Replaying
+}
+
+// repeatLastChange replays the last change through ProcessInput. A count
+// replaces the count the change was originally typed with.
+func repeatLastChange(e *editor.Editor, count int) {
+	keys := e.Dot.LastKeys
+	if len(keys) == 0 {
+		return
+	}
+	if count > 0 {
+		_, rest := parseCount(keys)
+		keys = append([]byte(strconv.Itoa(count)), rest...)
+		e.Dot.LastKeys = keys
+	}
+
+	e.PendingKeys = nil
+	e.Dot.Replaying = true
+	for _, key := range keys {
+		ProcessInput(e, key)
+	}
+	e.Dot.Replaying = false
+	e.Dot.Keys = nil // The replay is not itself a change to remember
+}
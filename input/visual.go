@@ -0,0 +1,123 @@
+package input
+
+import (
+	"goedit/editor"
+	"goedit/terminal"
+)
+
+// startVisual enters Visual mode with the selection anchored at the cursor.
+func startVisual(e *editor.Editor, linewise bool) {
+	e.CurrentMode = editor.ModeVisual
+	e.VisualStart = editor.Position{Line: e.CursorY, Col: e.CursorX}
+	e.VisualLinewise = linewise
+}
+
+// processVisualModeInput handles input when in Visual mode. Like Normal mode,
+// keys are collected in e.PendingKeys until they form a complete command.
+func processVisualModeInput(e *editor.Editor, key byte) {
+	e.PendingKeys = append(e.PendingKeys, key)
+	switch runVisualCommand(e, e.PendingKeys) {
+	case keysPending:
+		return
+	case keysInvalid:
+		e.KeyError = true
+	}
+	e.PendingKeys = nil
+}
+
+// runVisualCommand executes the Visual mode command spelled by keys.
+func runVisualCommand(e *editor.Editor, keys []byte) keyResult {
+	count, rest := parseCount(keys)
+	if len(rest) == 0 {
+		return keysPending
+	}
+
+	switch rest[0] {
+	case terminal.KeyEsc:
+		exitVisual(e)
+	case 'v', 'V':
+		if e.VisualLinewise == (rest[0] == 'V') {
+			exitVisual(e)
+		} else {
+			e.VisualLinewise = rest[0] == 'V'
+		}
+	case 'h', 'j', 'k', 'l', terminal.KeyArrowLeft, terminal.KeyArrowDown, terminal.KeyArrowUp, terminal.KeyArrowRight:
+		moveCursor(e, rest[0], count)
+	case 'o':
+		cursor := editor.Position{Line: e.CursorY, Col: e.CursorX}
+		e.CursorY, e.CursorX = e.VisualStart.Line, e.VisualStart.Col
+		e.VisualStart = cursor
+	case 'i', 'a':
+		if len(rest) < 2 {
+			return keysPending
+		}
+		return selectTextObject(e, rest[1], rest[0] == 'i', max(count, 1))
+	case 'd', 'x':
+		applyOperator(e, 'd', exitVisual(e))
+	case 'c', 's':
+		applyOperator(e, 'c', exitVisual(e))
+	case 'y':
+		applyOperator(e, 'y', exitVisual(e))
+	case '>', '<':
+		r := exitVisual(e)
+		e.SetMark(editor.MarkChangeStart, r.Start)
+		e.SetMark(editor.MarkChangeEnd, r.End)
+		shiftLines(e, rest[0], r, max(count, 1))
+	case '=':
+		applyOperator(e, '=', exitVisual(e))
+	case ':', '!':
+		exitVisual(e)
+		text := "'<,'>"
+		if rest[0] == '!' {
+			text += "!"
+		}
+		e.StartCommandLine(editor.ModeCommand, text)
+		e.SetStatusMessage("")
+	default:
+		return keysInvalid
+	}
+	return keysDone
+}
+
+// exitVisual returns to Normal mode, remembering the selection in the '< and
+// '> marks, and returns the selected region.
+func exitVisual(e *editor.Editor) editor.Region {
+	r := e.VisualRegion()
+	e.SetMark(editor.MarkVisualStart, r.Start)
+	e.SetMark(editor.MarkVisualEnd, r.End)
+	e.CurrentMode = editor.ModeNormal
+	return r
+}
+
+// selectTextObject sets the Visual selection to a text object. Repeating a
+// bracket, quote or tag object that is already selected selects the next
+// enclosing pair.
+func selectTextObject(e *editor.Editor, obj byte, inner bool, count int) keyResult {
+	r, ok := e.SelectTextObject(obj, inner, count)
+	if ok && editor.IsPairObject(obj) && sameSelection(e, r) {
+		r, ok = e.SelectTextObject(obj, inner, count+1)
+	}
+	if !ok {
+		return keysInvalid
+	}
+	if r.IsEmpty() {
+		return keysDone
+	}
+
+	e.VisualLinewise = r.Linewise
+	e.VisualStart = r.Start
+	e.CursorY, e.CursorX = r.End.Line, r.End.Col
+	if r.Linewise {
+		e.VisualStart.Col, e.CursorX = 0, 0
+	}
+	return keysDone
+}
+
+// sameSelection reports whether r is what Visual mode currently selects.
+func sameSelection(e *editor.Editor, r editor.Region) bool {
+	sel := e.VisualRegion()
+	if r.Linewise || sel.Linewise {
+		return r.Linewise == sel.Linewise && r.Start.Line == sel.Start.Line && r.End.Line == sel.End.Line
+	}
+	return r == sel
+}
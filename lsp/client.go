@@ -0,0 +1,213 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+)
+
+// Client speaks the Language Server Protocol to a single server.
+type Client struct {
+	Conn     *Conn
+	SyncKind int // How the server wants document changes sent, from its capabilities
+
+	close func() error // Releases the server's process or pipes
+
+	mu          sync.Mutex
+	diagnostics map[string][]Diagnostic // Latest published diagnostics by URI, until taken
+}
+
+// NewClient returns a client that reads the server's messages from r and
+// writes to w. closer, which may be nil, is called by Shutdown.
+func NewClient(r io.Reader, w io.Writer, closer func() error) *Client {
+	c := &Client{close: closer, diagnostics: make(map[string][]Diagnostic)}
+	c.Conn = NewConn(r, w, c.handle)
+	return c
+}
+
+// handle answers the server's requests and records its diagnostics.
+func (c *Client) handle(method string, params json.RawMessage) (any, error) {
+	switch method {
+	case "textDocument/publishDiagnostics":
+		var p struct {
+			URI         string       `json:"uri"`
+			Diagnostics []Diagnostic `json:"diagnostics"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		c.mu.Lock()
+		c.diagnostics[p.URI] = p.Diagnostics
+		c.mu.Unlock()
+	case "workspace/configuration":
+		// No settings are configured; answer each item with null.
+		var p struct {
+			Items []json.RawMessage `json:"items"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		return make([]any, len(p.Items)), nil
+	}
+	return nil, nil
+}
+
+// TakeDiagnostics returns the diagnostics published for uri since the last
+// call, and whether any were.
+func (c *Client) TakeDiagnostics(uri string) ([]Diagnostic, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	diags, ok := c.diagnostics[uri]
+	delete(c.diagnostics, uri)
+	return diags, ok
+}
+
+// Initialize performs the initialize handshake for a workspace rooted at
+// rootURI and records the server's sync kind.
+func (c *Client) Initialize(rootURI string) error {
+	params := map[string]any{
+		"processId": os.Getpid(),
+		"rootUri":   rootURI,
+		"capabilities": map[string]any{
+			"textDocument": map[string]any{
+				"synchronization":    map[string]any{"didSave": false},
+				"hover":              map[string]any{"contentFormat": []string{"plaintext", "markdown"}},
+				"completion":         map[string]any{"completionItem": map[string]any{"snippetSupport": false}},
+				"definition":         map[string]any{},
+				"references":         map[string]any{},
+				"rename":             map[string]any{},
+				"publishDiagnostics": map[string]any{},
+			},
+			"workspace": map[string]any{"configuration": true},
+		},
+		"workspaceFolders": []map[string]string{{"uri": rootURI, "name": URIPath(rootURI)}},
+	}
+	var result struct {
+		Capabilities struct {
+			TextDocumentSync json.RawMessage `json:"textDocumentSync"`
+		} `json:"capabilities"`
+	}
+	if err := c.Conn.Call("initialize", params, &result); err != nil {
+		return err
+	}
+
+	// textDocumentSync is either a kind or an options object.
+	c.SyncKind = SyncNone
+	syncCap := result.Capabilities.TextDocumentSync
+	if err := json.Unmarshal(syncCap, &c.SyncKind); err != nil {
+		var opts struct {
+			Change int `json:"change"`
+		}
+		if json.Unmarshal(syncCap, &opts) == nil {
+			c.SyncKind = opts.Change
+		}
+	}
+	return c.Conn.Notify("initialized", struct{}{})
+}
+
+// DidOpen tells the server that a document was opened with text.
+func (c *Client) DidOpen(uri, languageID string, version int, text string) error {
+	return c.Conn.Notify("textDocument/didOpen", map[string]any{
+		"textDocument": map[string]any{
+			"uri":        uri,
+			"languageId": languageID,
+			"version":    version,
+			"text":       text,
+		},
+	})
+}
+
+// DidChange sends changes that bring the document to version.
+func (c *Client) DidChange(uri string, version int, changes []TextDocumentContentChangeEvent) error {
+	return c.Conn.Notify("textDocument/didChange", map[string]any{
+		"textDocument":   map[string]any{"uri": uri, "version": version},
+		"contentChanges": changes,
+	})
+}
+
+// DidClose tells the server that a document was closed.
+func (c *Client) DidClose(uri string) error {
+	return c.Conn.Notify("textDocument/didClose", map[string]any{
+		"textDocument": map[string]any{"uri": uri},
+	})
+}
+
+// Definition returns the locations where the symbol at pos is defined.
+func (c *Client) Definition(uri string, pos Position) ([]Location, error) {
+	var raw json.RawMessage
+	if err := c.Conn.Call("textDocument/definition", positionParams(uri, pos), &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// References returns the locations where the symbol at pos is used,
+// including its declaration.
+func (c *Client) References(uri string, pos Position) ([]Location, error) {
+	params := struct {
+		textDocumentPositionParams
+		Context struct {
+			IncludeDeclaration bool `json:"includeDeclaration"`
+		} `json:"context"`
+	}{textDocumentPositionParams: positionParams(uri, pos)}
+	params.Context.IncludeDeclaration = true
+	var raw json.RawMessage
+	if err := c.Conn.Call("textDocument/references", params, &raw); err != nil {
+		return nil, err
+	}
+	return parseLocations(raw)
+}
+
+// Hover returns the documentation of the symbol at pos as text, or "" if
+// there is none.
+func (c *Client) Hover(uri string, pos Position) (string, error) {
+	var result *struct {
+		Contents json.RawMessage `json:"contents"`
+	}
+	if err := c.Conn.Call("textDocument/hover", positionParams(uri, pos), &result); err != nil || result == nil {
+		return "", err
+	}
+	return parseHover(result.Contents), nil
+}
+
+// Rename returns the edits that rename the symbol at pos to newName.
+func (c *Client) Rename(uri string, pos Position, newName string) (WorkspaceEdit, error) {
+	params := struct {
+		textDocumentPositionParams
+		NewName string `json:"newName"`
+	}{positionParams(uri, pos), newName}
+	var edit WorkspaceEdit
+	err := c.Conn.Call("textDocument/rename", params, &edit)
+	return edit, err
+}
+
+// Completion returns the completion candidates at pos.
+func (c *Client) Completion(uri string, pos Position) ([]CompletionItem, error) {
+	var raw json.RawMessage
+	if err := c.Conn.Call("textDocument/completion", positionParams(uri, pos), &raw); err != nil {
+		return nil, err
+	}
+	// The result is an array of items or a CompletionList.
+	var items []CompletionItem
+	if json.Unmarshal(raw, &items) == nil {
+		return items, nil
+	}
+	var list struct {
+		Items []CompletionItem `json:"items"`
+	}
+	err := json.Unmarshal(raw, &list)
+	return list.Items, err
+}
+
+// Shutdown asks the server to exit and releases it.
+func (c *Client) Shutdown() error {
+	err := c.Conn.Call("shutdown", nil, nil)
+	_ = c.Conn.Notify("exit", nil)
+	if c.close != nil {
+		if cerr := c.close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
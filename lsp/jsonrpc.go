@@ -0,0 +1,211 @@
+package lsp
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultTimeout is how long a request waits for the server to answer.
+const DefaultTimeout = 5 * time.Second
+
+// ErrClosed is returned by calls on a connection whose server has gone away.
+var ErrClosed = errors.New("language server connection closed")
+
+// Handler answers a request or notification sent by the server. The result
+// of a notification is ignored. Handlers run on the connection's reading
+// goroutine and must not block.
+type Handler func(method string, params json.RawMessage) (any, error)
+
+// ResponseError is an error returned by the server for a request.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return e.Message
+}
+
+// message is any JSON-RPC 2.0 request, notification or response.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// Conn is a JSON-RPC 2.0 connection whose messages are framed with
+// Content-Length headers, as the Language Server Protocol requires.
+type Conn struct {
+	Timeout time.Duration // How long Call waits for a response
+
+	w       io.Writer
+	handler Handler
+	writeMu sync.Mutex
+
+	mu      sync.Mutex
+	nextID  int
+	pending map[int]chan *message
+	done    chan struct{}
+}
+
+// NewConn starts reading messages from r and returns a connection that
+// writes to w. Requests and notifications from the other side go to handler.
+func NewConn(r io.Reader, w io.Writer, handler Handler) *Conn {
+	c := &Conn{
+		Timeout: DefaultTimeout,
+		w:       w,
+		handler: handler,
+		pending: make(map[int]chan *message),
+		done:    make(chan struct{}),
+	}
+	go c.readLoop(bufio.NewReader(r))
+	return c
+}
+
+// Call sends a request and decodes the response into result, which may be
+// nil to discard it.
+func (c *Conn) Call(method string, params, result any) error {
+	c.mu.Lock()
+	c.nextID++
+	id := c.nextID
+	ch := make(chan *message, 1)
+	c.pending[id] = ch
+	c.mu.Unlock()
+	defer func() {
+		c.mu.Lock()
+		delete(c.pending, id)
+		c.mu.Unlock()
+	}()
+
+	rawID := json.RawMessage(strconv.Itoa(id))
+	if err := c.send(message{ID: &rawID, Method: method}, params); err != nil {
+		return err
+	}
+
+	select {
+	case resp := <-ch:
+		if resp.Error != nil {
+			return resp.Error
+		}
+		if result == nil || len(resp.Result) == 0 {
+			return nil
+		}
+		return json.Unmarshal(resp.Result, result)
+	case <-c.done:
+		return ErrClosed
+	case <-time.After(c.Timeout):
+		return fmt.Errorf("%s: language server did not respond", method)
+	}
+}
+
+// Notify sends a notification, which gets no response.
+func (c *Conn) Notify(method string, params any) error {
+	return c.send(message{Method: method}, params)
+}
+
+// send writes msg with params as its parameters.
+func (c *Conn) send(msg message, params any) error {
+	if params != nil {
+		raw, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+		msg.Params = raw
+	}
+	return c.write(msg)
+}
+
+// write frames and writes a single message.
+func (c *Conn) write(msg message) error {
+	msg.JSONRPC = "2.0"
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(body)
+	return err
+}
+
+// readLoop dispatches incoming messages until the reader fails.
+func (c *Conn) readLoop(r *bufio.Reader) {
+	defer close(c.done)
+	for {
+		msg, err := readMessage(r)
+		if err != nil {
+			return
+		}
+		switch {
+		case msg.Method == "" && msg.ID != nil: // Response
+			id, err := strconv.Atoi(string(*msg.ID))
+			if err != nil {
+				continue
+			}
+			c.mu.Lock()
+			ch := c.pending[id]
+			c.mu.Unlock()
+			if ch != nil {
+				ch <- msg
+			}
+		case msg.ID != nil: // Request
+			c.respond(msg)
+		case c.handler != nil: // Notification
+			_, _ = c.handler(msg.Method, msg.Params)
+		}
+	}
+}
+
+// respond answers a request from the other side with the handler's result,
+// or a method-not-found error if there is no handler.
+func (c *Conn) respond(req *message) {
+	resp := message{ID: req.ID}
+	if c.handler == nil {
+		resp.Error = &ResponseError{Code: -32601, Message: "method not found: " + req.Method}
+		_ = c.write(resp)
+		return
+	}
+	result, err := c.handler(req.Method, req.Params)
+	if err == nil {
+		resp.Result, err = json.Marshal(result)
+	}
+	if err != nil {
+		resp.Result = nil
+		resp.Error = &ResponseError{Code: -32603, Message: err.Error()}
+	}
+	_ = c.write(resp)
+}
+
+// readMessage reads one framed message.
+func readMessage(r *bufio.Reader) (*message, error) {
+	header, err := textproto.NewReader(r).ReadMIMEHeader()
+	if err != nil {
+		return nil, err
+	}
+	length, err := strconv.Atoi(header.Get("Content-Length"))
+	if err != nil {
+		return nil, fmt.Errorf("bad Content-Length header: %w", err)
+	}
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, err
+	}
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
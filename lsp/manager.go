@@ -0,0 +1,476 @@
+// Package lsp connects the editor to language servers for diagnostics,
+// go-to-definition, hover, rename, references and completion.
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"sort"
+	"strings"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+// defaultServers are the commands tried for each filetype when they are
+// installed. More can be added with :lspserver.
+var defaultServers = map[string][]string{
+	"go":     {"gopls"},
+	"python": {"pyright-langserver", "--stdio"},
+}
+
+var errNoServer = errors.New("No language server")
+
+// Manager keeps the buffer in sync with the language server for its
+// filetype and provides the commands that use it.
+type Manager struct {
+	Servers map[string][]string // Server command for each filetype
+
+	editor  *editor.Editor
+	dial    func(command []string) (*Client, error) // Starts a server; replaced in tests
+	client  *Client
+	uri     string
+	version int
+	watched bool // Whether the change listener is installed
+}
+
+// NewManager returns a manager for e with the default servers that are
+// found on PATH.
+func NewManager(e *editor.Editor) *Manager {
+	m := &Manager{Servers: make(map[string][]string), editor: e, dial: startServer}
+	for ft, command := range defaultServers {
+		if _, err := exec.LookPath(command[0]); err == nil {
+			m.Servers[ft] = command
+		}
+	}
+	return m
+}
+
+// startServer runs command and connects to it over its standard input and
+// output.
+func startServer(command []string) (*Client, error) {
+	c := exec.Command(command[0], command[1:]...)
+	stdin, err := c.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := c.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err := c.Start(); err != nil {
+		return nil, err
+	}
+	return NewClient(stdout, stdin, func() error {
+		_ = stdin.Close()
+		return c.Wait()
+	}), nil
+}
+
+// Attach starts the server for the buffer's filetype, if one is configured,
+// and opens the buffer in it. Any server already attached is shut down.
+func (m *Manager) Attach() error {
+	m.Shutdown()
+	e := m.editor
+	ft := e.StringOption("filetype")
+	command := m.Servers[ft]
+	if len(command) == 0 || e.Filename == "" {
+		return nil
+	}
+
+	client, err := m.dial(command)
+	if err != nil {
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+	root, err := os.Getwd()
+	if err != nil {
+		root = "/"
+	}
+	if err := client.Initialize(FileURI(root)); err != nil {
+		_ = client.Shutdown()
+		return fmt.Errorf("%s: %w", command[0], err)
+	}
+
+	m.client = client
+	m.uri = FileURI(e.Filename)
+	m.version = 1
+	if !m.watched {
+		e.ChangeListeners = append(e.ChangeListeners, m.sync)
+		e.OmniFunc = m.complete
+		m.watched = true
+	}
+	return client.DidOpen(m.uri, ft, m.version, joinLines(e.EditorContent))
+}
+
+// Shutdown stops the attached server, if any.
+func (m *Manager) Shutdown() {
+	if m.client == nil {
+		return
+	}
+	_ = m.client.DidClose(m.uri)
+	_ = m.client.Shutdown()
+	m.client = nil
+	m.editor.SetSigns("diagnostics", nil)
+}
+
+// joinLines returns lines as document text, each ending with a newline.
+func joinLines(lines []string) string {
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+// sync sends the server a change to the buffer, as the lines it replaced
+// and the lines now in their place, or as the whole text if the server does
+// not accept ranges. It is called after every change.
+func (m *Manager) sync(c editor.LineChange) {
+	if m.client == nil || m.client.SyncKind == SyncNone || c.Old == 0 && c.New == 0 {
+		return
+	}
+	lines := m.editor.EditorContent
+	change := TextDocumentContentChangeEvent{Text: joinLines(lines)}
+	if m.client.SyncKind == SyncIncremental {
+		change = TextDocumentContentChangeEvent{
+			Range: &Range{Start: Position{Line: c.Line}, End: Position{Line: c.Line + c.Old}},
+			Text:  joinLines(lines[c.Line : c.Line+c.New]),
+		}
+	}
+	m.version++
+	_ = m.client.DidChange(m.uri, m.version, []TextDocumentContentChangeEvent{change})
+}
+
+// Poll shows any diagnostics the server has published since the last call
+// as signs. It is called from the main loop.
+func (m *Manager) Poll() {
+	if m.client == nil {
+		return
+	}
+	diags, ok := m.client.TakeDiagnostics(m.uri)
+	if !ok {
+		return
+	}
+	// Show the most severe diagnostic of each line.
+	sort.SliceStable(diags, func(i, j int) bool {
+		return severityRank(diags[i].Severity) < severityRank(diags[j].Severity)
+	})
+	signs := make([]editor.Sign, 0, len(diags))
+	for _, d := range diags {
+		signs = append(signs, editor.Sign{
+			Line:    d.Range.Start.Line,
+			Text:    severityText(d.Severity),
+			Message: d.Message,
+		})
+	}
+	m.editor.SetSigns("diagnostics", signs)
+}
+
+// severityRank orders severities from most to least severe; servers may
+// leave the severity out, which counts as an error.
+func severityRank(severity int) int {
+	if severity == 0 {
+		return SeverityError
+	}
+	return severity
+}
+
+// severityText returns the sign shown for a diagnostic severity.
+func severityText(severity int) string {
+	switch severity {
+	case SeverityWarning:
+		return "W"
+	case SeverityInformation:
+		return "I"
+	case SeverityHint:
+		return "H"
+	}
+	return "E"
+}
+
+// cursorPosition returns the cursor position in protocol terms.
+func (m *Manager) cursorPosition() Position {
+	e := m.editor
+	line := ""
+	if e.CursorY < len(e.EditorContent) {
+		line = e.EditorContent[e.CursorY]
+	}
+	return Position{Line: e.CursorY, Character: UTF16Col(line, e.CursorX)}
+}
+
+// bufferPosition converts a protocol position to a buffer position.
+func (m *Manager) bufferPosition(p Position) editor.Position {
+	content := m.editor.EditorContent
+	if p.Line >= len(content) {
+		return editor.Position{Line: len(content), Col: 0}
+	}
+	return editor.Position{Line: p.Line, Col: ByteCol(content[p.Line], p.Character)}
+}
+
+// complete provides Ctrl-X Ctrl-O completion: the column where the
+// completed text starts and the candidates.
+func (m *Manager) complete() (int, []string) {
+	e := m.editor
+	if m.client == nil || e.CursorY >= len(e.EditorContent) {
+		return 0, nil
+	}
+	items, err := m.client.Completion(m.uri, m.cursorPosition())
+	if err != nil {
+		e.SetStatusMessage(err.Error())
+		return 0, nil
+	}
+
+	line := e.EditorContent[e.CursorY]
+	start := min(e.CursorX, len(line))
+	for start > 0 && isIdentChar(line[start-1]) {
+		start--
+	}
+	var words []string
+	for _, item := range items {
+		if item.TextEdit != nil && item.TextEdit.Range.Start.Line == e.CursorY {
+			start = min(start, ByteCol(line, item.TextEdit.Range.Start.Character))
+		}
+		if text := item.Text(); text != "" && !slices.Contains(words, text) {
+			words = append(words, text)
+		}
+	}
+	return start, words
+}
+
+// isIdentChar reports whether c can be part of an identifier.
+func isIdentChar(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c >= 0x80
+}
+
+// RegisterCommands adds the Ex commands that use the language server:
+// :definition, :hover, :rename, :references and :lspserver.
+func (m *Manager) RegisterCommands() {
+	cmd.RegisterCommand("definition", m.definitionCommand)
+	cmd.RegisterCommand("hover", m.hoverCommand)
+	cmd.RegisterCommand("rename", m.renameCommand)
+	cmd.RegisterCommand("references", m.referencesCommand)
+	cmd.RegisterCommand("lspserver", m.serverCommand)
+}
+
+// definitionCommand jumps to the definition of the symbol under the cursor.
+func (m *Manager) definitionCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if m.client == nil {
+		return errNoServer
+	}
+	locs, err := m.client.Definition(m.uri, m.cursorPosition())
+	if err != nil {
+		return err
+	}
+	if len(locs) == 0 {
+		return errors.New("No definition found")
+	}
+	loc := locs[0]
+	if loc.URI != m.uri {
+		p := loc.Range.Start
+		return fmt.Errorf("Definition is in another file: %s:%d:%d", URIPath(loc.URI), p.Line+1, p.Character+1)
+	}
+	e.PushJump()
+	p := m.bufferPosition(loc.Range.Start)
+	e.CursorY, e.CursorX = min(p.Line, len(e.EditorContent)-1), p.Col
+	return nil
+}
+
+// hoverCommand shows the documentation of the symbol under the cursor.
+func (m *Manager) hoverCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if m.client == nil {
+		return errNoServer
+	}
+	text, err := m.client.Hover(m.uri, m.cursorPosition())
+	if err != nil {
+		return err
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return errors.New("No information available")
+	}
+	e.ShowOutput(strings.Split(text, "\n"))
+	return nil
+}
+
+// referencesCommand lists the places that use the symbol under the cursor.
+func (m *Manager) referencesCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if m.client == nil {
+		return errNoServer
+	}
+	locs, err := m.client.References(m.uri, m.cursorPosition())
+	if err != nil {
+		return err
+	}
+	if len(locs) == 0 {
+		return errors.New("No references found")
+	}
+	lines := make([]string, len(locs))
+	for i, loc := range locs {
+		p := loc.Range.Start
+		lines[i] = fmt.Sprintf("%s:%d:%d", relativePath(URIPath(loc.URI)), p.Line+1, p.Character+1)
+	}
+	e.ShowOutput(lines)
+	return nil
+}
+
+// relativePath returns path relative to the working directory if it is
+// inside it.
+func relativePath(path string) string {
+	if wd, err := os.Getwd(); err == nil {
+		if rel, ok := strings.CutPrefix(path, wd+string(os.PathSeparator)); ok {
+			return rel
+		}
+	}
+	return path
+}
+
+// renameCommand renames the symbol under the cursor everywhere the server
+// finds it. Edits to the buffer are made in place; other files are
+// rewritten on disk. All the edits are checked first, so that nothing is
+// changed if any of them does not apply. The files changed are listed.
+func (m *Manager) renameCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if m.client == nil {
+		return errNoServer
+	}
+	name := strings.TrimSpace(c.Args)
+	if name == "" {
+		return errors.New("Argument required")
+	}
+	edit, err := m.client.Rename(m.uri, m.cursorPosition(), name)
+	if err != nil {
+		return err
+	}
+	edits := edit.Edits()
+	if len(edits) == 0 {
+		return errors.New("Nothing to rename")
+	}
+	uris := make([]string, 0, len(edits))
+	for uri := range edits {
+		uris = append(uris, uri)
+	}
+	sort.Strings(uris)
+
+	texts := make(map[string]string) // New text of each file other than the buffer
+	for _, uri := range uris {
+		path := URIPath(uri)
+		text := joinLines(e.EditorContent)
+		if uri != m.uri {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return err
+			}
+			text = string(data)
+		}
+		newText, err := applyTextEdits(text, edits[uri])
+		if err != nil {
+			return fmt.Errorf("%s: %v", relativePath(path), err)
+		}
+		if uri != m.uri {
+			texts[path] = newText
+		}
+	}
+
+	names := make([]string, len(uris))
+	for i, uri := range uris {
+		path := URIPath(uri)
+		names[i] = relativePath(path)
+		if uri == m.uri {
+			m.applyEdits(edits[uri])
+			continue
+		}
+		if err := os.WriteFile(path, []byte(texts[path]), 0644); err != nil {
+			return err
+		}
+	}
+	e.SetStatusMessage(fmt.Sprintf("Renamed to %s in %s", name, strings.Join(names, ", ")))
+	return nil
+}
+
+// sortEdits orders edits from last to first, so that applying each leaves
+// the positions of the rest valid.
+func sortEdits(edits []TextEdit) {
+	sort.SliceStable(edits, func(i, j int) bool {
+		a, b := edits[i].Range.Start, edits[j].Range.Start
+		return a.Line > b.Line || a.Line == b.Line && a.Character > b.Character
+	})
+}
+
+// applyEdits applies edits to the buffer.
+func (m *Manager) applyEdits(edits []TextEdit) {
+	sortEdits(edits)
+	for _, edit := range edits {
+		start := m.bufferPosition(edit.Range.Start)
+		end := m.bufferPosition(edit.Range.End)
+		m.editor.ReplaceText(start, end, edit.NewText)
+	}
+}
+
+// applyTextEdits returns text with edits applied. It returns an error if an
+// edit is outside the text, ends before it starts or overlaps another.
+func applyTextEdits(text string, edits []TextEdit) (string, error) {
+	lines := strings.SplitAfter(text, "\n")
+	offset := func(p Position) (int, error) {
+		if p.Line < 0 || p.Line >= len(lines) {
+			return 0, fmt.Errorf("Edit at line %d is outside the file", p.Line+1)
+		}
+		line := strings.TrimSuffix(lines[p.Line], "\n")
+		if p.Character < 0 || p.Character > UTF16Col(line, len(line)) {
+			return 0, fmt.Errorf("Edit at line %d, column %d is outside the line", p.Line+1, p.Character+1)
+		}
+		off := 0
+		for _, l := range lines[:p.Line] {
+			off += len(l)
+		}
+		return off + ByteCol(line, p.Character), nil
+	}
+	sortEdits(edits)
+	next := len(text) // Start of the edit after this one
+	for _, edit := range edits {
+		start, err := offset(edit.Range.Start)
+		if err != nil {
+			return "", err
+		}
+		end, err := offset(edit.Range.End)
+		if err != nil {
+			return "", err
+		}
+		switch {
+		case end < start:
+			return "", fmt.Errorf("Edit at line %d ends before it starts", edit.Range.Start.Line+1)
+		case end > next:
+			return "", fmt.Errorf("Overlapping edits at line %d", edit.Range.Start.Line+1)
+		}
+		text = text[:start] + edit.NewText + text[end:]
+		next = start
+	}
+	return text, nil
+}
+
+// serverCommand sets the server command for a filetype:
+// ":lspserver {filetype} {command} [args...]". With only a filetype it
+// shows the command. Setting the server for the buffer's filetype restarts
+// it.
+func (m *Manager) serverCommand(e *editor.Editor, c cmd.ExCommand) error {
+	fields := strings.Fields(c.Args)
+	if len(fields) == 0 {
+		return errors.New("Argument required")
+	}
+	ft := fields[0]
+	if len(fields) == 1 {
+		command, ok := m.Servers[ft]
+		if !ok {
+			return fmt.Errorf("No language server for %s", ft)
+		}
+		e.SetStatusMessage(ft + ": " + strings.Join(command, " "))
+		return nil
+	}
+	m.Servers[ft] = fields[1:]
+	if ft == e.StringOption("filetype") && e.Filename != "" && m.client != nil {
+		return m.Attach()
+	}
+	return nil
+}
@@ -0,0 +1,333 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+// fakeServer is an in-process language server. It keeps the text of the
+// open document up to date from didOpen and didChange, and answers other
+// requests from responses.
+type fakeServer struct {
+	conn *Conn
+
+	mu        sync.Mutex
+	text      string
+	version   int
+	responses map[string]any // Result for each request method
+}
+
+func (s *fakeServer) handle(method string, params json.RawMessage) (any, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch method {
+	case "initialize":
+		return map[string]any{"capabilities": map[string]any{"textDocumentSync": SyncIncremental}}, nil
+	case "textDocument/didOpen":
+		var p struct {
+			TextDocument struct {
+				Text    string `json:"text"`
+				Version int    `json:"version"`
+			} `json:"textDocument"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		s.text, s.version = p.TextDocument.Text, p.TextDocument.Version
+	case "textDocument/didChange":
+		var p struct {
+			TextDocument struct {
+				Version int `json:"version"`
+			} `json:"textDocument"`
+			ContentChanges []TextDocumentContentChangeEvent `json:"contentChanges"`
+		}
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, err
+		}
+		for _, change := range p.ContentChanges {
+			lines := strings.SplitAfter(s.text, "\n")
+			offset := func(pos Position) int {
+				off := 0
+				for i := 0; i < pos.Line && i < len(lines); i++ {
+					off += len(lines[i])
+				}
+				return off + pos.Character
+			}
+			start, end := offset(change.Range.Start), offset(change.Range.End)
+			s.text = s.text[:start] + change.Text + s.text[end:]
+		}
+		s.version = p.TextDocument.Version
+	case "test/text":
+		return s.text, nil
+	}
+	return s.responses[method], nil
+}
+
+// respond sets the result the server gives for method.
+func (s *fakeServer) respond(method string, result any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[method] = result
+}
+
+// serverText returns the document text as the server has it, once it has
+// handled everything sent before.
+func serverText(t *testing.T, m *Manager) string {
+	t.Helper()
+	var text string
+	if err := m.client.Conn.Call("test/text", nil, &text); err != nil {
+		t.Fatalf("test/text: %v", err)
+	}
+	return text
+}
+
+// newTestManager returns a manager attached to a fake server for a Go file
+// with content.
+func newTestManager(t *testing.T, content string) (*Manager, *fakeServer, *editor.Editor) {
+	t.Helper()
+	e := editor.NewEditor(80, 24)
+	e.Filename = filepath.Join(t.TempDir(), "main.go")
+	e.LoadFile([]byte(content))
+	e.SetFiletype()
+
+	server := &fakeServer{responses: make(map[string]any)}
+	m := NewManager(e)
+	m.Servers = map[string][]string{"go": {"fake"}}
+	m.dial = func(command []string) (*Client, error) {
+		clientRead, serverWrite := io.Pipe()
+		serverRead, clientWrite := io.Pipe()
+		server.conn = NewConn(serverRead, serverWrite, server.handle)
+		return NewClient(clientRead, clientWrite, func() error {
+			_ = clientWrite.Close()
+			return serverWrite.Close()
+		}), nil
+	}
+	m.RegisterCommands()
+	if err := m.Attach(); err != nil {
+		t.Fatalf("Attach: %v", err)
+	}
+	t.Cleanup(m.Shutdown)
+	return m, server, e
+}
+
+func TestSync(t *testing.T) {
+	tests := []struct {
+		name string
+		edit func(e *editor.Editor)
+	}{
+		{"insert char", func(e *editor.Editor) { e.CursorY, e.CursorX = 1, 2; e.InsertChar('x') }},
+		{"split line", func(e *editor.Editor) { e.CursorY, e.CursorX = 0, 3; e.InsertNewline() }},
+		{"join lines", func(e *editor.Editor) { e.CursorY, e.CursorX = 1, 0; e.DeleteChar() }},
+		{"insert lines", func(e *editor.Editor) { e.InsertLines(3, []string{"a", "", "b"}) }},
+		{"append lines", func(e *editor.Editor) { e.InsertLines(len(e.EditorContent), []string{"end"}) }},
+		{"delete lines", func(e *editor.Editor) {
+			e.DeleteRegion(editor.Region{Start: editor.Position{Line: 1}, End: editor.Position{Line: 2}, Linewise: true})
+		}},
+		{"delete all", func(e *editor.Editor) {
+			e.DeleteRegion(editor.Region{End: editor.Position{Line: len(e.EditorContent) - 1}, Linewise: true})
+		}},
+		{"multi-line text", func(e *editor.Editor) { e.InsertText(editor.Position{Line: 2, Col: 1}, "one\ntwo\nthree") }},
+		{"replace text", func(e *editor.Editor) {
+			e.ReplaceText(editor.Position{Line: 2, Col: 5}, editor.Position{Line: 3, Col: 1}, "x\ny")
+		}},
+		{"delete across lines", func(e *editor.Editor) {
+			e.DeleteRegion(editor.Region{Start: editor.Position{Line: 0, Col: 4}, End: editor.Position{Line: 2, Col: 3}})
+		}},
+		{"join several", func(e *editor.Editor) { e.CursorY = 2; e.JoinLines(3) }},
+		{"replace lines", func(e *editor.Editor) { e.ReplaceLines(0, 4, nil) }},
+		{"undo", func(e *editor.Editor) {
+			e.BeginUndoStep()
+			e.ReplaceLines(1, 2, []string{"a", "b", "c"})
+			e.EndUndoStep()
+			_ = e.Undo(1)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, server, e := newTestManager(t, "package main\n\nfunc main() {\n\tprintln(1)\n}\n")
+			tt.edit(e)
+			want := joinLines(e.EditorContent)
+			if got := serverText(t, m); got != want {
+				t.Errorf("Expected server text %q, got %q", want, got)
+			}
+			server.mu.Lock()
+			defer server.mu.Unlock()
+			if server.version != m.version || m.version < 2 {
+				t.Errorf("Expected server version %d, got %d", m.version, server.version)
+			}
+		})
+	}
+}
+
+func TestDiagnosticSigns(t *testing.T) {
+	m, server, e := newTestManager(t, "package main\n\nfunc main() {\n\tx := 1\n}\n")
+	err := server.conn.Notify("textDocument/publishDiagnostics", map[string]any{
+		"uri": m.uri,
+		"diagnostics": []Diagnostic{
+			{Range: Range{Start: Position{Line: 3, Character: 1}}, Severity: SeverityWarning, Message: "unused"},
+			{Range: Range{Start: Position{Line: 3, Character: 1}}, Severity: SeverityError, Message: "declared and not used: x"},
+			{Range: Range{Start: Position{Line: 0}}, Severity: SeverityHint, Message: "hint"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("Notify: %v", err)
+	}
+	for deadline := time.Now().Add(time.Second); len(e.Signs) == 0 && time.Now().Before(deadline); {
+		time.Sleep(5 * time.Millisecond)
+		m.Poll()
+	}
+
+	tests := []struct {
+		line    int
+		text    string
+		message string
+	}{
+		{3, "E", "declared and not used: x"},
+		{0, "H", "hint"},
+	}
+	for _, tt := range tests {
+		sign, ok := e.SignAt(tt.line)
+		if !ok || sign.Text != tt.text || sign.Message != tt.message {
+			t.Errorf("Expected sign %q %q on line %d, got %+v", tt.text, tt.message, tt.line, sign)
+		}
+	}
+	if _, ok := e.SignAt(1); ok {
+		t.Errorf("Expected no sign on line 1")
+	}
+}
+
+func TestDefinitionCommand(t *testing.T) {
+	m, server, e := newTestManager(t, "package main\n\nfunc fooé() {}\n\nfunc main() { fooé() }\n")
+	e.CursorY, e.CursorX = 4, 14
+	server.respond("textDocument/definition", []Location{{URI: m.uri, Range: Range{Start: Position{Line: 2, Character: 9}}}})
+	if err := cmd.ExecuteCommand(e, "definition"); err != nil {
+		t.Fatalf("definition: %v", err)
+	}
+	if e.CursorY != 2 || e.CursorX != 10 { // é is one UTF-16 unit but two bytes
+		t.Errorf("Expected cursor at 2,10, got %d,%d", e.CursorY, e.CursorX)
+	}
+	if !e.JumpOlder(1) || e.CursorY != 4 {
+		t.Errorf("Expected the jump to be recorded")
+	}
+
+	server.respond("textDocument/definition", map[string]any{"uri": FileURI("/other.go"), "range": Range{}})
+	if err := cmd.ExecuteCommand(e, "definition"); err == nil || !strings.Contains(err.Error(), "other.go") {
+		t.Errorf("Expected error naming other.go, got %v", err)
+	}
+
+	server.respond("textDocument/definition", nil)
+	if err := cmd.ExecuteCommand(e, "definition"); err == nil {
+		t.Errorf("Expected error for no definition")
+	}
+}
+
+func TestHoverCommand(t *testing.T) {
+	_, server, e := newTestManager(t, "package main\n")
+	server.respond("textDocument/hover", map[string]any{
+		"contents": map[string]any{"kind": "markdown", "value": "func main()\n\nmain runs.\n"},
+	})
+	if err := cmd.ExecuteCommand(e, "hover"); err != nil {
+		t.Fatalf("hover: %v", err)
+	}
+	want := []string{"func main()", "", "main runs."}
+	if strings.Join(e.Output, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected output %q, got %q", want, e.Output)
+	}
+}
+
+func TestRenameCommand(t *testing.T) {
+	m, server, e := newTestManager(t, "package main\n\nfunc old() {}\n\nfunc main() { old(); old() }\n")
+	other := filepath.Join(filepath.Dir(e.Filename), "other.go")
+	if err := os.WriteFile(other, []byte("package main\n\nvar f = old\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	edit := func(line, char, n int) TextEdit {
+		return TextEdit{Range: Range{Start: Position{Line: line, Character: char}, End: Position{Line: line, Character: char + n}}, NewText: "renamed"}
+	}
+	server.respond("textDocument/rename", map[string]any{
+		"changes": map[string][]TextEdit{
+			m.uri:          {edit(2, 5, 3), edit(4, 14, 3), edit(4, 21, 3)},
+			FileURI(other): {edit(2, 8, 3)},
+		},
+	})
+	if err := cmd.ExecuteCommand(e, "rename renamed"); err != nil {
+		t.Fatalf("rename: %v", err)
+	}
+
+	want := "package main\n\nfunc renamed() {}\n\nfunc main() { renamed(); renamed() }\n"
+	if got := joinLines(e.EditorContent); got != want {
+		t.Errorf("Expected buffer %q, got %q", want, got)
+	}
+	if got := serverText(t, m); got != want {
+		t.Errorf("Expected server text %q, got %q", want, got)
+	}
+	data, _ := os.ReadFile(other)
+	if string(data) != "package main\n\nvar f = renamed\n" {
+		t.Errorf("Expected other.go to be renamed, got %q", data)
+	}
+	if !strings.Contains(e.StatusMessage, "main.go, ") || !strings.HasSuffix(e.StatusMessage, "other.go") {
+		t.Errorf("Expected the files changed listed, got %q", e.StatusMessage)
+	}
+
+	// An edit that does not apply changes nothing, in the buffer or on disk.
+	server.respond("textDocument/rename", map[string]any{
+		"changes": map[string][]TextEdit{
+			m.uri:          {edit(2, 5, 7)},
+			FileURI(other): {edit(2, 8, 7), edit(7, 0, 1)},
+		},
+	})
+	if err := cmd.ExecuteCommand(e, "rename again"); err == nil {
+		t.Errorf("Expected an error for an edit outside other.go")
+	}
+	if got := joinLines(e.EditorContent); got != want {
+		t.Errorf("Expected the buffer unchanged, got %q", got)
+	}
+	if data, _ := os.ReadFile(other); string(data) != "package main\n\nvar f = renamed\n" {
+		t.Errorf("Expected other.go unchanged, got %q", data)
+	}
+	if err := cmd.ExecuteCommand(e, "rename"); err == nil {
+		t.Errorf("Expected error for missing name")
+	}
+}
+
+func TestReferencesCommand(t *testing.T) {
+	m, server, e := newTestManager(t, "package main\n")
+	server.respond("textDocument/references", []Location{
+		{URI: m.uri, Range: Range{Start: Position{Line: 0, Character: 8}}},
+		{URI: FileURI("/src/other.go"), Range: Range{Start: Position{Line: 9, Character: 0}}},
+	})
+	if err := cmd.ExecuteCommand(e, "references"); err != nil {
+		t.Fatalf("references: %v", err)
+	}
+	want := []string{URIPath(m.uri) + ":1:9", "/src/other.go:10:1"}
+	if strings.Join(e.Output, "|") != strings.Join(want, "|") {
+		t.Errorf("Expected output %q, got %q", want, e.Output)
+	}
+}
+
+func TestOmniCompletion(t *testing.T) {
+	_, server, e := newTestManager(t, "package main\n\nfunc main() { fmt.Pr }\n")
+	e.CursorY, e.CursorX = 2, 20
+	server.respond("textDocument/completion", map[string]any{
+		"items": []CompletionItem{{Label: "Println"}, {Label: "Printf", InsertText: "Printf"}, {Label: "Println"}},
+	})
+	if !e.StartCompletion(editor.CompleteOmni) {
+		t.Fatalf("Expected completion to start")
+	}
+	if e.Completion.Start.Col != 18 {
+		t.Errorf("Expected completion to start at column 18, got %d", e.Completion.Start.Col)
+	}
+	if strings.Join(e.Completion.Items, ",") != "Println,Printf" {
+		t.Errorf("Expected items Println,Printf, got %v", e.Completion.Items)
+	}
+}
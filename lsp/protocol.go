@@ -0,0 +1,227 @@
+package lsp
+
+import (
+	"encoding/json"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf16"
+)
+
+// Diagnostic severities.
+const (
+	SeverityError       = 1
+	SeverityWarning     = 2
+	SeverityInformation = 3
+	SeverityHint        = 4
+)
+
+// Text document sync kinds, from the server's textDocumentSync capability.
+const (
+	SyncNone        = 0
+	SyncFull        = 1
+	SyncIncremental = 2
+)
+
+// Position is a zero-based line and UTF-16 code unit offset in a document.
+type Position struct {
+	Line      int `json:"line"`
+	Character int `json:"character"`
+}
+
+// Range is the span from Start up to, but not including, End.
+type Range struct {
+	Start Position `json:"start"`
+	End   Position `json:"end"`
+}
+
+// Location is a range in a document.
+type Location struct {
+	URI   string `json:"uri"`
+	Range Range  `json:"range"`
+}
+
+// locationLink is the alternative form of a definition result.
+type locationLink struct {
+	TargetURI            string `json:"targetUri"`
+	TargetSelectionRange Range  `json:"targetSelectionRange"`
+}
+
+// Diagnostic is an error or warning reported by the server.
+type Diagnostic struct {
+	Range    Range  `json:"range"`
+	Severity int    `json:"severity,omitempty"`
+	Source   string `json:"source,omitempty"`
+	Message  string `json:"message"`
+}
+
+// TextEdit replaces a range of a document with new text.
+type TextEdit struct {
+	Range   Range  `json:"range"`
+	NewText string `json:"newText"`
+}
+
+// WorkspaceEdit is a set of edits to several documents, in either of the
+// forms a server may send.
+type WorkspaceEdit struct {
+	Changes         map[string][]TextEdit `json:"changes,omitempty"`
+	DocumentChanges []struct {
+		TextDocument struct {
+			URI string `json:"uri"`
+		} `json:"textDocument"`
+		Edits []TextEdit `json:"edits"`
+	} `json:"documentChanges,omitempty"`
+}
+
+// Edits returns the edits of w by document URI.
+func (w WorkspaceEdit) Edits() map[string][]TextEdit {
+	edits := make(map[string][]TextEdit)
+	for uri, e := range w.Changes {
+		edits[uri] = append(edits[uri], e...)
+	}
+	for _, dc := range w.DocumentChanges {
+		uri := dc.TextDocument.URI
+		edits[uri] = append(edits[uri], dc.Edits...)
+	}
+	return edits
+}
+
+// CompletionItem is a single completion candidate.
+type CompletionItem struct {
+	Label      string    `json:"label"`
+	InsertText string    `json:"insertText,omitempty"`
+	TextEdit   *TextEdit `json:"textEdit,omitempty"`
+}
+
+// Text returns the text the item inserts.
+func (c CompletionItem) Text() string {
+	switch {
+	case c.TextEdit != nil:
+		return c.TextEdit.NewText
+	case c.InsertText != "":
+		return c.InsertText
+	}
+	return c.Label
+}
+
+// TextDocumentContentChangeEvent is a change sent with didChange. A nil
+// Range replaces the whole document.
+type TextDocumentContentChangeEvent struct {
+	Range *Range `json:"range,omitempty"`
+	Text  string `json:"text"`
+}
+
+// textDocumentPositionParams identifies a position in a document.
+type textDocumentPositionParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+	} `json:"textDocument"`
+	Position Position `json:"position"`
+}
+
+func positionParams(uri string, pos Position) textDocumentPositionParams {
+	var p textDocumentPositionParams
+	p.TextDocument.URI = uri
+	p.Position = pos
+	return p
+}
+
+// parseLocations decodes a definition or references result, which may be
+// null, a Location, or an array of Locations or LocationLinks.
+func parseLocations(raw json.RawMessage) ([]Location, error) {
+	raw = json.RawMessage(strings.TrimSpace(string(raw)))
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] != '[' {
+		var loc Location
+		err := json.Unmarshal(raw, &loc)
+		return []Location{loc}, err
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, err
+	}
+	var locs []Location
+	for _, item := range items {
+		var link locationLink
+		if err := json.Unmarshal(item, &link); err == nil && link.TargetURI != "" {
+			locs = append(locs, Location{URI: link.TargetURI, Range: link.TargetSelectionRange})
+			continue
+		}
+		var loc Location
+		if err := json.Unmarshal(item, &loc); err != nil {
+			return nil, err
+		}
+		locs = append(locs, loc)
+	}
+	return locs, nil
+}
+
+// parseHover decodes the contents of a hover result, which may be a
+// MarkupContent, a MarkedString or an array of MarkedStrings, into text.
+func parseHover(raw json.RawMessage) string {
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return s
+	}
+	var marked struct {
+		Value string `json:"value"`
+	}
+	if json.Unmarshal(raw, &marked) == nil && marked.Value != "" {
+		return marked.Value
+	}
+	var parts []json.RawMessage
+	if json.Unmarshal(raw, &parts) != nil {
+		return ""
+	}
+	var texts []string
+	for _, part := range parts {
+		if text := parseHover(part); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// ByteCol converts a UTF-16 offset into line to a byte column. Invalid UTF-8
+// bytes count as one unit each.
+func ByteCol(line string, character int) int {
+	units := 0
+	for i, r := range line {
+		if units >= character {
+			return i
+		}
+		units += utf16.RuneLen(r)
+	}
+	return len(line)
+}
+
+// UTF16Col converts a byte column of line to a UTF-16 offset.
+func UTF16Col(line string, col int) int {
+	units := 0
+	for i, r := range line {
+		if i >= col {
+			break
+		}
+		units += utf16.RuneLen(r)
+	}
+	return units
+}
+
+// FileURI returns the file:// URI of path.
+func FileURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	return (&url.URL{Scheme: "file", Path: filepath.ToSlash(path)}).String()
+}
+
+// URIPath returns the file path of a file:// URI.
+func URIPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
@@ -0,0 +1,86 @@
+package lsp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestColumnConversion(t *testing.T) {
+	tests := []struct {
+		line    string
+		byteCol int
+		utf16   int
+	}{
+		{"abc", 2, 2},
+		{"héllo", 3, 2},
+		{"a😀b", 5, 3},
+		{"a😀b", 6, 4},
+		{"abc", 3, 3},
+	}
+	for _, tt := range tests {
+		if got := UTF16Col(tt.line, tt.byteCol); got != tt.utf16 {
+			t.Errorf("UTF16Col(%q, %d): expected %d, got %d", tt.line, tt.byteCol, tt.utf16, got)
+		}
+		if got := ByteCol(tt.line, tt.utf16); got != tt.byteCol {
+			t.Errorf("ByteCol(%q, %d): expected %d, got %d", tt.line, tt.utf16, tt.byteCol, got)
+		}
+	}
+	if got := ByteCol("abc", 10); got != 3 {
+		t.Errorf("Expected ByteCol past the end to be 3, got %d", got)
+	}
+}
+
+func TestParseLocations(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want []string
+	}{
+		{`null`, nil},
+		{`{"uri":"file:///a.go","range":{"start":{"line":1,"character":2},"end":{"line":1,"character":3}}}`, []string{"file:///a.go"}},
+		{`[{"uri":"file:///a.go","range":{}},{"uri":"file:///b.go","range":{}}]`, []string{"file:///a.go", "file:///b.go"}},
+		{`[{"targetUri":"file:///c.go","targetRange":{},"targetSelectionRange":{}}]`, []string{"file:///c.go"}},
+	}
+	for _, tt := range tests {
+		locs, err := parseLocations(json.RawMessage(tt.raw))
+		if err != nil {
+			t.Errorf("parseLocations(%s): %v", tt.raw, err)
+			continue
+		}
+		if len(locs) != len(tt.want) {
+			t.Errorf("parseLocations(%s): expected %d locations, got %d", tt.raw, len(tt.want), len(locs))
+			continue
+		}
+		for i, loc := range locs {
+			if loc.URI != tt.want[i] {
+				t.Errorf("parseLocations(%s): expected URI %s, got %s", tt.raw, tt.want[i], loc.URI)
+			}
+		}
+	}
+}
+
+func TestParseHover(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{`"plain"`, "plain"},
+		{`{"kind":"markdown","value":"**bold**"}`, "**bold**"},
+		{`{"language":"go","value":"func f()"}`, "func f()"},
+		{`["one",{"language":"go","value":"two"}]`, "one\n\ntwo"},
+	}
+	for _, tt := range tests {
+		if got := parseHover(json.RawMessage(tt.raw)); got != tt.want {
+			t.Errorf("parseHover(%s): expected %q, got %q", tt.raw, tt.want, got)
+		}
+	}
+}
+
+func TestFileURI(t *testing.T) {
+	uri := FileURI("/tmp/a b.go")
+	if uri != "file:///tmp/a%20b.go" {
+		t.Errorf("Expected file:///tmp/a%%20b.go, got %s", uri)
+	}
+	if path := URIPath(uri); path != "/tmp/a b.go" {
+		t.Errorf("Expected /tmp/a b.go, got %s", path)
+	}
+}
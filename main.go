@@ -1,16 +1,40 @@
 package main
 
 import (
+	"fmt"
+	"io"
 	"log"
 	"os"
+	"path/filepath"
+	"time"
 
+	"goedit/cmd"
 	"goedit/editor"
 	"goedit/input"
+	"goedit/lsp"
+	"goedit/plugin"
 	"goedit/terminal"
 	"goedit/ui"
 )
 
 func main() {
+	args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "goedit: %v\nMore info with: goedit --help\n", err)
+		os.Exit(2)
+	}
+	if args.help {
+		fmt.Print(usage)
+		return
+	}
+	if args.version {
+		fmt.Println("goedit " + version)
+		return
+	}
+	if args.batch {
+		os.Exit(runBatch(args, os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	// Get initial terminal size for editor creation
 	width, height, err := terminal.GetSize()
 	if err != nil {
@@ -21,20 +45,95 @@ func main() {
 
 	// Initialize editor state using the new package
 	ed := editor.NewEditor(width, height)
+	servers := lsp.NewManager(ed)
+	servers.RegisterCommands()
+	attach := func(editor.Event) {
+		if err := servers.Attach(); err != nil {
+			ed.SetStatusMessage(err.Error())
+		}
+	}
+	ed.On(editor.EventBufRead, attach)
+	ed.On(editor.EventBufNewFile, attach)
+
+	// Apply the user's config before any file is loaded
+	if err := cmd.LoadConfig(ed); err != nil {
+		ed.SetStatusMessage(err.Error())
+	}
+	plugins := plugin.New(ed)
+	defer plugins.Close()
+	if err := plugins.LoadPlugins(plugin.Dir()); err != nil {
+		ed.SetStatusMessage(err.Error())
+	}
+
+	// Restore the histories and registers of earlier runs
+	statePath := editor.StatePath()
+	state := &editor.State{}
+	if statePath != "" {
+		if state, err = editor.ReadState(statePath); err != nil {
+			ed.SetStatusMessage(err.Error())
+		}
+		ed.RestoreState(state)
+	}
 
 	// Handle file loading
-	if len(os.Args) > 1 {
-		ed.Filename = os.Args[1]
-		content, err := os.ReadFile(ed.Filename)
+	if args.readOnly {
+		_ = ed.SetOption("readonly", true)
+	}
+	ed.ArgIndex = -1
+	for _, f := range args.files {
+		ed.ArgList = append(ed.ArgList, f.name)
+		if f.line > 0 { // Put the cursor there when the file is edited
+			if path, err := filepath.Abs(f.name); err == nil {
+				if ed.FilePositions == nil {
+					ed.FilePositions = make(map[string]editor.FileState)
+				}
+				ed.FilePositions[path] = editor.FileState{
+					Cursor: editor.Position{Line: f.line - 1, Col: max(f.col-1, 0)},
+					Time:   time.Now(),
+				}
+			}
+		}
+	}
+	if args.stdin {
+		content, err := io.ReadAll(os.Stdin)
 		if err != nil {
-			if !os.IsNotExist(err) { // Log errors other than file not found
-				log.Printf("Error opening file '%s': %v", ed.Filename, err)
-			} // If file doesn't exist, editor starts empty anyway
-		} else {
-			ed.LoadFile(content)
+			log.Fatalf("Error reading standard input: %v", err)
+		}
+		ed.LoadFile(content)
+		if err := terminal.UseTTY(); err != nil {
+			log.Fatalf("Error opening the terminal: %v", err)
+		}
+	} else if len(ed.ArgList) > 0 {
+		if err := cmd.EditArg(ed, 0); err != nil {
+			ed.SetStatusMessage(err.Error())
+		}
+	}
+	if args.loadSession {
+		if err := cmd.LoadSession(ed, args.session); err != nil {
+			ed.SetStatusMessage(err.Error())
+		}
+	}
+	for _, c := range args.commands {
+		if err := cmd.ExecuteCommand(ed, c); err != nil {
+			ed.SetStatusMessage(err.Error())
 		}
 	}
+	defer servers.Shutdown()
 
+	if !ed.ShouldQuit { // A -c command may have quit already
+		runInteractive(ed, servers)
+	}
+
+	ed.Fire(editor.EventVimLeavePre)
+	if statePath != "" {
+		if err := ed.WriteState(statePath, state); err != nil {
+			log.Printf("Error saving state: %v", err)
+		}
+	}
+}
+
+// runInteractive runs the editor in the terminal until it quits.
+func runInteractive(ed *editor.Editor, servers *lsp.Manager) {
 	// Enter raw mode and ensure it's disabled on exit
 	originalState, err := terminal.EnableRawMode()
 	if err != nil {
@@ -45,15 +144,12 @@ func main() {
 	ui.RefreshScreen(ed)
 
 	// Main input loop
-	for {
+	for !ed.ShouldQuit {
 		key := terminal.ReadKey()
 
 		input.ProcessInput(ed, key)
+		servers.Poll()
 
 		ui.RefreshScreen(ed)
-
-		if ed.ShouldQuit {
-			break // Exit the loop gracefully
-		}
 	}
 }
@@ -0,0 +1,273 @@
+package plugin
+
+import (
+	"fmt"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+// modeNames are the names of modes in goedit.map and goedit.mode.
+var modeNames = map[string]editor.Mode{
+	"n": editor.ModeNormal,
+	"i": editor.ModeInsert,
+	"v": editor.ModeVisual,
+	"c": editor.ModeCommand,
+	"R": editor.ModeReplace,
+}
+
+// api returns the goedit table. Lines are numbered from 1 and columns from
+// 0, as in Vim's Lua API.
+func (h *Host) api() *lua.LTable {
+	return h.L.SetFuncs(h.L.NewTable(), map[string]lua.LGFunction{
+		"command":    h.command,
+		"map":        h.mapKeys,
+		"unmap":      h.unmapKeys,
+		"on":         h.on,
+		"exec":       h.exec,
+		"feed":       h.feed,
+		"line_count": h.lineCount,
+		"get_lines":  h.getLines,
+		"set_lines":  h.setLines,
+		"cursor":     h.cursor,
+		"set_cursor": h.setCursor,
+		"option":     h.option,
+		"set_option": h.setOption,
+		"message":    h.message,
+		"filename":   h.filename,
+		"mode":       h.mode,
+	})
+}
+
+// command implements goedit.command(name, fn), which adds an Ex command.
+// fn is called with a table of args, bang, line1, line2 and range, which
+// is true if a range was given.
+func (h *Host) command(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	for i := 0; i < len(name); i++ {
+		if c := name[i]; !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+			L.ArgError(1, "command name must be letters only")
+		}
+	}
+	if name == "" {
+		L.ArgError(1, "command name must not be empty")
+	}
+	cmd.RegisterCommand(name, func(e *editor.Editor, c cmd.ExCommand) error {
+		opts := L.NewTable()
+		opts.RawSetString("args", lua.LString(c.Args))
+		opts.RawSetString("bang", lua.LBool(c.Bang))
+		opts.RawSetString("line1", lua.LNumber(c.Range.Start+1))
+		opts.RawSetString("line2", lua.LNumber(c.Range.End+1))
+		opts.RawSetString("range", lua.LBool(c.HasRange))
+		return h.call(fn, opts)
+	})
+	return 0
+}
+
+// checkMode returns the mode named by argument n.
+func checkMode(L *lua.LState, n int) editor.Mode {
+	name := L.CheckString(n)
+	mode, ok := modeNames[name]
+	if !ok || mode == editor.ModeCommand || mode == editor.ModeReplace {
+		L.ArgError(n, fmt.Sprintf("invalid mode %q", name))
+	}
+	return mode
+}
+
+// mapKeys implements goedit.map(mode, keys, fn), which makes keys typed in
+// mode "n", "i" or "v" call fn.
+func (h *Host) mapKeys(L *lua.LState) int {
+	mode := checkMode(L, 1)
+	keys := L.CheckString(2)
+	fn := L.CheckFunction(3)
+	if keys == "" {
+		L.ArgError(2, "keys must not be empty")
+	}
+	h.e.MapKeys(mode, keys, func() { h.callback(fn) })
+	return 0
+}
+
+// unmapKeys implements goedit.unmap(mode, keys).
+func (h *Host) unmapKeys(L *lua.LState) int {
+	mode := checkMode(L, 1)
+	L.Push(lua.LBool(h.e.UnmapKeys(mode, L.CheckString(2))))
+	return 1
+}
+
+// on implements goedit.on(event, fn), which calls fn with a table of event,
+// file and match whenever the event fires.
+func (h *Host) on(L *lua.LState) int {
+	name := L.CheckString(1)
+	fn := L.CheckFunction(2)
+	h.e.On(name, func(ev editor.Event) {
+		t := L.NewTable()
+		t.RawSetString("event", lua.LString(ev.Name))
+		t.RawSetString("file", lua.LString(ev.File))
+		t.RawSetString("match", lua.LString(ev.Match))
+		h.callback(fn, t)
+	})
+	return 0
+}
+
+// exec implements goedit.exec(command), which runs an Ex command and
+// raises its error, if any.
+func (h *Host) exec(L *lua.LState) int {
+	if err := cmd.ExecuteCommand(h.e, L.CheckString(1)); err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	return 0
+}
+
+// feed implements goedit.feed(keys), which runs keys as Normal mode input
+// as :normal does.
+func (h *Host) feed(L *lua.LState) int {
+	if err := cmd.ExecuteCommand(h.e, "normal "+L.CheckString(1)); err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	return 0
+}
+
+// lineCount implements goedit.line_count().
+func (h *Host) lineCount(L *lua.LState) int {
+	L.Push(lua.LNumber(len(h.e.EditorContent)))
+	return 1
+}
+
+// getLines implements goedit.get_lines([first [, last]]), which returns
+// lines first to last as a list. They default to the whole buffer.
+func (h *Host) getLines(L *lua.LState) int {
+	count := len(h.e.EditorContent)
+	first := L.OptInt(1, 1)
+	last := L.OptInt(2, count)
+	if first < 1 || first > count+1 {
+		L.ArgError(1, "line out of range")
+	}
+	if last < first-1 || last > count {
+		L.ArgError(2, "line out of range")
+	}
+	t := L.NewTable()
+	for _, line := range h.e.EditorContent[first-1 : last] {
+		t.Append(lua.LString(line))
+	}
+	L.Push(t)
+	return 1
+}
+
+// setLines implements goedit.set_lines(first, last, lines), which replaces
+// lines first to last with a list of lines. With last one less than first
+// the lines are inserted before first. The cursor stays where it is, or on
+// the last line if that is now before it.
+func (h *Host) setLines(L *lua.LState) int {
+	count := len(h.e.EditorContent)
+	first := L.CheckInt(1)
+	last := L.CheckInt(2)
+	t := L.CheckTable(3)
+	if first < 1 || first > count+1 {
+		L.ArgError(1, "line out of range")
+	}
+	if last < first-1 || last > count {
+		L.ArgError(2, "line out of range")
+	}
+	var lines []string
+	for i := 1; i <= t.Len(); i++ {
+		s, ok := t.RawGetInt(i).(lua.LString)
+		if !ok {
+			L.ArgError(3, "lines must be strings")
+		}
+		lines = append(lines, string(s))
+	}
+	if len(lines) == 0 && last < first {
+		return 0
+	}
+
+	y, x := h.e.CursorY, h.e.CursorX
+	h.e.ReplaceLines(first-1, last-1, lines)
+	h.e.CursorY = min(y, len(h.e.EditorContent)-1)
+	h.e.CursorX = min(x, len(h.e.EditorContent[h.e.CursorY]))
+	return 0
+}
+
+// cursor implements goedit.cursor(), which returns the cursor line and
+// column.
+func (h *Host) cursor(L *lua.LState) int {
+	L.Push(lua.LNumber(h.e.CursorY + 1))
+	L.Push(lua.LNumber(h.e.CursorX))
+	return 2
+}
+
+// setCursor implements goedit.set_cursor(line, col). Positions past the
+// end of the buffer or line are moved back onto it.
+func (h *Host) setCursor(L *lua.LState) int {
+	line := L.CheckInt(1)
+	col := L.CheckInt(2)
+	h.e.CursorY = max(min(line-1, len(h.e.EditorContent)-1), 0)
+	h.e.CursorX = max(min(col, len(h.e.EditorContent[h.e.CursorY])), 0)
+	return 0
+}
+
+// option implements goedit.option(name), which returns the value of an
+// option.
+func (h *Host) option(L *lua.LState) int {
+	name := L.CheckString(1)
+	switch v := h.e.OptionValue(name).(type) {
+	case bool:
+		L.Push(lua.LBool(v))
+	case int:
+		L.Push(lua.LNumber(v))
+	case string:
+		L.Push(lua.LString(v))
+	default:
+		L.ArgError(1, "Unknown option: "+name)
+	}
+	return 1
+}
+
+// setOption implements goedit.set_option(name, value), which sets an
+// option as :set does.
+func (h *Host) setOption(L *lua.LState) int {
+	name := L.CheckString(1)
+	var value any
+	switch v := L.CheckAny(2).(type) {
+	case lua.LBool:
+		value = bool(v)
+	case lua.LNumber:
+		value = int(v)
+	case lua.LString:
+		value = string(v)
+	default:
+		L.ArgError(2, "value must be a boolean, number or string")
+	}
+	if err := h.e.SetOption(name, value); err != nil {
+		L.RaiseError("%s", err.Error())
+	}
+	return 0
+}
+
+// message implements goedit.message(text), which shows text in the status
+// bar.
+func (h *Host) message(L *lua.LState) int {
+	h.e.SetStatusMessage(L.CheckString(1))
+	return 0
+}
+
+// filename implements goedit.filename(), which returns the name of the file
+// being edited, or "" if it has none.
+func (h *Host) filename(L *lua.LState) int {
+	L.Push(lua.LString(h.e.Filename))
+	return 1
+}
+
+// mode implements goedit.mode(), which returns "n", "i", "v", "c" or "R".
+func (h *Host) mode(L *lua.LState) int {
+	for name, mode := range modeNames {
+		if mode == h.e.CurrentMode {
+			L.Push(lua.LString(name))
+			return 1
+		}
+	}
+	L.Push(lua.LString("c")) // File name prompt
+	return 1
+}
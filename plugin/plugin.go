@@ -0,0 +1,135 @@
+// Package plugin runs Lua plugins that extend the editor with commands, key
+// mappings and event handlers through the goedit API table.
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	lua "github.com/yuin/gopher-lua"
+
+	"goedit/cmd"
+	"goedit/editor"
+)
+
+// Host holds the Lua state shared by all plugins of an editor.
+type Host struct {
+	L *lua.LState
+	e *editor.Editor
+}
+
+// New returns a host for e with the goedit API installed, and registers
+// the :lua and :luafile commands.
+func New(e *editor.Editor) *Host {
+	h := &Host{L: lua.NewState(), e: e}
+	h.L.SetGlobal("goedit", h.api())
+	cmd.RegisterCommand("lua", h.luaCommand)
+	cmd.RegisterCommand("luafile", h.luafileCommand)
+	return h
+}
+
+// Close releases the Lua state.
+func (h *Host) Close() {
+	h.L.Close()
+}
+
+// Dir returns the directory plugins are loaded from: "plugins" next to the
+// config file. It returns "" if that cannot be determined.
+func Dir() string {
+	path := cmd.ConfigPath()
+	if path == "" {
+		return ""
+	}
+	return filepath.Join(filepath.Dir(path), "plugins")
+}
+
+// LoadPlugins runs every .lua file in dir in name order. A failing plugin
+// does not stop the others; the first error is returned. A missing
+// directory is not an error.
+func (h *Host) LoadPlugins(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".lua") {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var first error
+	for _, name := range names {
+		if err := h.DoFile(filepath.Join(dir, name)); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+// DoString runs a chunk of Lua code.
+func (h *Host) DoString(code string) error {
+	return luaError(h.L.DoString(code))
+}
+
+// DoFile runs a Lua file.
+func (h *Host) DoFile(path string) error {
+	return luaError(h.L.DoFile(path))
+}
+
+// call calls a Lua function, returning any error it raises.
+func (h *Host) call(fn *lua.LFunction, args ...lua.LValue) error {
+	return luaError(h.L.CallByParam(lua.P{Fn: fn, Protect: true}, args...))
+}
+
+// callback calls a Lua function from a mapping or event handler, where
+// there is no caller to return an error to, and shows any error in the
+// status bar.
+func (h *Host) callback(fn *lua.LFunction, args ...lua.LValue) {
+	if err := h.call(fn, args...); err != nil {
+		h.e.SetStatusMessage(err.Error())
+	}
+}
+
+// luaError turns an error from the Lua state into one whose message is the
+// Lua error message alone, without the stack trace.
+func luaError(err error) error {
+	var apiErr *lua.ApiError
+	if errors.As(err, &apiErr) {
+		if apiErr.Cause != nil {
+			return fmt.Errorf("Lua: %v", apiErr.Cause)
+		}
+		return fmt.Errorf("Lua: %s", apiErr.Object.String())
+	}
+	return err
+}
+
+// luaCommand implements :lua {code}.
+func (h *Host) luaCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if c.Args == "" {
+		return errors.New("Argument required")
+	}
+	return h.DoString(c.Args)
+}
+
+// luafileCommand implements :luafile {file}.
+func (h *Host) luafileCommand(e *editor.Editor, c cmd.ExCommand) error {
+	if c.Args == "" {
+		return errors.New("Argument required")
+	}
+	path := cmd.ExpandHome(c.Args)
+	if _, err := os.Stat(path); err != nil {
+		return fmt.Errorf("Can't open file %s", c.Args)
+	}
+	return h.DoFile(path)
+}
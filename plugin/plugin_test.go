@@ -0,0 +1,221 @@
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"goedit/cmd"
+	"goedit/editor"
+	"goedit/input"
+)
+
+// newTestHost returns a host for an editor with content, in Normal mode.
+func newTestHost(t *testing.T, content ...string) (*Host, *editor.Editor) {
+	t.Helper()
+	e := editor.NewEditor(80, 24)
+	e.EditorContent = content
+	e.CurrentMode = editor.ModeNormal
+	h := New(e)
+	t.Cleanup(h.Close)
+	return h, e
+}
+
+func feedKeys(e *editor.Editor, keys string) {
+	for i := 0; i < len(keys); i++ {
+		input.ProcessInput(e, keys[i])
+	}
+}
+
+func TestBufferAPI(t *testing.T) {
+	tests := []struct {
+		name           string
+		code           string
+		expectedLines  []string
+		expectedCursor editor.Position
+	}{
+		{"get and set lines", `
+			local lines = goedit.get_lines(2, 3)
+			goedit.set_lines(1, 1, {lines[2], lines[1]})`,
+			[]string{"three", "two", "two", "three"}, editor.Position{Line: 1, Col: 1}},
+		{"insert lines", `goedit.set_lines(3, 2, {"x", "y"})`,
+			[]string{"one", "two", "x", "y", "three"}, editor.Position{Line: 1, Col: 1}},
+		{"delete lines", `goedit.set_lines(2, goedit.line_count(), {})`,
+			[]string{"one"}, editor.Position{Line: 0, Col: 1}},
+		{"set cursor", `goedit.set_cursor(3, 99)`,
+			[]string{"one", "two", "three"}, editor.Position{Line: 2, Col: 5}},
+		{"exec", `goedit.exec("2normal Ax")`,
+			[]string{"one", "twox", "three"}, editor.Position{Line: 1, Col: 4}},
+		{"feed", `goedit.feed("ddp")`,
+			[]string{"one", "three", "two"}, editor.Position{Line: 2, Col: 0}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, e := newTestHost(t, "one", "two", "three")
+			e.CursorY, e.CursorX = 1, 1
+			if err := h.DoString(tt.code); err != nil {
+				t.Fatalf("DoString: %v", err)
+			}
+			if strings.Join(e.EditorContent, "|") != strings.Join(tt.expectedLines, "|") {
+				t.Errorf("Expected lines %q, got %q", tt.expectedLines, e.EditorContent)
+			}
+			if e.CursorY != tt.expectedCursor.Line || e.CursorX != tt.expectedCursor.Col {
+				t.Errorf("Expected cursor at %d,%d, got %d,%d", tt.expectedCursor.Line, tt.expectedCursor.Col, e.CursorY, e.CursorX)
+			}
+		})
+	}
+}
+
+func TestQueries(t *testing.T) {
+	h, e := newTestHost(t, "one", "two")
+	e.Filename = "notes.txt"
+	e.CursorY, e.CursorX = 1, 2
+	err := h.DoString(`
+		local line, col = goedit.cursor()
+		goedit.set_option("tabstop", 3)
+		goedit.message(table.concat({goedit.filename(), line, col, goedit.line_count(),
+			goedit.option("tabstop"), tostring(goedit.option("expandtab")), goedit.mode()}, ","))`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+	if want := "notes.txt,2,2,2,3,false,n"; e.StatusMessage != want {
+		t.Errorf("Expected message %q, got %q", want, e.StatusMessage)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		code     string
+		expected string
+	}{
+		{"syntax error", `goedit.message(`, "Lua:"},
+		{"runtime error", `error("boom")`, "boom"},
+		{"line out of range", `goedit.get_lines(5, 5)`, "line out of range"},
+		{"unknown option", `goedit.option("nosuch")`, "Unknown option"},
+		{"failing command", `goedit.exec("nosuch")`, "Unknown command: nosuch"},
+		{"bad mode", `goedit.map("x", "a", function() end)`, "invalid mode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h, _ := newTestHost(t, "one")
+			err := h.DoString(tt.code)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error containing %q, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestCommand(t *testing.T) {
+	h, e := newTestHost(t, "one", "two", "three")
+	err := h.DoString(`
+		goedit.command("Upper", function(opts)
+			if opts.args == "fail" then error("failed") end
+			local lines = goedit.get_lines(opts.line1, opts.line2)
+			for i, line in ipairs(lines) do lines[i] = line:upper() .. opts.args end
+			goedit.set_lines(opts.line1, opts.line2, lines)
+		end)`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+	if err := cmd.ExecuteCommand(e, "2,3Upper !"); err != nil {
+		t.Fatalf("Upper: %v", err)
+	}
+	if want := "one|TWO!|THREE!"; strings.Join(e.EditorContent, "|") != want {
+		t.Errorf("Expected lines %q, got %q", want, e.EditorContent)
+	}
+	if err := cmd.ExecuteCommand(e, "Upper fail"); err == nil || !strings.Contains(err.Error(), "failed") {
+		t.Errorf("Expected error from the command, got %v", err)
+	}
+	if err := h.DoString(`goedit.command("bad-name", function() end)`); err == nil {
+		t.Errorf("Expected error for an invalid command name")
+	}
+}
+
+func TestMap(t *testing.T) {
+	h, e := newTestHost(t, "one", "two")
+	err := h.DoString(`
+		goedit.map("n", ",d", function() goedit.set_lines(1, 1, {}) end)
+		goedit.map("i", "jk", function() goedit.message("mapped " .. goedit.mode()) end)
+		goedit.map("n", ",e", function() error("mapping failed") end)`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+
+	feedKeys(e, ",d")
+	if strings.Join(e.EditorContent, "|") != "two" {
+		t.Errorf("Expected ,d to delete the first line, got %q", e.EditorContent)
+	}
+	feedKeys(e, "u")
+	if strings.Join(e.EditorContent, "|") != "one|two" {
+		t.Errorf("Expected u to undo the mapping, got %q", e.EditorContent)
+	}
+	feedKeys(e, "ijxjk")
+	if e.EditorContent[0] != "jxone" || e.StatusMessage != "mapped i" {
+		t.Errorf("Expected jk to run its mapping, got %q and message %q", e.EditorContent[0], e.StatusMessage)
+	}
+	feedKeys(e, "\x1b,e")
+	if !strings.Contains(e.StatusMessage, "mapping failed") {
+		t.Errorf("Expected the error in the status bar, got %q", e.StatusMessage)
+	}
+	if err := h.DoString(`assert(goedit.unmap("n", ",d"))`); err != nil {
+		t.Errorf("unmap: %v", err)
+	}
+}
+
+func TestEvents(t *testing.T) {
+	h, e := newTestHost(t, "one  ")
+	e.Filename = filepath.Join(t.TempDir(), "out.txt")
+	err := h.DoString(`
+		goedit.on("BufWritePre", function(ev) goedit.set_lines(1, 1, {"stamped"}) end)
+		goedit.on("BufWritePost", function(ev) goedit.message(ev.event .. " " .. ev.file) end)`)
+	if err != nil {
+		t.Fatalf("DoString: %v", err)
+	}
+	cmd.SaveFile(e)
+	data, _ := os.ReadFile(e.Filename)
+	if string(data) != "stamped\n" {
+		t.Errorf("Expected BufWritePre to change the saved text, got %q", data)
+	}
+	if want := "BufWritePost " + e.Filename; e.StatusMessage != want {
+		t.Errorf("Expected message %q, got %q", want, e.StatusMessage)
+	}
+}
+
+func TestLoadPlugins(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"b.lua":      `goedit.message(goedit.message_prefix .. "b")`,
+		"a.lua":      `goedit.message_prefix = "a"`,
+		"broken.lua": `error("broken plugin")`,
+		"notes.txt":  `error("not a plugin")`,
+	}
+	for name, code := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(code), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	h, e := newTestHost(t, "")
+	err := h.LoadPlugins(dir)
+	if err == nil || !strings.Contains(err.Error(), "broken plugin") {
+		t.Errorf("Expected error from broken.lua, got %v", err)
+	}
+	if e.StatusMessage != "ab" {
+		t.Errorf("Expected plugins to run in name order, got message %q", e.StatusMessage)
+	}
+	if err := h.LoadPlugins(filepath.Join(dir, "missing")); err != nil {
+		t.Errorf("Expected no error for a missing directory, got %v", err)
+	}
+
+	if err := cmd.ExecuteCommand(e, "luafile "+filepath.Join(dir, "a.lua")); err != nil {
+		t.Errorf("luafile: %v", err)
+	}
+	if err := cmd.ExecuteCommand(e, `lua goedit.message("hi")`); err != nil || e.StatusMessage != "hi" {
+		t.Errorf("Expected :lua to run, got %v and message %q", err, e.StatusMessage)
+	}
+}
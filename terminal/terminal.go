@@ -123,6 +123,17 @@ func ReadKey() byte {
 	return key
 }
 
+// UseTTY makes keys be read from the controlling terminal rather than
+// standard input, so that standard input can be used for text piped in.
+func UseTTY() error {
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return err
+	}
+	os.Stdin = tty
+	return nil
+}
+
 // GetSize returns the current width and height of the terminal.
 func GetSize() (width, height int, err error) {
 	return term.GetSize(int(os.Stdout.Fd()))
@@ -0,0 +1,114 @@
+package ui
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"goedit/editor"
+)
+
+// maxPopupHeight is the most items a popup menu shows at once.
+const maxPopupHeight = 10
+
+// drawCompletionMenu draws the Insert mode completion items in a popup menu
+// below the completed text, or above it if there is no room below.
+func drawCompletionMenu(e *editor.Editor, buf *bytes.Buffer) {
+	c := e.Completion
+	if c == nil || e.CurrentMode != editor.ModeInsert {
+		return
+	}
+	line := e.EditorContent[c.Start.Line]
+	col := e.DisplayCol(line, c.Start.Col) - e.ColOffset + e.GutterWidth()
+	drawPopup(e, buf, c.Start.Line-e.RowOffset, col, c.Items, c.Selected)
+}
+
+// drawPopup draws items in a box next to screen row anchorRow (0-based),
+// starting at screen column col, with the selected item highlighted. If
+// there are more items than fit, the shown ones follow the selection.
+func drawPopup(e *editor.Editor, buf *bytes.Buffer, anchorRow, col int, items []string, selected int) {
+	textRows := e.TermHeight - 1
+	height := min(len(items), maxPopupHeight)
+	top := anchorRow + 1
+	if top+height > textRows { // No room below: show it above
+		height = min(height, anchorRow)
+		top = anchorRow - height
+	}
+	if height <= 0 {
+		return
+	}
+
+	width := 0
+	for _, item := range items {
+		width = max(width, len(item))
+	}
+	width = min(width+2, e.TermWidth) // A space either side
+	col = max(min(col, e.TermWidth-width), 0)
+
+	first := 0
+	if selected >= height {
+		first = selected - height + 1
+	}
+	for i := 0; i < height; i++ {
+		n := first + i
+		fmt.Fprintf(buf, "\x1b[%d;%dH", top+i+1, col+1)
+		if n == selected {
+			buf.WriteString("\x1b[7m") // Selected item in inverse video
+		} else {
+			buf.WriteString("\x1b[47;30m") // Others black on grey
+		}
+		fmt.Fprintf(buf, " %-*.*s ", width-2, width-2, items[n])
+		buf.WriteString("\x1b[m")
+	}
+}
+
+// drawWildmenu draws the command-line completion items in a row over the
+// last text row, with the selected item highlighted. If they do not all fit,
+// the shown ones follow the selection.
+func drawWildmenu(e *editor.Editor, buf *bytes.Buffer) {
+	w := e.Wildmenu
+	if w == nil || e.TermHeight < 2 {
+		return
+	}
+	first := 0
+	for first < w.Selected && !wildmenuFits(e, w.Items[first:w.Selected+1]) {
+		first++
+	}
+
+	fmt.Fprintf(buf, "\x1b[%d;1H", e.TermHeight-1)
+	buf.WriteString("\x1b[47;30m")
+	width := 0
+	if first > 0 {
+		buf.WriteString("< ")
+		width += 2
+	}
+	for i := first; i < len(w.Items); i++ {
+		item := w.Items[i]
+		if width+len(item)+2 > e.TermWidth {
+			buf.WriteString(">")
+			width++
+			break
+		}
+		if i == w.Selected {
+			buf.WriteString("\x1b[7m" + item + "\x1b[27m")
+		} else {
+			buf.WriteString(item)
+		}
+		buf.WriteString("  ")
+		width += len(item) + 2
+	}
+	if width < e.TermWidth {
+		buf.WriteString(strings.Repeat(" ", e.TermWidth-width))
+	}
+	buf.WriteString("\x1b[m")
+}
+
+// wildmenuFits reports whether items fit in the wildmenu row after the "< "
+// shown when earlier items are hidden, leaving room for a ">".
+func wildmenuFits(e *editor.Editor, items []string) bool {
+	width := 2
+	for _, item := range items {
+		width += len(item) + 2
+	}
+	return width+1 <= e.TermWidth
+}
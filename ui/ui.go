@@ -23,11 +23,16 @@ func RefreshScreen(e *editor.Editor) {
 		log.Printf("Error getting terminal size: %v", err)
 	}
 
+	e.ScrollToCursor()
+
 	screenBuf.WriteString("\x1b[?25l") // Hide cursor
 	screenBuf.WriteString("\x1b[H")    // Move cursor home
 
 	// Draw visible portion of the file content
 	drawTextRows(e, &screenBuf)
+	drawCompletionMenu(e, &screenBuf)
+	drawWildmenu(e, &screenBuf)
+	drawOutput(e, &screenBuf)
 
 	// Draw Status Bar
 	drawStatusBar(e, &screenBuf)
@@ -45,19 +50,18 @@ func RefreshScreen(e *editor.Editor) {
 
 // drawTextRows draws the visible lines of the file content or tildes.
 func drawTextRows(e *editor.Editor, buf *bytes.Buffer) {
+	textWidth := e.TextWidth()
 	for y := 0; y < e.TermHeight-1; y++ {
 		fileRow := e.RowOffset + y
 
 		if fileRow >= len(e.EditorContent) {
 			buf.WriteString("~") // Draw tilde
 		} else {
-			line := e.EditorContent[fileRow]
-			// TODO: Handle colOffset for horizontal scrolling
-			lineLen := len(line)
-			if lineLen > e.TermWidth {
-				line = line[:e.TermWidth] // Truncate long lines
-			}
-			buf.WriteString(line)
+			drawGutter(e, buf, fileRow)
+			line := e.ExpandTabs(e.EditorContent[fileRow])
+			start := min(e.ColOffset, len(line))
+			end := min(start+textWidth, len(line)) // Truncate long lines
+			writeLine(e, buf, fileRow, line[start:end], start)
 		}
 
 		buf.WriteString("\x1b[K") // Clear rest of line
@@ -68,6 +72,77 @@ func drawTextRows(e *editor.Editor, buf *bytes.Buffer) {
 	}
 }
 
+// drawGutter draws the sign column and line number for a file row.
+func drawGutter(e *editor.Editor, buf *bytes.Buffer, fileRow int) {
+	if e.ShowSignColumn() {
+		sign, _ := e.SignAt(fileRow)
+		fmt.Fprintf(buf, "%-2.2s", sign.Text)
+	}
+	if e.ShowLineNumbers() {
+		buf.WriteString("\x1b[2m") // Dim line numbers
+		fmt.Fprintf(buf, "%*s ", e.NumberWidth(), e.LineNumberLabel(fileRow))
+		buf.WriteString("\x1b[m")
+	}
+}
+
+// writeLine writes the visible part of a line, with tabs expanded, which
+// starts at screen column startCol, showing any part of it selected in
+// Visual mode in inverse video.
+func writeLine(e *editor.Editor, buf *bytes.Buffer, fileRow int, line string, startCol int) {
+	if e.CurrentMode != editor.ModeVisual {
+		buf.WriteString(line)
+		return
+	}
+	sel := e.VisualRegion()
+	if fileRow < sel.Start.Line || fileRow > sel.End.Line {
+		buf.WriteString(line)
+		return
+	}
+
+	from, to := 0, len(line) // Selected columns [from, to) of the visible text
+	if !sel.Linewise {
+		text := e.EditorContent[fileRow]
+		if fileRow == sel.Start.Line {
+			from = max(min(e.DisplayCol(text, sel.Start.Col)-startCol, len(line)), 0)
+		}
+		if fileRow == sel.End.Line {
+			to = max(min(e.DisplayCol(text, sel.End.Col+1)-startCol, len(line)), 0)
+		}
+	}
+	if from > to {
+		from = to
+	}
+	buf.WriteString(line[:from])
+	buf.WriteString("\x1b[7m")
+	buf.WriteString(line[from:to])
+	buf.WriteString("\x1b[m")
+	buf.WriteString(line[to:])
+}
+
+// drawOutput draws command output over the bottom rows of the text. Output
+// taller than the text area is cut short with a note of how much is hidden.
+func drawOutput(e *editor.Editor, buf *bytes.Buffer) {
+	if e.Output == nil {
+		return
+	}
+	lines := e.Output
+	rows := max(e.TermHeight-1, 1)
+	if len(lines) > rows {
+		hidden := len(lines) - rows + 1
+		lines = append(lines[:rows-1:rows-1], fmt.Sprintf("-- %d more lines --", hidden))
+	}
+	top := e.TermHeight - len(lines) // 1-based row of the first line
+	for i, line := range lines {
+		fmt.Fprintf(buf, "\x1b[%d;1H", top+i)
+		line = e.ExpandTabs(line)
+		if len(line) > e.TermWidth {
+			line = line[:e.TermWidth]
+		}
+		buf.WriteString(line)
+		buf.WriteString("\x1b[K")
+	}
+}
+
 // drawStatusBar renders the status bar at the bottom line.
 func drawStatusBar(e *editor.Editor, buf *bytes.Buffer) {
 	fmt.Fprintf(buf, "\x1b[%d;%dH", e.TermHeight, 1) // Move to last line
@@ -75,17 +150,28 @@ func drawStatusBar(e *editor.Editor, buf *bytes.Buffer) {
 
 	// Message content logic
 	msg := ""
-	if e.CurrentMode == editor.ModeCommand {
+	if e.Searching() {
+		msg = string(e.CommandPrompt) + e.CommandBuffer
+	} else if e.CurrentMode == editor.ModeCommand {
 		msg = ":" + e.CommandBuffer
+	} else if e.Output != nil {
+		msg = "Press ENTER or type command to continue"
 	} else if e.CurrentMode == editor.ModeFileNamePrompt {
 		msg = e.StatusMessage
-	} else if time.Since(e.StatusMessageTime) < 5*time.Second {
+	} else if time.Since(e.StatusMessageTime) < time.Duration(e.IntOption("statustimeout"))*time.Millisecond {
 		msg = e.StatusMessage
 	} else {
 		e.StatusMessage = ""
 		modeStr := "NORMAL"
-		if e.CurrentMode == editor.ModeInsert {
+		switch {
+		case e.CurrentMode == editor.ModeInsert:
 			modeStr = "INSERT"
+		case e.CurrentMode == editor.ModeReplace:
+			modeStr = "REPLACE"
+		case e.CurrentMode == editor.ModeVisual && e.VisualLinewise:
+			modeStr = "VISUAL LINE"
+		case e.CurrentMode == editor.ModeVisual:
+			modeStr = "VISUAL"
 		}
 		fn := e.Filename
 		if fn == "" {
@@ -98,7 +184,19 @@ func drawStatusBar(e *editor.Editor, buf *bytes.Buffer) {
 		if len(fn) > maxFnLen {
 			fn = fn[:maxFnLen-3] + "..."
 		}
+		if e.BoolOption("readonly") {
+			fn += " [RO]"
+		}
+		if !e.BoolOption("modifiable") {
+			fn += " [-]"
+		}
+		if e.RecordingRegister != 0 {
+			modeStr += fmt.Sprintf(" (recording @%c)", e.RecordingRegister)
+		}
 		leftStatus := fmt.Sprintf(" %s | %s ", modeStr, fn)
+		if sign, ok := e.SignAt(e.CursorY); ok && sign.Message != "" {
+			leftStatus += "| " + sign.Message + " "
+		}
 		rightStatus := fmt.Sprintf(" %d/%d ", e.CursorY+1, len(e.EditorContent))
 		spaces := e.TermWidth - len(leftStatus) - len(rightStatus)
 		if spaces < 0 {
@@ -119,9 +217,19 @@ func drawStatusBar(e *editor.Editor, buf *bytes.Buffer) {
 
 // positionCursor moves the terminal cursor to the calculated screen position.
 func positionCursor(e *editor.Editor, buf *bytes.Buffer) {
+	if e.Output == nil && (e.CurrentMode == editor.ModeCommand || e.CurrentMode == editor.ModeFileNamePrompt) {
+		// On the command line, after the ":", "/" or "?" or the prompt
+		col := 1 + len(":") + e.CommandCursor()
+		if e.CurrentMode == editor.ModeFileNamePrompt {
+			col = 1 + len(e.StatusMessage) - len(e.CommandBuffer) + e.CommandCursor()
+		}
+		fmt.Fprintf(buf, "\x1b[%d;%dH", e.TermHeight, max(min(col, e.TermWidth), 1))
+		return
+	}
+
 	// Calculate screen position based on file cursor and viewport offset
 	screenCursorY := e.CursorY - e.RowOffset + 1
-	screenCursorX := e.CursorX - e.ColOffset + 1
+	screenCursorX := e.CursorDisplayCol() - e.ColOffset + 1 + e.GutterWidth()
 
 	// Clamp cursor position to valid screen area
 	if screenCursorY < 1 {